@@ -3,12 +3,14 @@ module stash.appscode.dev/prober-demo
 go 1.12
 
 require (
+	github.com/appscode/go v0.0.0-20191025021232-311ac347b3ef
+	github.com/gabriel-vasile/mimetype v0.3.22
 	github.com/gogo/protobuf v1.2.2-0.20190723190241-65acae22fc9d // indirect
-	github.com/golang/protobuf v1.3.2 // indirect
+	github.com/golang/protobuf v1.3.2
 	github.com/gorilla/mux v1.6.2
 	github.com/spf13/cobra v0.0.3
 	golang.org/x/crypto v0.0.0-20190611184440-5c40567a22f8 // indirect
-	golang.org/x/net v0.0.0-20190812203447-cdfb69ac37fc // indirect
+	golang.org/x/net v0.0.0-20190812203447-cdfb69ac37fc
 	golang.org/x/oauth2 v0.0.0-20190604053449-0f29369cfe45 // indirect
 	golang.org/x/sys v0.0.0-20190507160741-ecd444e8653b // indirect
 	google.golang.org/appengine v1.5.0 // indirect
@@ -16,7 +18,7 @@ require (
 	k8s.io/apimachinery v0.0.0-20191004115801-a2eda9f80ab8
 	k8s.io/client-go v11.0.0+incompatible
 	k8s.io/klog v0.4.0 // indirect
-	k8s.io/utils v0.0.0-20190801114015-581e00157fb1 // indirect
+	k8s.io/utils v0.0.0-20190801114015-581e00157fb1
 	kmodules.xyz/prober v0.0.0-20191107124222-ccf3578a9432
 )
 
@@ -0,0 +1,568 @@
+package v1
+
+import (
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	api "kmodules.xyz/prober/api"
+	prober_v1 "kmodules.xyz/prober/api/v1"
+)
+
+// Handler extends prober_v1.Handler with the actions and options supported by this demo.
+// One and only one action should be specified, either in the embedded handler or below.
+type Handler struct {
+	prober_v1.Handler `json:",inline"`
+	// HTTP specifies an http request of any method to perform.
+	// HTTPGet and HTTPPost are shortcuts for it.
+	// +optional
+	HTTP *HTTPAction `json:"http,omitempty"`
+	// TLSCert specifies a check of the certificates served on a TLS port.
+	// +optional
+	TLSCert *TLSCertAction `json:"tlsCert,omitempty"`
+	// GRPC specifies a call to the gRPC health checking service.
+	// +optional
+	GRPC *GRPCAction `json:"grpc,omitempty"`
+	// UDP specifies a datagram to send, and optionally the reply to wait for.
+	// +optional
+	UDP *UDPAction `json:"udp,omitempty"`
+	// DNS specifies a name to resolve.
+	// +optional
+	DNS *DNSAction `json:"dns,omitempty"`
+	// Postgres specifies a PostgreSQL server to authenticate to.
+	// +optional
+	Postgres *DatabaseAction `json:"postgres,omitempty"`
+	// MySQL specifies a MySQL server to authenticate to.
+	// +optional
+	MySQL *DatabaseAction `json:"mysql,omitempty"`
+	// Redis specifies a Redis server to ping.
+	// +optional
+	Redis *RedisAction `json:"redis,omitempty"`
+	// MongoDB specifies a MongoDB server to ask for its replication state.
+	// +optional
+	MongoDB *MongoDBAction `json:"mongodb,omitempty"`
+	// HTTPScenario specifies HTTP requests sent in order, passing values from one response to the next requests.
+	// +optional
+	HTTPScenario *HTTPScenarioAction `json:"httpScenario,omitempty"`
+	// Composite specifies nested probes whose results are combined into one.
+	// +optional
+	Composite *CompositeAction `json:"composite,omitempty"`
+	// HTTPHeadersFrom adds headers to HTTP, HTTPGet and HTTPPost probes whose values are resolved when the probe runs.
+	// Resolved values are redacted from the probe output.
+	// +optional
+	HTTPHeadersFrom []HTTPHeaderSource `json:"httpHeadersFrom,omitempty"`
+	// HTTPAuth adds an Authorization header to HTTP, HTTPGet and HTTPPost probes.
+	// +optional
+	HTTPAuth *HTTPAuth `json:"httpAuth,omitempty"`
+	// HTTPBody sets the body of HTTP and HTTPPost probes.
+	// +optional
+	HTTPBody *HTTPBodySource `json:"httpBody,omitempty"`
+	// HTTPAssertions are checked against the response of HTTP, HTTPGet and HTTPPost probes.
+	// +optional
+	HTTPAssertions *HTTPAssertions `json:"httpAssertions,omitempty"`
+	// HTTPRedirects sets which redirects HTTP, HTTPGet and HTTPPost probes follow.
+	// Defaults to following up to 10 redirects to the same host.
+	// +optional
+	HTTPRedirects *HTTPRedirectPolicy `json:"httpRedirects,omitempty"`
+	// HTTPStatusResults maps the status code of the last response of HTTP, HTTPGet and HTTPPost probes to a result.
+	// The first matching entry applies. Codes that match none are successes from 200 to 299,
+	// warnings from 300 to 399 and failures otherwise.
+	// +optional
+	HTTPStatusResults []HTTPStatusResult `json:"httpStatusResults,omitempty"`
+	// TCPExchange sends a payload once TCPSocket probes are connected, and checks the reply.
+	// +optional
+	TCPExchange *TCPExchange `json:"tcpExchange,omitempty"`
+	// Retries is the number of times a failed probe is run again. Retries stop when the probe timeout is reached.
+	// The reason of a probe run more than once shows the result, latency and reason of every attempt.
+	// +optional
+	Retries int32 `json:"retries,omitempty"`
+	// RetryBackoff sets the delay between attempts.
+	// Defaults to a constant delay of 1s.
+	// +optional
+	RetryBackoff *RetryBackoff `json:"retryBackoff,omitempty"`
+	// RetryOn lists the failures that are retried: connectionError, timeout, or the status codes of HTTP probes,
+	// e.g. "503", or inclusive ranges of them, e.g. "500-599".
+	// Defaults to every failure. Unknown results are never retried.
+	// +optional
+	RetryOn []string `json:"retryOn,omitempty"`
+	// PerAttemptTimeout is the timeout of every attempt of a probe with retries, so that an attempt that times out
+	// leaves time for the next one. It never goes past the probe timeout.
+	// Defaults to an equal share of the probe timeout for each attempt.
+	// +optional
+	PerAttemptTimeout *metav1.Duration `json:"perAttemptTimeout,omitempty"`
+	// TLS configures how the server certificate of HTTPS, TLSCert and GRPC probes is verified.
+	// If it is not set, HTTPS probes skip certificate verification.
+	// +optional
+	TLS *TLSConfig `json:"tls,omitempty"`
+}
+
+// HTTPAction describes an action based on HTTP requests of any method.
+// The request is built like the one of an HTTPPostAction, so a body is only sent if Body or Form is set.
+type HTTPAction struct {
+	// Method of the request, e.g. GET, PUT, PATCH, DELETE, HEAD or OPTIONS.
+	// Defaults to GET.
+	// +optional
+	Method string `json:"method,omitempty"`
+
+	prober_v1.HTTPPostAction `json:",inline"`
+}
+
+// HTTPRedirectPolicy describes the redirects followed by an HTTP probe.
+// The chain of followed redirects is appended to the probe reason.
+type HTTPRedirectPolicy struct {
+	// FollowNonLocal follows redirects to other hosts too.
+	// Otherwise the redirect response is the result of the probe, a warning by default.
+	// +optional
+	FollowNonLocal bool `json:"followNonLocal,omitempty"`
+	// MaxRedirects is the number of redirects followed before the probe fails.
+	// Zero follows none, and the redirect response is the result of the probe.
+	// Defaults to 10.
+	// +optional
+	MaxRedirects *int32 `json:"maxRedirects,omitempty"`
+}
+
+// HTTPStatusResult maps response status codes to the result of an HTTP probe.
+type HTTPStatusResult struct {
+	// Codes lists status codes, e.g. "302", or inclusive ranges of them, e.g. "300-399".
+	Codes []string `json:"codes"`
+	// Result of the probe when the status code is one of Codes.
+	// One of success, warning or failure.
+	Result api.Result `json:"result"`
+}
+
+// HTTPHeaderSource describes a request header whose value is resolved when the probe runs.
+type HTTPHeaderSource struct {
+	// Name of the header.
+	Name string `json:"name"`
+	// ValueFrom selects the value of the header.
+	ValueFrom ValueSource `json:"valueFrom"`
+}
+
+// HTTPAuth describes the credentials sent in the Authorization header.
+// One and only one of Basic and Bearer should be set.
+type HTTPAuth struct {
+	// Basic sends the credentials with the Basic scheme.
+	// +optional
+	Basic *BasicAuth `json:"basic,omitempty"`
+	// Bearer sends the selected token with the Bearer scheme.
+	// +optional
+	Bearer *ValueSource `json:"bearer,omitempty"`
+}
+
+// BasicAuth describes the credentials of the Basic authentication scheme.
+type BasicAuth struct {
+	// Username to authenticate as.
+	Username string `json:"username"`
+	// Password selects the password of Username.
+	Password ValueSource `json:"password"`
+}
+
+// ValueSource selects a value that is resolved when the probe runs.
+// One and only one of the following should be specified.
+type ValueSource struct {
+	// SecretKeyRef selects a key of a Secret in the pod's namespace.
+	// +optional
+	SecretKeyRef *core.SecretKeySelector `json:"secretKeyRef,omitempty"`
+	// ServiceAccountToken reads the projected ServiceAccount token available to the prober.
+	// +optional
+	ServiceAccountToken *ServiceAccountTokenSource `json:"serviceAccountToken,omitempty"`
+	// Env is the name of an environment variable of the prober.
+	// +optional
+	Env string `json:"env,omitempty"`
+}
+
+// ServiceAccountTokenSource describes where the projected ServiceAccount token is mounted.
+type ServiceAccountTokenSource struct {
+	// Path of the token file.
+	// Defaults to /var/run/secrets/kubernetes.io/serviceaccount/token.
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// HTTPBodySource describes the request body of an HTTP probe.
+// At most one of File, ConfigMapKeyRef, SecretKeyRef, Multipart and Protobuf should be set, it replaces Body and Form.
+// Use ContentType to send other encodings like XML as they are.
+type HTTPBodySource struct {
+	// ContentType is sent as the Content-Type of the body, whatever its source.
+	// Multipart bodies get their boundary appended to it.
+	// Defaults to the Content-Type header of the probe, or to a type detected from the body.
+	// +optional
+	ContentType string `json:"contentType,omitempty"`
+	// File is the path of a file read by the prober.
+	// +optional
+	File string `json:"file,omitempty"`
+	// ConfigMapKeyRef selects a key of a ConfigMap in the pod's namespace.
+	// +optional
+	ConfigMapKeyRef *core.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// SecretKeyRef selects a key of a Secret in the pod's namespace.
+	// The body is redacted from the probe output.
+	// +optional
+	SecretKeyRef *core.SecretKeySelector `json:"secretKeyRef,omitempty"`
+	// Multipart sends the parts as a multipart/form-data body.
+	// +optional
+	Multipart []MultipartPart `json:"multipart,omitempty"`
+	// Protobuf is a JSON object sent as a binary google.protobuf.Struct message.
+	// ContentType defaults to application/x-protobuf.
+	// +optional
+	Protobuf string `json:"protobuf,omitempty"`
+}
+
+// MultipartPart describes a part of a multipart/form-data body.
+type MultipartPart struct {
+	// Name of the form field.
+	Name string `json:"name"`
+	// Value of the form field.
+	// +optional
+	Value string `json:"value,omitempty"`
+	// File is the path of a file read by the prober and sent as the content of the field, in place of Value.
+	// Its base name is sent as the file name.
+	// +optional
+	File string `json:"file,omitempty"`
+	// ContentType of the part. Defaults to application/octet-stream for files.
+	// +optional
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// TCPExchange describes the bytes a TCP probe sends and expects once connected.
+// Send and ExpectBytes may contain the escape sequences \r, \n, \t, \0, \\ and \xHH.
+// If neither ExpectRegex nor ExpectBytes is set, the probe succeeds once Send is written.
+type TCPExchange struct {
+	// Send is written to the connection before reading.
+	// +optional
+	Send string `json:"send,omitempty"`
+	// ExpectRegex is a regular expression the received bytes must match.
+	// +optional
+	ExpectRegex string `json:"expectRegex,omitempty"`
+	// ExpectBytes is a byte sequence the received bytes must contain. It is ignored if ExpectRegex is set.
+	// +optional
+	ExpectBytes string `json:"expectBytes,omitempty"`
+	// ReadTimeout is how long the probe waits for the expected bytes.
+	// Defaults to the probe timeout.
+	// +optional
+	ReadTimeout *metav1.Duration `json:"readTimeout,omitempty"`
+}
+
+// UDPAction describes an action that sends a UDP datagram.
+// Send and ExpectBytes may contain the same escape sequences as in TCPExchange.
+// If neither ExpectRegex nor ExpectBytes is set, the probe succeeds unless the port is reported unreachable.
+type UDPAction struct {
+	// Name or number of the port to access on the container.
+	// Number must be in the range 1 to 65535.
+	// Name must be an IANA_SVC_NAME.
+	Port intstr.IntOrString `json:"port"`
+	// Host name to connect to, defaults to the pod IP.
+	// +optional
+	Host string `json:"host,omitempty"`
+	// Send is the payload of the datagram.
+	// +optional
+	Send string `json:"send,omitempty"`
+	// ExpectRegex is a regular expression a reply must match.
+	// +optional
+	ExpectRegex string `json:"expectRegex,omitempty"`
+	// ExpectBytes is a byte sequence a reply must contain. It is ignored if ExpectRegex is set.
+	// +optional
+	ExpectBytes string `json:"expectBytes,omitempty"`
+}
+
+// DNSAction describes an action that resolves a name.
+// The reason of the probe shows the answers and how long resolving took.
+type DNSAction struct {
+	// Name to resolve. SRV names include the service and protocol, e.g. "_http._tcp.example.com".
+	Name string `json:"name"`
+	// Type of the records to resolve. One of A, AAAA, CNAME or SRV.
+	// Defaults to A.
+	// +optional
+	Type string `json:"type,omitempty"`
+	// Server is the host:port of the DNS server to query.
+	// Defaults to the system resolver.
+	// +optional
+	Server string `json:"server,omitempty"`
+	// Expect is the set of expected answers, in any order. SRV answers are written "priority weight port target".
+	// If it is not set, any answer succeeds.
+	// +optional
+	Expect []string `json:"expect,omitempty"`
+	// WarnLatency is how long resolving may take before the probe returns warning.
+	// If it is not set, the latency is not checked.
+	// +optional
+	WarnLatency *metav1.Duration `json:"warnLatency,omitempty"`
+	// MaxLatency is how long resolving may take before the probe fails, even with the expected answers.
+	// If it is not set, the latency is not checked.
+	// +optional
+	MaxLatency *metav1.Duration `json:"maxLatency,omitempty"`
+}
+
+// DatabaseAction describes an action that connects to a database server, authenticates and optionally runs a query.
+// The password is redacted from the probe output.
+type DatabaseAction struct {
+	// Name or number of the port to access on the container.
+	// Number must be in the range 1 to 65535.
+	// Name must be an IANA_SVC_NAME.
+	Port intstr.IntOrString `json:"port"`
+	// Host name to connect to, defaults to the pod IP.
+	// +optional
+	Host string `json:"host,omitempty"`
+	// Database to connect to.
+	// Defaults to the user name for PostgreSQL, and to no database for MySQL.
+	// +optional
+	Database string `json:"database,omitempty"`
+	// Username to authenticate as.
+	Username string `json:"username"`
+	// Password selects the password of Username. If it is not set, the probe authenticates without password.
+	// +optional
+	Password *ValueSource `json:"password,omitempty"`
+	// Query is run once authenticated, e.g. "SELECT 1". The probe fails if the query fails.
+	// +optional
+	Query string `json:"query,omitempty"`
+	// RequireWritable fails the probe if the server does not accept writes:
+	// a PostgreSQL server in recovery or with read-only transactions, or a MySQL server with read_only set.
+	// +optional
+	RequireWritable bool `json:"requireWritable,omitempty"`
+}
+
+// RedisAction describes an action that authenticates to a Redis server and sends PING.
+// The password is redacted from the probe output.
+type RedisAction struct {
+	// Name or number of the port to access on the container.
+	// Number must be in the range 1 to 65535.
+	// Name must be an IANA_SVC_NAME.
+	Port intstr.IntOrString `json:"port"`
+	// Host name to connect to, defaults to the pod IP.
+	// +optional
+	Host string `json:"host,omitempty"`
+	// Username to authenticate as, for servers with ACL users.
+	// Defaults to the default user.
+	// +optional
+	Username string `json:"username,omitempty"`
+	// Password selects the password sent with AUTH. If it is not set, the probe does not authenticate.
+	// +optional
+	Password *ValueSource `json:"password,omitempty"`
+	// Role is the replication role the server must report to ROLE. One of master or replica.
+	// If it is not set, any role succeeds.
+	// +optional
+	Role string `json:"role,omitempty"`
+}
+
+// MongoDBAction describes an action that runs the hello command, or isMaster on older servers.
+// The reason of the probe shows the replication state of the server.
+type MongoDBAction struct {
+	// Name or number of the port to access on the container.
+	// Number must be in the range 1 to 65535.
+	// Name must be an IANA_SVC_NAME.
+	Port intstr.IntOrString `json:"port"`
+	// Host name to connect to, defaults to the pod IP.
+	// +optional
+	Host string `json:"host,omitempty"`
+	// PrimaryOnly fails the probe unless the server accepts writes: a primary, a standalone server or a mongos router.
+	// +optional
+	PrimaryOnly bool `json:"primaryOnly,omitempty"`
+}
+
+// HTTPScenarioAction describes an action made of HTTP requests sent in order with a shared cookie jar.
+// The paths, header values and bodies of the steps may refer to variables as ${name}: Variables,
+// and the values captured by previous steps. The steps run until one fails, the rest are skipped.
+// The reason of the probe is a tree showing the result, latency and reason of every step.
+// TLS and HTTPRedirects of the handler apply to every step.
+type HTTPScenarioAction struct {
+	// Name or number of the port to access on the container.
+	// Number must be in the range 1 to 65535.
+	// Name must be an IANA_SVC_NAME.
+	Port intstr.IntOrString `json:"port"`
+	// Host name to connect to, defaults to the pod IP.
+	// +optional
+	Host string `json:"host,omitempty"`
+	// Scheme to use for connecting to the host.
+	// Defaults to HTTP.
+	// +optional
+	Scheme core.URIScheme `json:"scheme,omitempty"`
+	// Variables are resolved when the probe runs, before the first step.
+	// Their values are redacted from the probe output.
+	// +optional
+	Variables []HTTPScenarioVariable `json:"variables,omitempty"`
+	// Steps are the requests to send, in order.
+	Steps []HTTPScenarioStep `json:"steps"`
+}
+
+// HTTPScenarioVariable describes a variable of an HTTP scenario whose value is resolved when the probe runs.
+type HTTPScenarioVariable struct {
+	// Name of the variable.
+	Name string `json:"name"`
+	// ValueFrom selects the value of the variable.
+	ValueFrom ValueSource `json:"valueFrom"`
+}
+
+// HTTPScenarioStep describes a request of an HTTP scenario.
+type HTTPScenarioStep struct {
+	// Name of the step, shown in the reason of the probe.
+	// Defaults to the method and path of the request.
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Method of the request.
+	// Defaults to GET.
+	// +optional
+	Method string `json:"method,omitempty"`
+	// Path to access on the HTTP server.
+	// Variables are escaped as a path segment before the "?" and as a query value after it.
+	// +optional
+	Path string `json:"path,omitempty"`
+	// Custom headers to set in the request. HTTP allows repeated headers.
+	// +optional
+	HTTPHeaders []core.HTTPHeader `json:"httpHeaders,omitempty"`
+	// Body of the request.
+	// +optional
+	Body string `json:"body,omitempty"`
+	// Assertions are checked against the response. Without them, status codes from 200 to 399 succeed.
+	// +optional
+	Assertions *HTTPAssertions `json:"assertions,omitempty"`
+	// Capture sets variables from the response, for the next steps.
+	// +optional
+	Capture []HTTPCapture `json:"capture,omitempty"`
+}
+
+// HTTPCapture describes a value of a response captured into a variable.
+// One and only one of Header and JSONPath should be set.
+type HTTPCapture struct {
+	// Name of the variable.
+	Name string `json:"name"`
+	// Header is the name of a response header whose first value is captured.
+	// +optional
+	Header string `json:"header,omitempty"`
+	// JSONPath is evaluated against the JSON response body, like in HTTPAssertions.
+	// +optional
+	JSONPath string `json:"jsonPath,omitempty"`
+	// Secret redacts the value from the probe output, e.g. for tokens.
+	// +optional
+	Secret bool `json:"secret,omitempty"`
+}
+
+// RetryBackoff describes the delay between the attempts of a probe.
+type RetryBackoff struct {
+	// Type is one of constant or exponential. Constant waits Delay between attempts.
+	// Exponential doubles the delay after each attempt, up to MaxDelay, and waits a random duration
+	// between half of it and all of it.
+	// Defaults to constant.
+	// +optional
+	Type string `json:"type,omitempty"`
+	// Delay before the first retry.
+	// Defaults to 1s.
+	// +optional
+	Delay *metav1.Duration `json:"delay,omitempty"`
+	// MaxDelay bounds the delays of the exponential type.
+	// Defaults to 30s.
+	// +optional
+	MaxDelay *metav1.Duration `json:"maxDelay,omitempty"`
+}
+
+// CompositeAction describes an action that runs nested probes and combines their results.
+// One and only one of AllOf, AnyOf and Sequence should be specified.
+// The reason of the probe is a tree showing the result, latency and reason of every nested probe.
+type CompositeAction struct {
+	// AllOf probes run concurrently. The result is the worst of their results.
+	// +optional
+	AllOf []Handler `json:"allOf,omitempty"`
+	// AnyOf probes run concurrently. The result is the best of their results.
+	// +optional
+	AnyOf []Handler `json:"anyOf,omitempty"`
+	// Sequence probes run in order, until one fails or returns an unknown result; the rest are skipped.
+	// The result is the worst of the results of the probes run. They share the timeout of the composite probe.
+	// +optional
+	Sequence []Handler `json:"sequence,omitempty"`
+}
+
+// TLSConfig describes how a probe verifies a TLS server and authenticates itself to it.
+type TLSConfig struct {
+	// CAFile is the path of a PEM encoded CA bundle used to verify the server certificate.
+	// Defaults to the system roots.
+	// +optional
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile is the path of a PEM encoded client certificate used for mutual TLS.
+	// +optional
+	CertFile string `json:"certFile,omitempty"`
+	// KeyFile is the path of the PEM encoded private key of CertFile.
+	// +optional
+	KeyFile string `json:"keyFile,omitempty"`
+	// ServerName overrides the host name sent as SNI and checked against the server certificate.
+	// Defaults to the probe host.
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+	// MinVersion is the minimum accepted TLS version. One of "1.0", "1.1", "1.2" or "1.3".
+	// Defaults to the Go default.
+	// +optional
+	MinVersion string `json:"minVersion,omitempty"`
+}
+
+// TLSCertAction describes an action that performs a TLS handshake and checks the served certificates.
+// If TLS.CAFile is set the chain must be signed by it, otherwise only expiry and host name are checked.
+type TLSCertAction struct {
+	// Name or number of the port to access on the container.
+	// Number must be in the range 1 to 65535.
+	// Name must be an IANA_SVC_NAME.
+	Port intstr.IntOrString `json:"port"`
+	// Host name to connect to, defaults to the pod IP.
+	// The certificate must be valid for it, unless TLS.ServerName overrides it.
+	// +optional
+	Host string `json:"host,omitempty"`
+	// WarnBefore is how long before a certificate of the chain expires the probe starts returning warning.
+	// Defaults to 30 days.
+	// +optional
+	WarnBefore *metav1.Duration `json:"warnBefore,omitempty"`
+}
+
+// GRPCAction describes an action that calls grpc.health.v1.Health/Check.
+type GRPCAction struct {
+	// Name or number of the port to access on the container.
+	// Number must be in the range 1 to 65535.
+	// Name must be an IANA_SVC_NAME.
+	Port intstr.IntOrString `json:"port"`
+	// Host name to connect to, defaults to the pod IP.
+	// +optional
+	Host string `json:"host,omitempty"`
+	// Service is the name of the service to check. The empty name checks the overall server health.
+	// +optional
+	Service string `json:"service,omitempty"`
+	// Scheme to use for connecting to the host. HTTP means plaintext HTTP/2 and HTTPS means TLS.
+	// Defaults to HTTP.
+	// +optional
+	Scheme core.URIScheme `json:"scheme,omitempty"`
+}
+
+// HTTPAssertions describes the checks done on the response of an HTTP probe.
+// Every check that is specified must pass for the probe to succeed.
+// Bodies are checked against the first 10KB of the response, the part that a probe reads.
+type HTTPAssertions struct {
+	// StatusCodes lists the accepted status codes. If set, it replaces the default 200-399 range:
+	// a listed code is a success, a redirect too, and any other code a failure.
+	// HTTPStatusResults takes precedence, the codes it matches keep the result it maps them to.
+	// +optional
+	StatusCodes []int `json:"statusCodes,omitempty"`
+	// BodyEquals is the exact expected body.
+	// +optional
+	BodyEquals *string `json:"bodyEquals,omitempty"`
+	// BodyContains is a string the body must contain.
+	// +optional
+	BodyContains string `json:"bodyContains,omitempty"`
+	// BodyRegex is a regular expression the body must match. An invalid one is an error of the probe.
+	// +optional
+	BodyRegex string `json:"bodyRegex,omitempty"`
+	// JSONPath lists values expected in a JSON body.
+	// +optional
+	JSONPath []JSONPathAssertion `json:"jsonPath,omitempty"`
+	// Headers lists headers expected in the response.
+	// +optional
+	Headers []HeaderAssertion `json:"headers,omitempty"`
+}
+
+// JSONPathAssertion expects the value found at Path in a JSON body to equal Value.
+type JSONPathAssertion struct {
+	// Path is a JSONPath expression made of child and index selectors, e.g. "$.items[0].status".
+	Path string `json:"path"`
+	// Value is compared to the string found at Path, or to the compact JSON encoding of any other value.
+	Value string `json:"value"`
+}
+
+// HeaderAssertion expects a response header to be present.
+type HeaderAssertion struct {
+	// Name of the header.
+	Name string `json:"name"`
+	// Value, if set, must equal one of the values of the header.
+	// +optional
+	Value string `json:"value,omitempty"`
+}
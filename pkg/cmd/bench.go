@@ -0,0 +1,390 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	api "kmodules.xyz/prober/api"
+	prober_v1 "kmodules.xyz/prober/api/v1"
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+	"stash.appscode.dev/prober-demo/pkg/probe"
+	execprobe "stash.appscode.dev/prober-demo/pkg/probe/exec"
+)
+
+type benchOptions struct {
+	filename    string
+	httpGet     string
+	tcpSocket   string
+	requests    int
+	duration    time.Duration
+	concurrency int
+	rate        float64
+	timeout     time.Duration
+	output      string
+}
+
+func NewCmdBench() *cobra.Command {
+	opt := benchOptions{}
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "measure the latency distribution of a probe",
+		Long: "Run a probe many times, at a set concurrency and rate, and report its latency percentiles, " +
+			"its error rate by failure code and its throughput. Probes run locally, like against run-client, " +
+			"so they cannot refer to named container ports. Exec probes run their command on the local machine.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// a duration alone runs as many probes as it allows.
+			if opt.duration > 0 && !cmd.Flags().Changed("requests") {
+				opt.requests = 0
+			}
+			if err := opt.validate(); err != nil {
+				return err
+			}
+			h, err := opt.handler()
+			if err != nil {
+				return err
+			}
+			var config *rest.Config
+			if kubeconfigPath := os.Getenv("KUBECONFIG"); kubeconfigPath != "" {
+				// only probes that read ConfigMaps or Secrets need it.
+				if config, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath); err != nil {
+					return fmt.Errorf("could not get Kubernetes config: %v", err)
+				}
+			}
+			pb := probe.NewProber(config)
+			// there is no container to exec into, so exec probes, also those in composite probes, run locally.
+			pb.Exec = execprobe.NewLocal(opt.timeout)
+			report, err := runBench(pb, h, opt)
+			if err != nil {
+				return err
+			}
+			if opt.output == outputJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(report)
+			}
+			report.writeText(os.Stdout)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&opt.filename, "filename", "f", "", "File of the probe handler to run, in YAML or JSON.")
+	cmd.Flags().StringVar(&opt.httpGet, "http-get", "", "URL to run an HTTP GET probe against, instead of --filename.")
+	cmd.Flags().StringVar(&opt.tcpSocket, "tcp-socket", "", "HOST:PORT to run a TCP socket probe against, instead of --filename.")
+	cmd.Flags().IntVarP(&opt.requests, "requests", "n", 100, "Number of probes to run. With --duration, the run stops at whichever comes first.")
+	cmd.Flags().DurationVarP(&opt.duration, "duration", "d", 0, "Time to run probes for, instead of a number of requests.")
+	cmd.Flags().IntVarP(&opt.concurrency, "concurrency", "c", 1, "Number of probes run at the same time.")
+	cmd.Flags().Float64Var(&opt.rate, "rate", 0, "Maximum number of probes started per second, across all workers. 0 means unlimited.")
+	cmd.Flags().DurationVar(&opt.timeout, "timeout", time.Second, "Timeout of each probe, like the timeoutSeconds of a Kubernetes probe.")
+	cmd.Flags().StringVarP(&opt.output, "output", "o", outputText, "Format of the report. One of text or json.")
+	return cmd
+}
+
+func (opt benchOptions) validate() error {
+	sources := 0
+	for _, s := range []string{opt.filename, opt.httpGet, opt.tcpSocket} {
+		if s != "" {
+			sources++
+		}
+	}
+	switch {
+	case sources != 1:
+		return fmt.Errorf("exactly one of --filename, --http-get or --tcp-socket must be set")
+	case opt.requests < 0:
+		return fmt.Errorf("invalid --requests %d, must not be negative", opt.requests)
+	case opt.requests == 0 && opt.duration <= 0:
+		return fmt.Errorf("either --requests or --duration must be positive")
+	case opt.concurrency < 1:
+		return fmt.Errorf("invalid --concurrency %d, must be at least 1", opt.concurrency)
+	case opt.rate < 0:
+		return fmt.Errorf("invalid --rate %v, must not be negative", opt.rate)
+	case opt.timeout <= 0:
+		return fmt.Errorf("invalid --timeout %v, must be positive", opt.timeout)
+	case opt.output != outputText && opt.output != outputJSON:
+		return fmt.Errorf("invalid output %q, must be one of %s or %s", opt.output, outputText, outputJSON)
+	}
+	return nil
+}
+
+// handler returns the probe handler given by the flags.
+func (opt benchOptions) handler() (*api_v1.Handler, error) {
+	switch {
+	case opt.httpGet != "":
+		u, err := url.Parse(opt.httpGet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --http-get: %v", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("invalid --http-get %q, the scheme must be http or https", opt.httpGet)
+		}
+		port := 80
+		if u.Scheme == "https" {
+			port = 443
+		}
+		if u.Port() != "" {
+			if port, err = strconv.Atoi(u.Port()); err != nil {
+				return nil, fmt.Errorf("invalid --http-get port %q", u.Port())
+			}
+		}
+		return &api_v1.Handler{Handler: prober_v1.Handler{
+			HTTPGet: &v1.HTTPGetAction{
+				Path:   u.RequestURI(),
+				Port:   intstr.FromInt(port),
+				Host:   u.Hostname(),
+				Scheme: v1.URIScheme(strings.ToUpper(u.Scheme)),
+			},
+		}}, nil
+	case opt.tcpSocket != "":
+		host, p, err := net.SplitHostPort(opt.tcpSocket)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --tcp-socket: %v", err)
+		}
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --tcp-socket port %q", p)
+		}
+		return &api_v1.Handler{Handler: prober_v1.Handler{
+			TCPSocket: &v1.TCPSocketAction{
+				Port: intstr.FromInt(port),
+				Host: host,
+			},
+		}}, nil
+	}
+	data, err := ioutil.ReadFile(opt.filename)
+	if err != nil {
+		return nil, err
+	}
+	var h api_v1.Handler
+	if err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), len(data)).Decode(&h); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", opt.filename, err)
+	}
+	return &h, nil
+}
+
+// benchReport is the report of prober bench. Durations are in seconds.
+type benchReport struct {
+	Action          string             `json:"action"`
+	Requests        int                `json:"requests"`
+	Concurrency     int                `json:"concurrency"`
+	Rate            float64            `json:"rate,omitempty"`
+	TimeoutSeconds  float64            `json:"timeoutSeconds"`
+	DurationSeconds float64            `json:"durationSeconds"`
+	Throughput      float64            `json:"throughput"`
+	Latency         benchLatency       `json:"latency"`
+	Results         map[api.Result]int `json:"results"`
+	Errors          int                `json:"errors"`
+	ErrorRate       float64            `json:"errorRate"`
+	ErrorsByCode    []benchErrors      `json:"errorsByCode,omitempty"`
+}
+
+// benchLatency is the latency distribution of the probes, retries included.
+type benchLatency struct {
+	MinSeconds  float64 `json:"minSeconds"`
+	P50Seconds  float64 `json:"p50Seconds"`
+	P90Seconds  float64 `json:"p90Seconds"`
+	P99Seconds  float64 `json:"p99Seconds"`
+	MaxSeconds  float64 `json:"maxSeconds"`
+	MeanSeconds float64 `json:"meanSeconds"`
+}
+
+// benchErrors counts the failed probes of a failure code, ProbeFailed for the probes that have none.
+type benchErrors struct {
+	Code  string  `json:"code"`
+	Count int     `json:"count"`
+	Rate  float64 `json:"rate"`
+}
+
+// runBench runs h as set by opt and reports the results. It stops at the first probe that cannot run.
+func runBench(pb *probe.Prober, h *api_v1.Handler, opt benchOptions) (*benchReport, error) {
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		results   = map[api.Result]int{}
+		codes     = map[string]int{}
+		runErr    error
+		wg        sync.WaitGroup
+		stopOnce  sync.Once
+	)
+	stop := make(chan struct{})
+	stopAll := func() { stopOnce.Do(func() { close(stop) }) }
+
+	// jobs hands out the probes to run, at the set rate, until the requests or the duration are done.
+	jobs := make(chan struct{})
+	go func() {
+		defer close(jobs)
+		var deadline <-chan time.Time
+		if opt.duration > 0 {
+			timer := time.NewTimer(opt.duration)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+		var tick <-chan time.Time
+		if opt.rate > 0 {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / opt.rate))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for i := 0; opt.requests == 0 || i < opt.requests; i++ {
+			// the first probe starts right away, the next ones wait for their tick.
+			if tick != nil && i > 0 {
+				select {
+				case <-tick:
+				case <-deadline:
+					return
+				case <-stop:
+					return
+				}
+			}
+			select {
+			case jobs <- struct{}{}:
+			case <-deadline:
+				return
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	for w := 0; w < opt.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				report, err := pb.Run(h, &v1.Pod{}, v1.PodStatus{}, v1.Container{}, opt.timeout)
+				mu.Lock()
+				if err != nil {
+					if runErr == nil {
+						runErr = err
+					}
+					mu.Unlock()
+					stopAll()
+					return
+				}
+				latencies = append(latencies, report.Duration)
+				results[report.Result]++
+				if isBenchError(report.Result) {
+					codes[failureReason(report)]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	stopAll()
+	if runErr != nil {
+		return nil, runErr
+	}
+
+	report := &benchReport{
+		Action:          probe.Describe(h),
+		Requests:        len(latencies),
+		Concurrency:     opt.concurrency,
+		Rate:            opt.rate,
+		TimeoutSeconds:  opt.timeout.Seconds(),
+		DurationSeconds: elapsed.Seconds(),
+		Throughput:      float64(len(latencies)) / elapsed.Seconds(),
+		Latency:         newBenchLatency(latencies),
+		Results:         results,
+	}
+	for code, count := range codes {
+		report.Errors += count
+		report.ErrorsByCode = append(report.ErrorsByCode, benchErrors{
+			Code:  code,
+			Count: count,
+			Rate:  float64(count) / float64(report.Requests),
+		})
+	}
+	if report.Requests > 0 {
+		report.ErrorRate = float64(report.Errors) / float64(report.Requests)
+	}
+	sort.Slice(report.ErrorsByCode, func(i, j int) bool {
+		if report.ErrorsByCode[i].Count != report.ErrorsByCode[j].Count {
+			return report.ErrorsByCode[i].Count > report.ErrorsByCode[j].Count
+		}
+		return report.ErrorsByCode[i].Code < report.ErrorsByCode[j].Code
+	})
+	return report, nil
+}
+
+// isBenchError returns true if result counts as an error. Warnings do not, the probe got an answer.
+func isBenchError(result api.Result) bool {
+	return result == api.Failure || result == api.Unknown
+}
+
+func newBenchLatency(latencies []time.Duration) benchLatency {
+	if len(latencies) == 0 {
+		return benchLatency{}
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var sum time.Duration
+	for _, l := range sorted {
+		sum += l
+	}
+	return benchLatency{
+		MinSeconds:  sorted[0].Seconds(),
+		P50Seconds:  percentile(sorted, 0.50).Seconds(),
+		P90Seconds:  percentile(sorted, 0.90).Seconds(),
+		P99Seconds:  percentile(sorted, 0.99).Seconds(),
+		MaxSeconds:  sorted[len(sorted)-1].Seconds(),
+		MeanSeconds: (sum / time.Duration(len(sorted))).Seconds(),
+	}
+}
+
+// percentile returns the p-th percentile of sorted by the nearest rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+func (r *benchReport) writeText(w io.Writer) {
+	rate := "unlimited"
+	if r.Rate > 0 {
+		rate = fmt.Sprintf("%v/s", r.Rate)
+	}
+	fmt.Fprintf(w, "Probe: %s\n", r.Action)
+	fmt.Fprintf(w, "Requests: %d in %v, concurrency %d, rate %s, timeout %v\n",
+		r.Requests, seconds(r.DurationSeconds).Round(time.Millisecond), r.Concurrency, rate, seconds(r.TimeoutSeconds))
+	fmt.Fprintf(w, "Throughput: %.2f requests/s\n", r.Throughput)
+	fmt.Fprintf(w, "Latency: min %v, p50 %v, p90 %v, p99 %v, max %v, mean %v\n",
+		seconds(r.Latency.MinSeconds), seconds(r.Latency.P50Seconds), seconds(r.Latency.P90Seconds),
+		seconds(r.Latency.P99Seconds), seconds(r.Latency.MaxSeconds), seconds(r.Latency.MeanSeconds))
+	var results []string
+	for _, result := range []api.Result{api.Success, api.Warning, api.Failure, api.Unknown} {
+		if n := r.Results[result]; n > 0 {
+			results = append(results, fmt.Sprintf("%s %d", result, n))
+		}
+	}
+	fmt.Fprintf(w, "Results: %s\n", strings.Join(results, ", "))
+	fmt.Fprintf(w, "Errors: %d (%.2f%%)\n", r.Errors, 100*r.ErrorRate)
+	for _, e := range r.ErrorsByCode {
+		fmt.Fprintf(w, "  %s: %d (%.2f%%)\n", e.Code, e.Count, 100*e.Rate)
+	}
+}
+
+// seconds converts seconds of the report back to a duration rounded to µs, for display.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Microsecond)
+}
@@ -0,0 +1,59 @@
+//go:build linux
+// +build linux
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenBacklogFull listens on addr with the smallest accept queue and fills it, so that the
+// kernel drops new connection attempts like it does for an overloaded server.
+func listenBacklogFull(addr string) (net.Listener, error) {
+	tcpAddr, err := net.ResolveTCPAddr("tcp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, syscall.IPPROTO_TCP)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	sa := &syscall.SockaddrInet4{Port: tcpAddr.Port}
+	if ip := tcpAddr.IP.To4(); ip != nil {
+		copy(sa.Addr[:], ip)
+	}
+	if err = syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err == nil {
+		if err = syscall.Bind(fd, sa); err == nil {
+			// a backlog of 0 lets a single connection wait to be accepted.
+			err = syscall.Listen(fd, 0)
+		}
+	}
+	if err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("listen %s: %v", addr, err)
+	}
+
+	f := os.NewFile(uintptr(fd), "refuse-"+addr)
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, err
+	}
+	return &fullListener{Listener: l, conns: fillBacklog(l)}, nil
+}
+
+// fullListener closes the connections filling its backlog along with itself.
+type fullListener struct {
+	net.Listener
+	conns []net.Conn
+}
+
+func (l *fullListener) Close() error {
+	for _, conn := range l.conns {
+		conn.Close()
+	}
+	return l.Listener.Close()
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package cmd
+
+import (
+	"net"
+)
+
+// listenBacklogFull listens on addr and fills the accept queue of the listener. The queue can't be
+// made small portably, so it may take long to fill, and connections succeed until it is.
+func listenBacklogFull(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	fillBacklog(l)
+	return l, nil
+}
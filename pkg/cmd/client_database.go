@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+
+	"stash.appscode.dev/prober-demo/pkg/mysql"
+	"stash.appscode.dev/prober-demo/pkg/postgres"
+)
+
+// databasePasswordEnv is the environment variable holding the password of the database user of run-client.
+const databasePasswordEnv = "DB_PASSWORD"
+
+// demoPostgresDatabases are the databases of the PostgreSQL server of run-client, one per state a probe can check.
+var demoPostgresDatabases = map[string]postgres.Database{
+	"postgres":    {},
+	"prober-demo": {},
+	"replica":     {InRecovery: true},
+	"readonly":    {ReadOnly: true},
+	"starting-up": {StartingUp: true},
+	"slow":        {Hang: true},
+}
+
+// demoMySQLDatabases are the databases of the MySQL server of run-client, one per state a probe can check.
+var demoMySQLDatabases = map[string]mysql.Database{
+	"prober-demo": {},
+	"readonly":    {ReadOnly: true},
+	"busy":        {Busy: true},
+	"slow":        {Hang: true},
+}
+
+// runPostgresServer serves the fake PostgreSQL server on addr until done is closed.
+func runPostgresServer(wg *sync.WaitGroup, done <-chan struct{}, addr, user, auth string, out io.Writer) {
+	defer wg.Done()
+	srv := &postgres.Server{
+		Users:     map[string]string{user: os.Getenv(databasePasswordEnv)},
+		Auth:      auth,
+		Databases: demoPostgresDatabases,
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("postgres server listener error:", err)
+	}
+	go srv.Serve(listener)
+	fmt.Fprintf(out, "PostgreSQL Server Started on %s with %s authentication for user %q\n", addr, auth, user)
+
+	<-done
+	listener.Close()
+	log.Print("PostgreSQL Server Stopped")
+}
+
+// runMySQLServer serves the fake MySQL server on addr until done is closed.
+func runMySQLServer(wg *sync.WaitGroup, done <-chan struct{}, addr, user, auth string, cacheMiss bool, out io.Writer) {
+	defer wg.Done()
+	srv := &mysql.Server{
+		Users:     map[string]string{user: os.Getenv(databasePasswordEnv)},
+		Auth:      auth,
+		Databases: demoMySQLDatabases,
+		CacheMiss: cacheMiss,
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("mysql server listener error:", err)
+	}
+	go srv.Serve(listener)
+	if cacheMiss {
+		auth += " (cache miss)"
+	}
+	fmt.Fprintf(out, "MySQL Server Started on %s with %s authentication for user %q\n", addr, auth, user)
+
+	<-done
+	listener.Close()
+	log.Print("MySQL Server Stopped")
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"stash.appscode.dev/prober-demo/pkg/dns"
+)
+
+// demoZone is the zone served by the DNS server of run-client.
+const demoZone = "prober-demo.test."
+
+// slowDNSDelay delays the answers for slow.prober-demo.test, for the latency thresholds of DNS probes.
+const slowDNSDelay = 300 * time.Millisecond
+
+// newDemoZone returns a zone with one name per kind of answer a DNS probe can check.
+func newDemoZone() *dns.Zone {
+	return dns.NewZone(demoZone, []dns.Record{
+		{Name: demoZone, Type: dns.TypeA, TTL: 60, IP: net.ParseIP("127.0.0.1")},
+		{Name: demoZone, Type: dns.TypeAAAA, TTL: 60, IP: net.ParseIP("::1")},
+		{Name: "multi." + demoZone, Type: dns.TypeA, TTL: 60, IP: net.ParseIP("127.0.0.1")},
+		{Name: "multi." + demoZone, Type: dns.TypeA, TTL: 60, IP: net.ParseIP("127.0.0.2")},
+		{Name: "slow." + demoZone, Type: dns.TypeA, TTL: 60, IP: net.ParseIP("127.0.0.1")},
+		{Name: "www." + demoZone, Type: dns.TypeCNAME, TTL: 60, Target: demoZone},
+		{Name: "external." + demoZone, Type: dns.TypeCNAME, TTL: 60, Target: "kubernetes.io."},
+		{Name: "_http._tcp." + demoZone, Type: dns.TypeSRV, TTL: 60, Priority: 10, Weight: 5, Port: 8080, Target: demoZone},
+		{Name: "_https._tcp." + demoZone, Type: dns.TypeSRV, TTL: 60, Priority: 10, Weight: 5, Port: 8443, Target: demoZone},
+	})
+}
+
+// runDNSServer serves the demo zone over UDP and TCP on addr until done is closed.
+func runDNSServer(wg *sync.WaitGroup, done <-chan struct{}, addr string, out io.Writer) {
+	defer wg.Done()
+	srv := &dns.Server{
+		Zone:   newDemoZone(),
+		Delays: map[string]time.Duration{"slow." + demoZone: slowDNSDelay},
+	}
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		log.Fatal("dns server listener error:", err)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("dns server listener error:", err)
+	}
+	go srv.ServeUDP(conn)
+	go srv.ServeTCP(listener)
+	fmt.Fprintf(out, "DNS Server Started on %s for zone %s\n", addr, demoZone)
+
+	<-done
+	conn.Close()
+	listener.Close()
+	log.Print("DNS Server Stopped")
+}
@@ -0,0 +1,299 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	prober_http "kmodules.xyz/prober/probe/http"
+)
+
+// Limits of the diagnostic endpoints, so that a probe experiment can't hold the client busy.
+const (
+	maxDelay     = 30 * time.Second
+	maxRedirects = 100
+	maxFailures  = 100
+	maxBytes     = 1 << 20 // 1MB
+)
+
+// addDiagnosticRoutes registers httpbin-like endpoints that let probes be tried against
+// any status code, latency, redirect chain, header or body.
+func addDiagnosticRoutes(router *mux.Router) {
+	router.HandleFunc("/status/{code}", statusHandler)
+	router.HandleFunc("/delay/{seconds}", delayHandler)
+	router.HandleFunc("/flaky/{n}", flakyHandler).Methods("GET", "HEAD")
+	router.HandleFunc("/redirect/{n}", redirectHandler).Methods("GET", "HEAD")
+	router.HandleFunc("/redirect-to", redirectToHandler)
+	router.HandleFunc("/absolute-redirect", absoluteRedirectHandler).Methods("GET", "HEAD")
+	router.HandleFunc("/headers", headersHandler).Methods("GET", "HEAD")
+	router.HandleFunc("/bytes/{n}", bytesHandler).Methods("GET", "HEAD")
+	router.HandleFunc("/basic-auth/{user}/{pass}", basicAuthHandler).Methods("GET", "HEAD")
+	router.HandleFunc("/gzip", gzipHandler).Methods("GET", "HEAD")
+	router.HandleFunc("/anything", anythingHandler)
+	router.HandleFunc("/anything/{path:.*}", anythingHandler)
+}
+
+// statusHandler replies with the status code of the path. Redirect codes point to /success.
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	code, err := strconv.Atoi(mux.Vars(r)["code"])
+	if err != nil || code < 100 || code > 599 {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid status code", fmt.Sprintf("%q is not a valid HTTP status code", mux.Vars(r)["code"]))
+		return
+	}
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		w.Header().Set("Location", "/success")
+	case http.StatusUnauthorized:
+		w.Header().Set("WWW-Authenticate", `Basic realm="prober-demo"`)
+	}
+	w.WriteHeader(code)
+}
+
+// delayHandler replies with the request as JSON after the given number of seconds, at most 30.
+func delayHandler(w http.ResponseWriter, r *http.Request) {
+	seconds, err := strconv.ParseFloat(mux.Vars(r)["seconds"], 64)
+	if err != nil || seconds < 0 {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid delay", fmt.Sprintf("%q is not a positive number of seconds", mux.Vars(r)["seconds"]))
+		return
+	}
+	delay := time.Duration(seconds * float64(time.Second))
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	select {
+	case <-time.After(delay):
+	case <-r.Context().Done():
+		// the prober gave up, nobody reads the reply.
+		return
+	}
+	writeJSON(w, http.StatusOK, newRequestInfo(r))
+}
+
+// flakyCounts counts the requests of each /flaky/{n} path.
+var flakyCounts = struct {
+	sync.Mutex
+	requests map[int]int
+}{requests: map[int]int{}}
+
+// flakyHandler replies 503 to n requests in a row, then 200 to the next one, for trying probe retries.
+func flakyHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.Atoi(mux.Vars(r)["n"])
+	if err != nil || n < 0 || n > maxFailures {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid failure count", fmt.Sprintf("%q is not a number between 0 and %d", mux.Vars(r)["n"], maxFailures))
+		return
+	}
+	flakyCounts.Lock()
+	count := flakyCounts.requests[n] % (n + 1)
+	flakyCounts.requests[n]++
+	flakyCounts.Unlock()
+	if count < n {
+		w.Header().Set("Retry-After", "1")
+		writeProblem(w, r, http.StatusServiceUnavailable, "Flaky failure", fmt.Sprintf("failure %d of %d, the next request succeeds after them", count+1, n))
+		return
+	}
+	writeJSON(w, http.StatusOK, newRequestInfo(r))
+}
+
+// redirectHandler redirects n times with relative redirects, then to /success.
+func redirectHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.Atoi(mux.Vars(r)["n"])
+	if err != nil || n < 1 || n > maxRedirects {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid redirect count", fmt.Sprintf("%q is not a number between 1 and %d", mux.Vars(r)["n"], maxRedirects))
+		return
+	}
+	location := "/success"
+	if n > 1 {
+		location = "/redirect/" + strconv.Itoa(n-1)
+	}
+	w.Header().Set("Location", location)
+	w.WriteHeader(http.StatusFound)
+}
+
+// redirectToHandler redirects to the url query parameter, with the status_code parameter or 302.
+func redirectToHandler(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("url")
+	if target == "" {
+		writeProblem(w, r, http.StatusBadRequest, "Missing url", "the url query parameter is required")
+		return
+	}
+	if _, err := url.Parse(target); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid url", err.Error())
+		return
+	}
+	code := http.StatusFound
+	if s := r.URL.Query().Get("status_code"); s != "" {
+		var err error
+		if code, err = strconv.Atoi(s); err != nil || code < 300 || code > 399 {
+			writeProblem(w, r, http.StatusBadRequest, "Invalid status code", fmt.Sprintf("%q is not a redirect status code", s))
+			return
+		}
+	}
+	w.Header().Set("Location", target)
+	w.WriteHeader(code)
+}
+
+// absoluteRedirectHandler redirects to /success of another host serving the same port,
+// 127.0.0.1 when requested as localhost and localhost otherwise, or the host query parameter.
+// Probes that don't follow non-local redirects get a Warning from it.
+func absoluteRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host, port = r.Host, ""
+	}
+	target := r.URL.Query().Get("host")
+	if target == "" {
+		target = "localhost"
+		if host == "localhost" {
+			target = "127.0.0.1"
+		}
+	}
+	if port != "" {
+		target = net.JoinHostPort(target, port)
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	w.Header().Set("Location", (&url.URL{Scheme: scheme, Host: target, Path: "/success"}).String())
+	w.WriteHeader(http.StatusFound)
+}
+
+// headersHandler replies with the request headers as JSON.
+func headersHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]http.Header{"headers": requestHeaders(r)})
+}
+
+// bytesHandler replies with n random bytes, at most 1MB. The seed query parameter makes them reproducible.
+func bytesHandler(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.Atoi(mux.Vars(r)["n"])
+	if err != nil || n < 0 || n > maxBytes {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid byte count", fmt.Sprintf("%q is not a number between 0 and %d", mux.Vars(r)["n"], maxBytes))
+		return
+	}
+	seed := time.Now().UnixNano()
+	if s := r.URL.Query().Get("seed"); s != "" {
+		if seed, err = strconv.ParseInt(s, 10, 64); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, "Invalid seed", err.Error())
+			return
+		}
+	}
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	w.Header().Set(prober_http.ContentType, "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(n))
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(data); err != nil {
+		log.Println("failed to write response:", err)
+	}
+}
+
+// basicAuthHandler accepts requests authenticated with the user and password of the path.
+func basicAuthHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	user, password, ok := r.BasicAuth()
+	if !ok || user != vars["user"] || password != vars["pass"] {
+		w.Header().Set("WWW-Authenticate", `Basic realm="prober-demo"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"authenticated": true, "user": user})
+}
+
+// gzipHandler replies with the request as gzip encoded JSON.
+func gzipHandler(w http.ResponseWriter, r *http.Request) {
+	info := newRequestInfo(r)
+	info.Gzipped = true
+	w.Header().Set(prober_http.ContentType, prober_http.ContentJson)
+	w.Header().Set("Content-Encoding", "gzip")
+	w.WriteHeader(http.StatusOK)
+	gz := gzip.NewWriter(w)
+	if err := json.NewEncoder(gz).Encode(info); err != nil {
+		log.Println("failed to write response:", err)
+	}
+	if err := gz.Close(); err != nil {
+		log.Println("failed to write response:", err)
+	}
+}
+
+// anythingHandler replies with the request, including its body, as JSON.
+func anythingHandler(w http.ResponseWriter, r *http.Request) {
+	info := newRequestInfo(r)
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	info.Data = string(body)
+	var v interface{}
+	if json.Unmarshal(body, &v) == nil {
+		info.JSON = v
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get(prober_http.ContentType)); mediaType == prober_http.ContentUrlEncodedForm {
+		if form, err := url.ParseQuery(info.Data); err == nil {
+			info.Form = form
+		}
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// requestInfo is the JSON description of a request replied by the diagnostic endpoints.
+type requestInfo struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Path       string      `json:"path"`
+	Args       url.Values  `json:"args"`
+	Headers    http.Header `json:"headers"`
+	RemoteAddr string      `json:"remoteAddr"`
+	Data       string      `json:"data,omitempty"`
+	Form       url.Values  `json:"form,omitempty"`
+	JSON       interface{} `json:"json,omitempty"`
+	Gzipped    bool        `json:"gzipped,omitempty"`
+}
+
+func newRequestInfo(r *http.Request) *requestInfo {
+	u := *r.URL
+	u.Host = r.Host
+	u.Scheme = "http"
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	return &requestInfo{
+		Method:     r.Method,
+		URL:        u.String(),
+		Path:       r.URL.Path,
+		Args:       r.URL.Query(),
+		Headers:    requestHeaders(r),
+		RemoteAddr: r.RemoteAddr,
+	}
+}
+
+// requestHeaders returns the headers of r, with Host which net/http moves out of them.
+func requestHeaders(r *http.Request) http.Header {
+	headers := make(http.Header, len(r.Header)+1)
+	for k, v := range r.Header {
+		headers[k] = v
+	}
+	headers.Set("Host", r.Host)
+	return headers
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set(prober_http.ContentType, prober_http.ContentJson)
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Println("failed to write response:", err)
+	}
+}
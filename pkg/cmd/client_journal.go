@@ -0,0 +1,364 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// journalPath is where the journal is served, requests to it are not recorded.
+	journalPath = "/__requests"
+	// maxJournalEntries is the number of requests kept, older ones are dropped.
+	maxJournalEntries = 1000
+	// maxJournalBody is the part of a body kept in the journal.
+	maxJournalBody = 64 << 10 // 64KB
+)
+
+// Protocols of journal entries.
+const (
+	protocolHTTP = "http"
+	protocolTCP  = "tcp"
+	protocolUDP  = "udp"
+)
+
+// requestJournal records the requests received by every server of run-client.
+var requestJournal = newJournal(maxJournalEntries)
+
+// journalEntry is a request received by run-client. TCP and UDP entries only have a body.
+type journalEntry struct {
+	ID         int64       `json:"id"`
+	Time       time.Time   `json:"time"`
+	Protocol   string      `json:"protocol"`
+	Listener   string      `json:"listener"`
+	RemoteAddr string      `json:"remoteAddr"`
+	Method     string      `json:"method,omitempty"`
+	Path       string      `json:"path,omitempty"`
+	Query      string      `json:"query,omitempty"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	// BodyEncoding is base64 when the body is not valid UTF-8.
+	BodyEncoding  string `json:"bodyEncoding,omitempty"`
+	BodyTruncated bool   `json:"bodyTruncated,omitempty"`
+}
+
+func (e *journalEntry) setBody(body []byte, truncated bool) {
+	if utf8.Valid(body) {
+		e.Body = string(body)
+	} else {
+		e.Body, e.BodyEncoding = base64.StdEncoding.EncodeToString(body), "base64"
+	}
+	e.BodyTruncated = truncated
+}
+
+// journal is a bounded, in-memory list of journalEntry.
+type journal struct {
+	mu      sync.Mutex
+	entries []journalEntry
+	max     int
+	lastID  int64
+}
+
+func newJournal(max int) *journal {
+	return &journal{max: max}
+}
+
+func (j *journal) add(e journalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.lastID++
+	e.ID = j.lastID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if len(j.entries) == j.max {
+		j.entries = append(j.entries[:0], j.entries[1:]...)
+	}
+	j.entries = append(j.entries, e)
+}
+
+// recordTCP adds a payload received on a TCP connection.
+func (j *journal) recordTCP(conn net.Conn, payload []byte) {
+	j.recordPayload(protocolTCP, conn.LocalAddr(), conn.RemoteAddr(), payload)
+}
+
+// recordPayload adds a payload received by a listener of the given protocol.
+func (j *journal) recordPayload(protocol string, local, remote net.Addr, payload []byte) {
+	e := journalEntry{
+		Protocol:   protocol,
+		Listener:   local.String(),
+		RemoteAddr: remote.String(),
+	}
+	if len(payload) > maxJournalBody {
+		e.setBody(payload[:maxJournalBody], true)
+	} else {
+		e.setBody(payload, false)
+	}
+	j.add(e)
+}
+
+func (j *journal) find(f journalFilter) []journalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := []journalEntry{}
+	for i := range j.entries {
+		if f.matches(&j.entries[i]) {
+			out = append(out, j.entries[i])
+		}
+	}
+	return out
+}
+
+func (j *journal) reset() {
+	j.mu.Lock()
+	j.entries = nil
+	j.mu.Unlock()
+}
+
+// Wrap returns a handler that records the requests served by next, except the ones to the journal itself.
+// The body is read ahead and handed on to next untouched.
+func (j *journal) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, journalPath) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		e := journalEntry{
+			Protocol:   protocolHTTP,
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Query:      r.URL.RawQuery,
+			Headers:    requestHeaders(r),
+		}
+		if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+			e.Listener = addr.String()
+		}
+		if r.Body != nil {
+			body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxJournalBody+1))
+			if err != nil {
+				writeProblem(w, r, http.StatusBadRequest, "Invalid request body", err.Error())
+				return
+			}
+			truncated := len(body) > maxJournalBody
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			if truncated {
+				body = body[:maxJournalBody]
+			}
+			e.setBody(body, truncated)
+		}
+		j.add(e)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// addJournalRoutes registers the endpoints that list, verify and clear the journal.
+func addJournalRoutes(router *mux.Router, j *journal) {
+	router.HandleFunc(journalPath, j.listHandler).Methods("GET")
+	router.HandleFunc(journalPath, j.resetHandler).Methods("DELETE")
+	router.HandleFunc(journalPath+"/verify", j.verifyHandler).Methods("GET")
+}
+
+// listHandler replies with the entries matching the filter of the query, e.g.
+// "curl 'localhost:8080/__requests?path=/post-demo&header=Content-Type:application/json'".
+func (j *journal) listHandler(w http.ResponseWriter, r *http.Request) {
+	f, err := parseJournalFilter(r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid filter", err.Error())
+		return
+	}
+	entries := j.find(f)
+	if f.limit > 0 && len(entries) > f.limit {
+		entries = entries[len(entries)-f.limit:]
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"count": len(entries), "requests": entries})
+}
+
+func (j *journal) resetHandler(w http.ResponseWriter, r *http.Request) {
+	j.reset()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifyHandler checks how many entries match the filter of the query. The expectation is set by
+// count, atLeast or atMost, and defaults to atLeast=1. It replies 200 if it is met, 417 otherwise, e.g.
+// "curl 'localhost:8080/__requests/verify?path=/post-demo&header=X-Demo&count=2'".
+func (j *journal) verifyHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	f, err := parseJournalFilter(query)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid filter", err.Error())
+		return
+	}
+	min, max := 1, -1
+	for _, name := range []string{"atMost", "atLeast", "count"} {
+		s := query.Get(name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			writeProblem(w, r, http.StatusBadRequest, "Invalid expectation", fmt.Sprintf("%s=%q is not a positive number", name, s))
+			return
+		}
+		switch name {
+		case "atMost":
+			min, max = 0, n
+		case "atLeast":
+			min = n
+		case "count":
+			min, max = n, n
+		}
+	}
+
+	matched := len(j.find(f))
+	expected := fmt.Sprintf("at least %d", min)
+	switch {
+	case min == max:
+		expected = strconv.Itoa(min)
+	case max >= 0:
+		expected = fmt.Sprintf("between %d and %d", min, max)
+	}
+	if matched < min || (max >= 0 && matched > max) {
+		writeProblem(w, r, http.StatusExpectationFailed, "Verification failed",
+			fmt.Sprintf("expected %s to be received %s times, was received %d times", f, expected, matched))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"matched": matched, "expected": expected})
+}
+
+// journalFilter selects journal entries. Every set field must match.
+type journalFilter struct {
+	protocol     string
+	method       string
+	path         string
+	pathPrefix   string
+	headers      []headerMatch
+	bodyContains string
+	remoteAddr   string
+	sinceID      int64
+	limit        int
+}
+
+// headerMatch expects a header, with the given value if it is not empty.
+type headerMatch struct {
+	name  string
+	value string
+}
+
+// parseJournalFilter reads a filter from the query parameters protocol, method, path, pathPrefix,
+// header (Name or Name:Value, repeatable), bodyContains, remoteAddr, since (an entry ID) and limit.
+func parseJournalFilter(query url.Values) (journalFilter, error) {
+	f := journalFilter{
+		protocol:     query.Get("protocol"),
+		method:       strings.ToUpper(query.Get("method")),
+		path:         query.Get("path"),
+		pathPrefix:   query.Get("pathPrefix"),
+		bodyContains: query.Get("bodyContains"),
+		remoteAddr:   query.Get("remoteAddr"),
+	}
+	for _, h := range query["header"] {
+		parts := strings.SplitN(h, ":", 2)
+		m := headerMatch{name: strings.TrimSpace(parts[0])}
+		if m.name == "" {
+			return f, fmt.Errorf("header=%q has no name", h)
+		}
+		if len(parts) == 2 {
+			m.value = strings.TrimSpace(parts[1])
+		}
+		f.headers = append(f.headers, m)
+	}
+	var err error
+	if s := query.Get("since"); s != "" {
+		if f.sinceID, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return f, fmt.Errorf("since=%q is not an entry ID", s)
+		}
+	}
+	if s := query.Get("limit"); s != "" {
+		if f.limit, err = strconv.Atoi(s); err != nil || f.limit < 0 {
+			return f, fmt.Errorf("limit=%q is not a positive number", s)
+		}
+	}
+	return f, nil
+}
+
+func (f journalFilter) matches(e *journalEntry) bool {
+	if e.ID <= f.sinceID {
+		return false
+	}
+	if f.protocol != "" && e.Protocol != f.protocol ||
+		f.method != "" && e.Method != f.method ||
+		f.path != "" && e.Path != f.path ||
+		f.pathPrefix != "" && !strings.HasPrefix(e.Path, f.pathPrefix) ||
+		f.remoteAddr != "" && !strings.HasPrefix(e.RemoteAddr, f.remoteAddr) {
+		return false
+	}
+	if f.bodyContains != "" && (e.BodyEncoding != "" || !strings.Contains(e.Body, f.bodyContains)) {
+		return false
+	}
+	for _, h := range f.headers {
+		values, ok := e.Headers[http.CanonicalHeaderKey(h.name)]
+		if !ok || h.value != "" && !containsValue(values, h.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes the filter for verification failures.
+func (f journalFilter) String() string {
+	var parts []string
+	add := func(format string, v interface{}) {
+		parts = append(parts, fmt.Sprintf(format, v))
+	}
+	if f.method != "" {
+		add("%s", f.method)
+	}
+	switch {
+	case f.path != "":
+		add("%s", f.path)
+	case f.pathPrefix != "":
+		add("%s*", f.pathPrefix)
+	case f.protocol != "":
+		add("%s payloads", f.protocol)
+	default:
+		add("%s", "requests")
+	}
+	for _, h := range f.headers {
+		if h.value != "" {
+			add("with header %s", h.name+": "+h.value)
+		} else {
+			add("with header %s", h.name)
+		}
+	}
+	if f.bodyContains != "" {
+		add("with body containing %q", f.bodyContains)
+	}
+	if f.remoteAddr != "" {
+		add("from %s", f.remoteAddr)
+	}
+	return strings.Join(parts, " ")
+}
+
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+
+	"stash.appscode.dev/prober-demo/pkg/mongo"
+)
+
+// Behaviors of the MongoDB listeners of run-client.
+const (
+	// mongodbPrimary is the primary of the demo replica set.
+	mongodbPrimary = "primary"
+	// mongodbSecondary is a secondary of the demo replica set.
+	mongodbSecondary = "secondary"
+	// mongodbLegacy is a standalone server too old to know hello, only isMaster.
+	mongodbLegacy = "legacy"
+)
+
+// demoReplicaSet is the name of the replica set of the primary and secondary listeners.
+const demoReplicaSet = "rs0"
+
+// defaultMongoDBListeners start one listener per behavior.
+var defaultMongoDBListeners = []string{
+	mongodbPrimary + "=:27017",
+	mongodbSecondary + "=:27018",
+	mongodbLegacy + "=:27019",
+}
+
+// newMongoDBServers parses listener specs of the form MODE=ADDR, e.g. "secondary=:27018".
+func newMongoDBServers(specs []string) (map[string]*mongo.Server, error) {
+	servers := map[string]*mongo.Server{}
+	var primary string
+	var hosts []string
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid MongoDB listener %q, expected MODE=ADDR", spec)
+		}
+		srv := &mongo.Server{SetName: demoReplicaSet, Me: loopbackAddr(parts[1])}
+		switch parts[0] {
+		case mongodbPrimary:
+			srv.State = mongo.StatePrimary
+			primary = srv.Me
+		case mongodbSecondary:
+			srv.State = mongo.StateSecondary
+		case mongodbLegacy:
+			srv.State = mongo.StateStandalone
+			srv.Legacy = true
+		default:
+			return nil, fmt.Errorf("invalid MongoDB listener %q, unknown mode %q", spec, parts[0])
+		}
+		if srv.State != mongo.StateStandalone {
+			hosts = append(hosts, srv.Me)
+		}
+		servers[parts[1]] = srv
+	}
+	for _, srv := range servers {
+		srv.Primary, srv.Hosts = primary, hosts
+	}
+	return servers, nil
+}
+
+// runMongoDBServer serves srv on addr until done is closed.
+func runMongoDBServer(wg *sync.WaitGroup, done <-chan struct{}, addr string, srv *mongo.Server, out io.Writer) {
+	defer wg.Done()
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("mongodb server listener error:", err)
+	}
+	go srv.Serve(listener)
+	fmt.Fprintf(out, "MongoDB Server Started on %s as %s, legacy: %v\n", addr, srv.State, srv.Legacy)
+
+	<-done
+	listener.Close()
+	log.Printf("MongoDB Server on %s Stopped", addr)
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"stash.appscode.dev/prober-demo/pkg/redis"
+)
+
+// Behaviors of the Redis listeners of run-client.
+const (
+	// redisMaster reports the master role.
+	redisMaster = "master"
+	// redisReplica reports the replica role, replicating the master listener.
+	redisReplica = "replica"
+	// redisLoading answers every command with a LOADING error.
+	redisLoading = "loading"
+)
+
+// defaultRedisListeners start one listener per behavior.
+var defaultRedisListeners = []string{
+	redisMaster + "=:6379",
+	redisReplica + "=:6380",
+	redisLoading + "=:6381",
+}
+
+// newRedisServers parses listener specs of the form MODE=ADDR, e.g. "replica=:6380".
+// The servers require the password of the DB_PASSWORD env, if it is set.
+func newRedisServers(specs []string) (map[string]*redis.Server, error) {
+	servers := map[string]*redis.Server{}
+	var masterAddr string
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid Redis listener %q, expected MODE=ADDR", spec)
+		}
+		srv := &redis.Server{Password: os.Getenv(databasePasswordEnv), Role: redis.RoleMaster}
+		switch parts[0] {
+		case redisMaster:
+			masterAddr = loopbackAddr(parts[1])
+		case redisReplica:
+			srv.Role = redis.RoleReplica
+		case redisLoading:
+			srv.Loading = true
+		default:
+			return nil, fmt.Errorf("invalid Redis listener %q, unknown mode %q", spec, parts[0])
+		}
+		servers[parts[1]] = srv
+	}
+	for _, srv := range servers {
+		srv.MasterAddr = masterAddr
+	}
+	return servers, nil
+}
+
+// runRedisServer serves srv on addr until done is closed.
+func runRedisServer(wg *sync.WaitGroup, done <-chan struct{}, addr string, srv *redis.Server, out io.Writer) {
+	defer wg.Done()
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("redis server listener error:", err)
+	}
+	go srv.Serve(listener)
+	fmt.Fprintf(out, "Redis Server Started on %s as %s, loading: %v\n", addr, srv.Role, srv.Loading)
+
+	<-done
+	listener.Close()
+	log.Printf("Redis Server on %s Stopped", addr)
+}
+
+// loopbackAddr returns addr with the loopback address as host if it has none, e.g. "127.0.0.1:6379" for ":6379".
+func loopbackAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// sessionCookie is the name of the cookie set by /login.
+	sessionCookie = "session"
+	// sessionTTL is how long a login stays valid.
+	sessionTTL = 5 * time.Minute
+)
+
+// session is a login to the demo session endpoints.
+type session struct {
+	username string
+	token    string
+	expires  time.Time
+}
+
+// sessionStore holds the sessions of the /login, /me and /logout endpoints, keyed by session cookie.
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+// demoSessions is the store of the session endpoints of run-client.
+var demoSessions = &sessionStore{sessions: map[string]session{}}
+
+// addSessionRoutes registers a login flow for multi-step probes: POST /login returns a bearer token
+// and sets a session cookie, GET /me requires both, and POST /logout ends the session.
+// Any username logs in with the token of the AUTH_TOKEN env as password, like /auth-demo.
+func addSessionRoutes(router *mux.Router, store *sessionStore) {
+	router.HandleFunc("/login", store.loginHandler).Methods("POST")
+	router.HandleFunc("/me", store.meHandler).Methods("GET", "HEAD")
+	router.HandleFunc("/logout", store.logoutHandler).Methods("POST")
+}
+
+// loginHandler checks the credentials of a JSON or form body and starts a session.
+func (s *sessionStore) loginHandler(w http.ResponseWriter, r *http.Request) {
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, "Invalid credentials", fmt.Sprintf("failed to decode JSON body: %v", err))
+			return
+		}
+	} else {
+		credentials.Username, credentials.Password = r.PostFormValue("username"), r.PostFormValue("password")
+	}
+	if credentials.Username == "" {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid credentials", "username is required")
+		return
+	}
+	token := os.Getenv("AUTH_TOKEN")
+	if token == "" || credentials.Password != token {
+		writeProblem(w, r, http.StatusUnauthorized, "Login failed", fmt.Sprintf("wrong password for user %q", credentials.Username))
+		return
+	}
+
+	id, sess := randomHex(16), session{username: credentials.Username, token: randomHex(16), expires: time.Now().Add(sessionTTL)}
+	s.mu.Lock()
+	for k, v := range s.sessions {
+		if time.Now().After(v.expires) {
+			delete(s.sessions, k)
+		}
+	}
+	s.sessions[id] = sess
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: id, Path: "/", HttpOnly: true, MaxAge: int(sessionTTL.Seconds())})
+	w.Header().Set("X-Session-Expires", sess.expires.UTC().Format(time.RFC3339))
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"token":     sess.token,
+		"tokenType": "Bearer",
+		"expiresIn": int(sessionTTL.Seconds()),
+	})
+}
+
+// meHandler replies with the user of the session, if the request has its cookie and token.
+func (s *sessionStore) meHandler(w http.ResponseWriter, r *http.Request) {
+	id, sess, ok := s.lookup(r)
+	if !ok {
+		writeProblem(w, r, http.StatusUnauthorized, "Not logged in", "the session cookie is missing or expired")
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+sess.token {
+		writeProblem(w, r, http.StatusUnauthorized, "Invalid token", "the bearer token does not belong to the session")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"username": sess.username,
+		"session":  id,
+		"expires":  sess.expires.UTC().Format(time.RFC3339),
+	})
+}
+
+// logoutHandler ends the session of the request.
+func (s *sessionStore) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if id, _, ok := s.lookup(r); ok {
+		s.mu.Lock()
+		delete(s.sessions, id)
+		s.mu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookup returns the unexpired session of the cookie of r.
+func (s *sessionStore) lookup(r *http.Request) (string, session, bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", session{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[cookie.Value]
+	if !ok || time.Now().After(sess.expires) {
+		return "", session{}, false
+	}
+	return cookie.Value, sess, true
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// The outcomes requests of run-client are counted by.
+const (
+	// outcomeServed means the request was answered as it asked, whatever the status code.
+	outcomeServed = "served"
+	// outcomeInvalid means the request was rejected with a problem document.
+	outcomeInvalid = "invalid"
+	// outcomeError means the handler failed.
+	outcomeError = "error"
+)
+
+// problem is an RFC 7807 problem document, returned for requests that can't be served.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// writeProblem replies to r with a problem document and marks the request as invalid for client errors.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, title string, detail string) {
+	if rec, ok := w.(*statusRecorder); ok && status < http.StatusInternalServerError {
+		rec.outcome = outcomeInvalid
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(problem{
+		Type:     "about:blank",
+		Title:    title,
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+	if err != nil {
+		log.Println("failed to write problem:", err)
+	}
+}
+
+// statusRecorder keeps the status code and the outcome of a request.
+type statusRecorder struct {
+	http.ResponseWriter
+	code    int
+	outcome string
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if rec.code == 0 {
+		rec.code = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.code == 0 {
+		rec.code = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming handlers, like the gRPC health service, flush through the recorder.
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+type requestKey struct {
+	method  string
+	path    string
+	code    int
+	outcome string
+}
+
+// requestCounter counts the requests received by run-client by route, status code and outcome.
+type requestCounter struct {
+	mu     sync.Mutex
+	counts map[requestKey]uint64
+}
+
+func newRequestCounter() *requestCounter {
+	return &requestCounter{counts: map[requestKey]uint64{}}
+}
+
+// Wrap returns a handler that counts every request served by router, including unmatched ones,
+// and turns handler panics into problem documents.
+func (c *requestCounter) Wrap(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, outcome: outcomeServed}
+		// count by route template, so that paths with variables don't each get a count.
+		path := "unmatched"
+		var match mux.RouteMatch
+		if router.Match(r, &match) && match.Route != nil {
+			if tpl, err := match.Route.GetPathTemplate(); err == nil {
+				path = tpl
+			}
+		} else {
+			rec.outcome = outcomeInvalid
+		}
+		defer func() {
+			if v := recover(); v != nil {
+				log.Printf("panic serving %s %s: %v", r.Method, r.URL.Path, v)
+				rec.outcome = outcomeError
+				if rec.code == 0 {
+					writeProblem(rec, r, http.StatusInternalServerError, "Internal Server Error", fmt.Sprint(v))
+				}
+			}
+			if rec.code == 0 {
+				rec.code = http.StatusOK
+			}
+			c.mu.Lock()
+			c.counts[requestKey{method: r.Method, path: path, code: rec.code, outcome: rec.outcome}]++
+			c.mu.Unlock()
+		}()
+		router.ServeHTTP(rec, r)
+	})
+}
+
+// ServeHTTP writes the counts in the Prometheus text format.
+func (c *requestCounter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	lines := make([]string, 0, len(c.counts))
+	for k, v := range c.counts {
+		lines = append(lines, fmt.Sprintf("prober_demo_http_requests_total{method=%q,path=%q,code=\"%s\",outcome=%q} %d",
+			k.method, k.path, strconv.Itoa(k.code), k.outcome, v))
+	}
+	c.mu.Unlock()
+	sort.Strings(lines)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP prober_demo_http_requests_total Number of HTTP requests received by run-client.")
+	fmt.Fprintln(w, "# TYPE prober_demo_http_requests_total counter")
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
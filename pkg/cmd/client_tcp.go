@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Behaviors of the TCP listeners of run-client.
+const (
+	// tcpReply reads once and replies "Message received.", the original behavior.
+	tcpReply = "reply"
+	// tcpEcho writes back everything it reads until the client closes.
+	tcpEcho = "echo"
+	// tcpBanner sends the banner on connect, then behaves like echo.
+	tcpBanner = "banner"
+	// tcpScript answers lines with the reply of the first script rule they match.
+	tcpScript = "script"
+	// tcpSession sends the banner on connect, then behaves like script, like SMTP or FTP servers do.
+	tcpSession = "session"
+	// tcpSilent accepts and never writes, until the client closes.
+	tcpSilent = "silent"
+	// tcpReset accepts and closes immediately with a RST.
+	tcpReset = "reset"
+	// tcpRefuse never accepts, its backlog is kept full so that new connections time out.
+	tcpRefuse = "refuse"
+)
+
+// defaultTCPListeners start one listener per behavior. 9091 is left closed on purpose.
+var defaultTCPListeners = []string{
+	tcpReply + "=:9090",
+	tcpEcho + "=:9092",
+	tcpBanner + "=:9093",
+	tcpScript + "=:9094",
+	tcpSession + "=:9099",
+	tcpSilent + "=:9096",
+	tcpReset + "=:9097",
+	tcpRefuse + "=:9098",
+}
+
+// maxBacklogFill bounds the connections opened to fill the backlog of a refuse listener.
+const maxBacklogFill = 16
+
+const defaultTCPBanner = "220 prober-demo ready\r\n"
+
+// defaultTCPScript is used by script listeners when no --tcp-script is given.
+const defaultTCPScript = `# PATTERN => REPLY, $1 refers to the first group of PATTERN.
+^PING$ => PONG
+^HELLO (.+)$ => HELLO $1
+^ECHO (.*)$ => $1
+`
+
+// tcpScriptQuit is the line that ends a script session, whatever the script.
+const tcpScriptQuit = "QUIT"
+
+// tcpServer is a TCP listener of run-client with one of the behaviors above.
+type tcpServer struct {
+	mode   string
+	addr   string
+	banner string
+	script []scriptRule
+	out    io.Writer
+
+	listener net.Listener
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// scriptRule replies Reply to the lines matching Pattern.
+type scriptRule struct {
+	pattern *regexp.Regexp
+	reply   string
+}
+
+// newTCPServers parses listener specs of the form MODE=ADDR, e.g. "echo=:9092".
+func newTCPServers(specs []string, banner string, scriptFile string, out io.Writer) ([]*tcpServer, error) {
+	script := defaultTCPScript
+	if scriptFile != "" {
+		data, err := ioutil.ReadFile(scriptFile)
+		if err != nil {
+			return nil, err
+		}
+		script = string(data)
+	}
+	rules, err := parseTCPScript(script)
+	if err != nil {
+		return nil, err
+	}
+
+	servers := make([]*tcpServer, 0, len(specs))
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid TCP listener %q, expected MODE=ADDR", spec)
+		}
+		switch parts[0] {
+		case tcpReply, tcpEcho, tcpBanner, tcpScript, tcpSession, tcpSilent, tcpReset, tcpRefuse:
+		default:
+			return nil, fmt.Errorf("invalid TCP listener %q, unknown mode %q", spec, parts[0])
+		}
+		servers = append(servers, &tcpServer{
+			mode:   parts[0],
+			addr:   parts[1],
+			banner: banner,
+			script: rules,
+			out:    out,
+			conns:  map[net.Conn]struct{}{},
+		})
+	}
+	return servers, nil
+}
+
+func parseTCPScript(script string) ([]scriptRule, error) {
+	var rules []scriptRule
+	for i, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, " => ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid TCP script line %d %q, expected PATTERN => REPLY", i+1, line)
+		}
+		re, err := regexp.Compile(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid TCP script line %d: %v", i+1, err)
+		}
+		rules = append(rules, scriptRule{pattern: re, reply: parts[1]})
+	}
+	return rules, nil
+}
+
+// run serves connections until done is closed, then closes the open ones.
+func (s *tcpServer) run(wg *sync.WaitGroup, done <-chan struct{}) {
+	defer wg.Done()
+	var err error
+	if s.mode == tcpRefuse {
+		s.listener, err = listenBacklogFull(s.addr)
+	} else {
+		s.listener, err = net.Listen("tcp", s.addr)
+	}
+	if err != nil {
+		log.Fatalf("tcp server listener error: %v", err)
+	}
+	fmt.Fprintf(s.out, "Starting %s TCP server on %s\n", s.mode, s.addr)
+
+	if s.mode != tcpRefuse {
+		go s.serve()
+	}
+
+	<-done
+	fmt.Fprintf(s.out, "Stopping %s TCP server on %s\n", s.mode, s.addr)
+	s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *tcpServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			// the listener is closed on shutdown.
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *tcpServer) handle(conn net.Conn) {
+	fmt.Fprintf(s.out, "New %s TCP connection from %s\n", s.mode, conn.RemoteAddr())
+	switch s.mode {
+	case tcpReply:
+		handleConnection(conn, s.out)
+	case tcpEcho:
+		echo(conn)
+	case tcpBanner:
+		if _, err := io.WriteString(conn, s.banner); err != nil {
+			return
+		}
+		echo(conn)
+	case tcpScript:
+		s.runScript(conn)
+	case tcpSession:
+		if _, err := io.WriteString(conn, s.banner); err != nil {
+			return
+		}
+		s.runScript(conn)
+	case tcpSilent:
+		// keep reading so that the payload is journaled and the close of the client is noticed.
+		payload := &journalBuffer{}
+		io.Copy(payload, conn)
+		requestJournal.recordTCP(conn, payload.Bytes())
+	case tcpReset:
+		if tc, ok := conn.(*net.TCPConn); ok {
+			// a zero linger makes Close send a RST instead of a FIN.
+			tc.SetLinger(0)
+		}
+	}
+}
+
+// handleConnection reads once and replies "Message received.".
+func handleConnection(conn net.Conn, out io.Writer) {
+	fmt.Fprintln(out, "Handling Request.....")
+	// Make a buffer to hold incoming data.
+	buf := make([]byte, 1024)
+	// Read the incoming connection into the buffer.
+	n, err := conn.Read(buf)
+	if err != nil {
+		fmt.Fprintln(out, "Error reading:", err.Error())
+	}
+	requestJournal.recordTCP(conn, buf[:n])
+	// Send a response back to person contacting us.
+	conn.Write([]byte("Message received."))
+	fmt.Fprintln(out, "Request Handling Done. Closing.....")
+}
+
+// echo writes back what it reads until the client closes.
+func echo(conn net.Conn) {
+	payload := &journalBuffer{}
+	io.Copy(io.MultiWriter(conn, payload), conn)
+	requestJournal.recordTCP(conn, payload.Bytes())
+}
+
+// runScript answers each line with the reply of the first rule it matches, or an error line.
+// The QUIT line is answered BYE and ends the session.
+func (s *tcpServer) runScript(conn net.Conn) {
+	payload := &journalBuffer{}
+	defer func() {
+		requestJournal.recordTCP(conn, payload.Bytes())
+	}()
+	scanner := bufio.NewScanner(io.TeeReader(conn, payload))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == tcpScriptQuit {
+			io.WriteString(conn, "BYE\r\n")
+			return
+		}
+		reply := fmt.Sprintf("ERR unknown command %q", line)
+		for _, rule := range s.script {
+			if m := rule.pattern.FindStringSubmatchIndex(line); m != nil {
+				reply = string(rule.pattern.ExpandString(nil, rule.reply, line, m))
+				break
+			}
+		}
+		if _, err := io.WriteString(conn, reply+"\r\n"); err != nil {
+			return
+		}
+	}
+}
+
+// journalBuffer keeps the first maxJournalBody bytes written to it, and one more to tell that it is truncated.
+type journalBuffer struct {
+	bytes.Buffer
+}
+
+func (b *journalBuffer) Write(p []byte) (int, error) {
+	if remaining := maxJournalBody + 1 - b.Len(); remaining > 0 {
+		if len(p) < remaining {
+			remaining = len(p)
+		}
+		b.Buffer.Write(p[:remaining])
+	}
+	return len(p), nil
+}
+
+// fillBacklog connects to l until connecting times out, so that the accept queue of l is full.
+// It gives up after maxBacklogFill connections.
+func fillBacklog(l net.Listener) []net.Conn {
+	var conns []net.Conn
+	for i := 0; i < maxBacklogFill; i++ {
+		conn, err := net.DialTimeout("tcp", l.Addr().String(), 100*time.Millisecond)
+		if err != nil {
+			break
+		}
+		conns = append(conns, conn)
+	}
+	return conns
+}
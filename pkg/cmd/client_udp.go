@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Behaviors of the UDP listeners of run-client.
+const (
+	// udpEcho sends every datagram back to its sender.
+	udpEcho = "echo"
+	// udpIgnore receives datagrams and never replies.
+	udpIgnore = "ignore"
+)
+
+// defaultUDPListeners start one listener per behavior.
+var defaultUDPListeners = []string{
+	udpEcho + "=:9100",
+	udpIgnore + "=:9101",
+}
+
+// maxDatagram is the largest datagram read by the UDP listeners.
+const maxDatagram = 64 << 10 // 64KB
+
+// udpServer is a UDP listener of run-client with one of the behaviors above.
+type udpServer struct {
+	mode string
+	addr string
+	out  io.Writer
+}
+
+// newUDPServers parses listener specs of the form MODE=ADDR, e.g. "echo=:9100".
+func newUDPServers(specs []string, out io.Writer) ([]*udpServer, error) {
+	servers := make([]*udpServer, 0, len(specs))
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid UDP listener %q, expected MODE=ADDR", spec)
+		}
+		switch parts[0] {
+		case udpEcho, udpIgnore:
+		default:
+			return nil, fmt.Errorf("invalid UDP listener %q, unknown mode %q", spec, parts[0])
+		}
+		servers = append(servers, &udpServer{mode: parts[0], addr: parts[1], out: out})
+	}
+	return servers, nil
+}
+
+// run serves datagrams until done is closed.
+func (s *udpServer) run(wg *sync.WaitGroup, done <-chan struct{}) {
+	defer wg.Done()
+	conn, err := net.ListenPacket("udp", s.addr)
+	if err != nil {
+		log.Fatalf("udp server listener error: %v", err)
+	}
+	fmt.Fprintf(s.out, "Starting %s UDP server on %s\n", s.mode, s.addr)
+
+	go func() {
+		buf := make([]byte, maxDatagram)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				// the connection is closed on shutdown.
+				return
+			}
+			fmt.Fprintf(s.out, "New %s UDP datagram from %s, %d bytes\n", s.mode, addr, n)
+			requestJournal.recordPayload(protocolUDP, conn.LocalAddr(), addr, buf[:n])
+			if s.mode == udpEcho {
+				if _, err = conn.WriteTo(buf[:n], addr); err != nil {
+					log.Println("failed to write UDP reply:", err)
+				}
+			}
+		}
+	}()
+
+	<-done
+	fmt.Fprintf(s.out, "Stopping %s UDP server on %s\n", s.mode, s.addr)
+	conn.Close()
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	api "kmodules.xyz/prober/api"
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+	"stash.appscode.dev/prober-demo/pkg/probe"
+)
+
+const (
+	// eventComponent is the source of the Events recorded by run-probe.
+	eventComponent = "prober-demo"
+	// maxEventMessage is the length Kubernetes accepts for the message of an Event.
+	maxEventMessage = 1024
+)
+
+// failureReason returns the failure code of report, or ProbeFailed or ProbeWarning if it has none.
+func failureReason(report *probe.Report) string {
+	if report.Code != "" {
+		return string(report.Code)
+	}
+	if report.Result == api.Warning {
+		return "ProbeWarning"
+	}
+	return "ProbeFailed"
+}
+
+// recordProbeEvent records a Warning Event on pod for the report of the i-th probe, unless it succeeded.
+// The reason of the Event is given by failureReason.
+func recordProbeEvent(client kubernetes.Interface, pod *v1.Pod, i int, p *api_v1.Handler, report *probe.Report) error {
+	if report.Result == api.Success {
+		return nil
+	}
+	reason := failureReason(report)
+	message := fmt.Sprintf("Probe %d (%s) returned %s: %s", i, probe.Describe(p), report.Result, report.Reason)
+	if len(message) > maxEventMessage {
+		message = message[:maxEventMessage-3] + "..."
+	}
+	now := metav1.Now()
+	_, err := client.CoreV1().Events(pod.Namespace).Create(&v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: pod.Name + ".",
+			Namespace:    pod.Namespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Namespace:  pod.Namespace,
+			Name:       pod.Name,
+			UID:        pod.UID,
+		},
+		Reason:         reason,
+		Message:        message,
+		Source:         v1.EventSource{Component: eventComponent},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Type:           v1.EventTypeWarning,
+	})
+	return err
+}
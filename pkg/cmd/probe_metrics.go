@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+	"stash.appscode.dev/prober-demo/pkg/probe"
+)
+
+// durationBuckets are the upper bounds of the duration histograms, in seconds.
+var durationBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// histogram is a Prometheus histogram of durations.
+type histogram struct {
+	// counts holds the number of observations of each bucket, not cumulated.
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]uint64, len(durationBuckets))}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+	for i, le := range durationBuckets {
+		if seconds <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// write writes the series of the histogram with the given labels, e.g. `probe="0"`.
+func (h *histogram) write(w io.Writer, name string, labels string) {
+	var cumulative uint64
+	for i, le := range durationBuckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", name, labels, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// probeMetrics collects the results and the HTTP timings of the probes run by run-probe.
+// Series are keyed by their labels, rendered in the Prometheus text format.
+type probeMetrics struct {
+	results     map[string]uint64
+	durations   map[string]*histogram
+	phases      map[string]*histogram
+	connections map[string]uint64
+}
+
+func newProbeMetrics() *probeMetrics {
+	return &probeMetrics{
+		results:     map[string]uint64{},
+		durations:   map[string]*histogram{},
+		phases:      map[string]*histogram{},
+		connections: map[string]uint64{},
+	}
+}
+
+// observe records the report of the i-th probe.
+func (m *probeMetrics) observe(i int, p *api_v1.Handler, report *probe.Report) {
+	labels := fmt.Sprintf("probe=\"%d\",action=%q", i, probe.Describe(p))
+	m.results[fmt.Sprintf("%s,result=%q,code=%q", labels, report.Result, report.Code)]++
+	observe(m.durations, labels, report.Duration)
+
+	t := report.HTTPTiming
+	if t == nil {
+		return
+	}
+	for _, phase := range []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"dns", t.DNSLookup},
+		{"connect", t.Connect},
+		{"tls", t.TLSHandshake},
+		{"first_byte", t.FirstByte},
+		{"total", t.Total},
+	} {
+		// phases that did not happen, like the DNS lookup of IP addresses, are left out.
+		if phase.duration > 0 {
+			observe(m.phases, fmt.Sprintf("%s,phase=%q", labels, phase.name), phase.duration)
+		}
+	}
+	if t.RemoteAddr != "" {
+		m.connections[fmt.Sprintf("%s,remote_addr=%q,reused=\"%t\"", labels, t.RemoteAddr, t.Reused)]++
+	}
+}
+
+func observe(histograms map[string]*histogram, labels string, d time.Duration) {
+	h, ok := histograms[labels]
+	if !ok {
+		h = newHistogram()
+		histograms[labels] = h
+	}
+	h.observe(d)
+}
+
+// write writes the metrics in the Prometheus text format.
+func (m *probeMetrics) write(w io.Writer) {
+	fmt.Fprintln(w, "# HELP prober_demo_probe_results_total Number of probe runs by result and failure code.")
+	fmt.Fprintln(w, "# TYPE prober_demo_probe_results_total counter")
+	for _, labels := range sortedKeys(m.results) {
+		fmt.Fprintf(w, "prober_demo_probe_results_total{%s} %d\n", labels, m.results[labels])
+	}
+	fmt.Fprintln(w, "# HELP prober_demo_probe_duration_seconds Time spent running probes, retries included.")
+	fmt.Fprintln(w, "# TYPE prober_demo_probe_duration_seconds histogram")
+	for _, labels := range sortedHistogramKeys(m.durations) {
+		m.durations[labels].write(w, "prober_demo_probe_duration_seconds", labels)
+	}
+	fmt.Fprintln(w, "# HELP prober_demo_probe_http_phase_duration_seconds Time spent by HTTP probes in each phase of the request.")
+	fmt.Fprintln(w, "# TYPE prober_demo_probe_http_phase_duration_seconds histogram")
+	for _, labels := range sortedHistogramKeys(m.phases) {
+		m.phases[labels].write(w, "prober_demo_probe_http_phase_duration_seconds", labels)
+	}
+	fmt.Fprintln(w, "# HELP prober_demo_probe_http_connections_total Number of HTTP probe requests by remote address and connection reuse.")
+	fmt.Fprintln(w, "# TYPE prober_demo_probe_http_connections_total counter")
+	for _, labels := range sortedKeys(m.connections) {
+		fmt.Fprintf(w, "prober_demo_probe_http_connections_total{%s} %d\n", labels, m.connections[labels])
+	}
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedHistogramKeys(m map[string]*histogram) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
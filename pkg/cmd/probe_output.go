@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	api "kmodules.xyz/prober/api"
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+	"stash.appscode.dev/prober-demo/pkg/probe"
+	"stash.appscode.dev/prober-demo/pkg/probe/failure"
+)
+
+// Formats of the output of run-probe.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// probeOutput is the JSON output of a probe run by run-probe. Durations are in seconds.
+type probeOutput struct {
+	Probe           int          `json:"probe"`
+	Action          string       `json:"action"`
+	Result          api.Result   `json:"result"`
+	Code            failure.Code `json:"code,omitempty"`
+	Reason          string       `json:"reason"`
+	DurationSeconds float64      `json:"durationSeconds"`
+	Attempts        int          `json:"attempts"`
+	HTTP            *httpOutput  `json:"http,omitempty"`
+}
+
+// httpOutput is the JSON output of the last response of an HTTP probe and its timing.
+type httpOutput struct {
+	StatusCode          int     `json:"statusCode,omitempty"`
+	RemoteAddr          string  `json:"remoteAddr,omitempty"`
+	ConnectionReused    bool    `json:"connectionReused"`
+	DNSLookupSeconds    float64 `json:"dnsLookupSeconds"`
+	ConnectSeconds      float64 `json:"connectSeconds"`
+	TLSHandshakeSeconds float64 `json:"tlsHandshakeSeconds"`
+	FirstByteSeconds    float64 `json:"firstByteSeconds"`
+	TotalSeconds        float64 `json:"totalSeconds"`
+}
+
+func newProbeOutput(i int, p *api_v1.Handler, report *probe.Report) probeOutput {
+	out := probeOutput{
+		Probe:           i,
+		Action:          probe.Describe(p),
+		Result:          report.Result,
+		Code:            report.Code,
+		Reason:          report.Reason,
+		DurationSeconds: report.Duration.Seconds(),
+		Attempts:        report.Attempts,
+	}
+	if t := report.HTTPTiming; t != nil {
+		out.HTTP = &httpOutput{
+			StatusCode:          report.StatusCode,
+			RemoteAddr:          t.RemoteAddr,
+			ConnectionReused:    t.Reused,
+			DNSLookupSeconds:    t.DNSLookup.Seconds(),
+			ConnectSeconds:      t.Connect.Seconds(),
+			TLSHandshakeSeconds: t.TLSHandshake.Seconds(),
+			FirstByteSeconds:    t.FirstByte.Seconds(),
+			TotalSeconds:        t.Total.Seconds(),
+		}
+	}
+	return out
+}
+
+// writeProbeText writes the report of the i-th probe as text.
+func writeProbeText(w io.Writer, i int, report *probe.Report) {
+	fmt.Fprintf(w, "============== Probe No: %d =================\n", i)
+	fmt.Fprintf(w, "Result: %v\n", report.Result)
+	if report.Code != "" {
+		fmt.Fprintf(w, "Code: %v\n", report.Code)
+	}
+	fmt.Fprintf(w, "Reason: %v\n", report.Reason)
+	if t := report.HTTPTiming; t != nil {
+		fmt.Fprintf(w, "Timing: dns %v, connect %v, tls %v, first byte %v, total %v, remote %s, reused %v\n",
+			t.DNSLookup.Round(time.Microsecond), t.Connect.Round(time.Microsecond), t.TLSHandshake.Round(time.Microsecond),
+			t.FirstByte.Round(time.Microsecond), t.Total.Round(time.Microsecond), t.RemoteAddr, t.Reused)
+	}
+}
+
+// writeProbesJSON writes the outputs of the probes as an indented JSON array.
+func writeProbesJSON(w io.Writer, outputs []probeOutput) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(outputs)
+}
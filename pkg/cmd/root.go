@@ -11,5 +11,7 @@ func NewRootCmd() *cobra.Command  {
 	}
 	rootCmd.AddCommand(NewCmdRunProbe())
 	rootCmd.AddCommand(NewCmdRunClient())
+	rootCmd.AddCommand(NewCmdBench())
+	rootCmd.AddCommand(NewCmdSelftest())
 	return rootCmd
 }
\ No newline at end of file
@@ -2,69 +2,248 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"mime"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/golang/protobuf/proto"
+	structpb "github.com/golang/protobuf/ptypes/struct"
 	"github.com/gorilla/mux" // need to use dep for package management
 	"github.com/spf13/cobra"
-	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	httpprobe "kmodules.xyz/prober/probe/http"
+	"golang.org/x/net/http2"
+	"k8s.io/client-go/util/cert"
+	prober_http "kmodules.xyz/prober/probe/http"
+	"stash.appscode.dev/prober-demo/pkg/grpc/health"
+	"stash.appscode.dev/prober-demo/pkg/mysql"
+	"stash.appscode.dev/prober-demo/pkg/postgres"
+	httpprobe "stash.appscode.dev/prober-demo/pkg/probe/http"
 )
 
+// requestCounts counts the requests of the HTTP and HTTPS servers, they are exposed at /metrics.
+var requestCounts = newRequestCounter()
+
+// maxMultipartMemory is the part of a multipart body kept in memory, the rest is stored in temporary files.
+const maxMultipartMemory = 1 << 20 // 1MB
+
+// healthServer is the gRPC health service of run-client, its statuses can be changed via /grpc-health.
+var healthServer = health.NewServer()
+
+type clientOptions struct {
+	httpAddr         string
+	httpsAddr        string
+	grpcAddr         string
+	tlsCertFile      string
+	tlsKeyFile       string
+	clientCAFile     string
+	certDir          string
+	tcpListeners     []string
+	tcpBanner        string
+	tcpScript        string
+	udpListeners     []string
+	dnsAddr          string
+	postgresAddr     string
+	postgresAuth     string
+	mysqlAddr        string
+	mysqlAuth        string
+	mysqlCacheMiss   bool
+	databaseUser     string
+	redisListeners   []string
+	mongodbListeners []string
+	// out is where the servers print what they serve.
+	out io.Writer
+}
+
 func NewCmdRunClient() *cobra.Command {
+	opt := clientOptions{out: os.Stdout}
 	cmd := &cobra.Command{
 		Use:   "run-client",
 		Short: "run client where probes will be executed",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			fmt.Println("Running... client")
-			return runClient()
+			return runClient(opt)
 		},
 	}
+	cmd.Flags().StringVar(&opt.httpAddr, "http-addr", ":8080", "Address of the HTTP server.")
+	cmd.Flags().StringVar(&opt.httpsAddr, "https-addr", ":8443", "Address of the HTTPS server.")
+	cmd.Flags().StringVar(&opt.grpcAddr, "grpc-addr", ":9095", "Address of the plaintext gRPC server.")
+	cmd.Flags().StringVar(&opt.tlsCertFile, "tls-cert-file", "", "PEM encoded certificate served by the HTTPS server. A self-signed one is generated if empty.")
+	cmd.Flags().StringVar(&opt.tlsKeyFile, "tls-key-file", "", "PEM encoded private key of --tls-cert-file.")
+	cmd.Flags().StringVar(&opt.clientCAFile, "client-ca-file", "", "If set, the HTTPS server requires client certificates signed by this CA bundle.")
+	cmd.Flags().StringVar(&opt.certDir, "cert-dir", filepath.Join(os.TempDir(), "prober-demo"), "Directory where the generated self-signed certificate is written.")
+	cmd.Flags().StringSliceVar(&opt.tcpListeners, "tcp-listener", defaultTCPListeners, "TCP listeners as MODE=ADDR. MODE is one of reply, echo, banner, script, session, silent, reset or refuse.")
+	cmd.Flags().StringVar(&opt.tcpBanner, "tcp-banner", defaultTCPBanner, "Banner sent on connect by banner and session TCP listeners.")
+	cmd.Flags().StringVar(&opt.tcpScript, "tcp-script", "", "File of PATTERN => REPLY lines answered by script and session TCP listeners. Defaults to a PING/HELLO/ECHO script.")
+	cmd.Flags().StringSliceVar(&opt.udpListeners, "udp-listener", defaultUDPListeners, "UDP listeners as MODE=ADDR. MODE is one of echo or ignore.")
+	cmd.Flags().StringVar(&opt.dnsAddr, "dns-addr", ":5353", "Address of the DNS server, authoritative for the prober-demo.test. zone, over UDP and TCP. Empty disables it.")
+	cmd.Flags().StringVar(&opt.postgresAddr, "postgres-addr", ":5432", "Address of the fake PostgreSQL server. Empty disables it.")
+	cmd.Flags().StringVar(&opt.postgresAuth, "postgres-auth", postgres.AuthSCRAM, "Authentication method of the PostgreSQL server. One of trust, password, md5 or scram-sha-256.")
+	cmd.Flags().StringVar(&opt.mysqlAddr, "mysql-addr", ":3306", "Address of the fake MySQL server. Empty disables it.")
+	cmd.Flags().StringVar(&opt.mysqlAuth, "mysql-auth", mysql.AuthCachingSHA2, "Authentication plugin of the MySQL server. One of mysql_native_password or caching_sha2_password.")
+	cmd.Flags().BoolVar(&opt.mysqlCacheMiss, "mysql-cache-miss", false, "Make caching_sha2_password authentication of the MySQL server always miss its cache, so clients send their password encrypted with its RSA key.")
+	cmd.Flags().StringVar(&opt.databaseUser, "database-user", "prober", "User of the PostgreSQL and MySQL servers. Its password is read from the "+databasePasswordEnv+" env.")
+	cmd.Flags().StringSliceVar(&opt.redisListeners, "redis-listener", defaultRedisListeners, "Redis listeners as MODE=ADDR. MODE is one of master, replica or loading. The "+databasePasswordEnv+" env is their password.")
+	cmd.Flags().StringSliceVar(&opt.mongodbListeners, "mongodb-listener", defaultMongoDBListeners, "MongoDB listeners as MODE=ADDR. MODE is one of primary, secondary or legacy.")
 	return cmd
 }
-func runClient() error {
+
+func runClient(opt clientOptions) error {
 	var wg sync.WaitGroup
 
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	// every server waits on done, so close it instead of relying on a single signal delivery.
+	done := make(chan struct{})
+	go func() {
+		<-sig
+		close(done)
+	}()
 
-	fmt.Println("Starting HTTP Server")
+	if err := startServers(opt, &wg, done); err != nil {
+		return err
+	}
+	wg.Wait()
+
+	fmt.Fprintln(opt.out, "Exiting Client")
+
+	return nil
+}
+
+// startServers starts the servers of run-client, they stop once done is closed.
+// wg is done when they all stopped.
+func startServers(opt clientOptions, wg *sync.WaitGroup, done <-chan struct{}) error {
+	fmt.Fprintln(opt.out, "Starting HTTP Server")
 	wg.Add(1)
-	go runHttpServer(&wg, done)
+	go runHttpServer(wg, done, opt.httpAddr, opt.out)
 
-	fmt.Println("Starting TCP Client")
+	tlsConfig, err := opt.tlsConfig()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(opt.out, "Starting HTTPS Server")
 	wg.Add(1)
-	go runTCPServer(&wg, done)
+	go runHttpsServer(wg, done, opt.httpsAddr, tlsConfig, opt.out)
 
-	wg.Wait()
+	fmt.Fprintln(opt.out, "Starting gRPC Server")
+	healthServer.SetServingStatus("unhealthy", health.HealthCheckResponse_NOT_SERVING)
+	wg.Add(1)
+	go runGRPCServer(wg, done, opt.grpcAddr)
+
+	tcpServers, err := newTCPServers(opt.tcpListeners, opt.tcpBanner, opt.tcpScript, opt.out)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(opt.out, "Starting TCP Servers")
+	for _, srv := range tcpServers {
+		wg.Add(1)
+		go srv.run(wg, done)
+	}
+
+	if opt.dnsAddr != "" {
+		fmt.Fprintln(opt.out, "Starting DNS Server")
+		wg.Add(1)
+		go runDNSServer(wg, done, opt.dnsAddr, opt.out)
+	}
 
-	fmt.Println("Exiting Client")
+	switch opt.postgresAuth {
+	case postgres.AuthTrust, postgres.AuthPassword, postgres.AuthMD5, postgres.AuthSCRAM:
+	default:
+		return fmt.Errorf("invalid --postgres-auth %q", opt.postgresAuth)
+	}
+	switch opt.mysqlAuth {
+	case mysql.AuthNativePassword, mysql.AuthCachingSHA2:
+	default:
+		return fmt.Errorf("invalid --mysql-auth %q", opt.mysqlAuth)
+	}
+
+	if opt.postgresAddr != "" {
+		fmt.Fprintln(opt.out, "Starting PostgreSQL Server")
+		wg.Add(1)
+		go runPostgresServer(wg, done, opt.postgresAddr, opt.databaseUser, opt.postgresAuth, opt.out)
+	}
+
+	if opt.mysqlAddr != "" {
+		fmt.Fprintln(opt.out, "Starting MySQL Server")
+		wg.Add(1)
+		go runMySQLServer(wg, done, opt.mysqlAddr, opt.databaseUser, opt.mysqlAuth, opt.mysqlCacheMiss, opt.out)
+	}
 
+	redisServers, err := newRedisServers(opt.redisListeners)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(opt.out, "Starting Redis Servers")
+	for addr, srv := range redisServers {
+		wg.Add(1)
+		go runRedisServer(wg, done, addr, srv, opt.out)
+	}
+
+	mongodbServers, err := newMongoDBServers(opt.mongodbListeners)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(opt.out, "Starting MongoDB Servers")
+	for addr, srv := range mongodbServers {
+		wg.Add(1)
+		go runMongoDBServer(wg, done, addr, srv, opt.out)
+	}
+
+	udpServers, err := newUDPServers(opt.udpListeners, opt.out)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(opt.out, "Starting UDP Servers")
+	for _, srv := range udpServers {
+		wg.Add(1)
+		go srv.run(wg, done)
+	}
 	return nil
 }
 
-func runHttpServer(wg *sync.WaitGroup, done chan os.Signal) {
-	defer wg.Done()
+// demoHandlers serve the demo routes of the HTTP and HTTPS servers, they print every request to out.
+type demoHandlers struct {
+	out io.Writer
+}
 
+func newRouter(out io.Writer) http.Handler {
+	h := demoHandlers{out: out}
 	router := mux.NewRouter()
-	router.HandleFunc("/", httpGETHandler).Methods("GET")
-	router.HandleFunc("/success", httpGETHandler).Methods("GET")
-	router.HandleFunc("/fail", httpGETHandler).Methods("GET")
-	router.HandleFunc("/post-demo", httpPostHandler).Methods("POST")
+	router.HandleFunc("/", h.httpGETHandler).Methods("GET")
+	router.HandleFunc("/success", h.httpGETHandler).Methods("GET", "HEAD")
+	router.HandleFunc("/fail", h.httpGETHandler).Methods("GET", "HEAD")
+	// every method with a body answers like POST does.
+	router.HandleFunc("/post-demo", h.httpPostHandler).Methods("POST", "PUT", "PATCH", "DELETE")
+	router.HandleFunc("/post-demo", h.httpOptionsHandler).Methods("OPTIONS")
+	router.HandleFunc("/auth-demo", h.httpAuthHandler).Methods("GET")
+	router.HandleFunc("/grpc-health", h.grpcHealthHandler).Methods("POST")
+	// gRPC works over the HTTPS server too, as it negotiates HTTP/2.
+	router.Handle(health.CheckPath, healthServer).Methods("POST")
+	router.Handle("/metrics", requestCounts).Methods("GET")
+	addDiagnosticRoutes(router)
+	addJournalRoutes(router, requestJournal)
+	addSessionRoutes(router, demoSessions)
+	return requestJournal.Wrap(requestCounts.Wrap(router))
+}
+
+func runHttpServer(wg *sync.WaitGroup, done <-chan struct{}, addr string, out io.Writer) {
+	defer wg.Done()
 
 	srv := &http.Server{
-		Addr:    ":8080",
-		Handler: router,
+		Addr:    addr,
+		Handler: newRouter(out),
 	}
 
 	go func() {
@@ -76,7 +255,73 @@ func runHttpServer(wg *sync.WaitGroup, done chan os.Signal) {
 
 	<-done
 	log.Print("Server Stopped")
+	shutdownServer(srv)
+}
+
+func runHttpsServer(wg *sync.WaitGroup, done <-chan struct{}, addr string, tlsConfig *tls.Config, out io.Writer) {
+	defer wg.Done()
+
+	srv := &http.Server{
+		Addr:      addr,
+		Handler:   newRouter(out),
+		TLSConfig: tlsConfig,
+	}
+
+	go func() {
+		// certificates are already loaded into TLSConfig
+		if err := srv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("listen: %s\n", err)
+		}
+	}()
+	log.Print("HTTPS Server Started")
+
+	<-done
+	log.Print("HTTPS Server Stopped")
+	shutdownServer(srv)
+}
+
+// runGRPCServer serves the gRPC health service over plaintext HTTP/2.
+func runGRPCServer(wg *sync.WaitGroup, done <-chan struct{}, addr string) {
+	defer wg.Done()
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("grpc server listener error:", err)
+	}
+	go func() {
+		h2 := &http2.Server{}
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go h2.ServeConn(conn, &http2.ServeConnOpts{Handler: healthServer})
+		}
+	}()
+	log.Print("gRPC Server Started")
+
+	<-done
+	listener.Close()
+	log.Print("gRPC Server Stopped")
+}
 
+// grpcHealthHandler sets the status reported by the gRPC health service,
+// e.g. "curl -d status=NOT_SERVING -d service=demo localhost:8080/grpc-health".
+func (h demoHandlers) grpcHealthHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	status, ok := health.HealthCheckResponse_ServingStatus_value[r.Form.Get("status")]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown status %q", r.Form.Get("status")), http.StatusBadRequest)
+		return
+	}
+	healthServer.SetServingStatus(r.Form.Get("service"), health.HealthCheckResponse_ServingStatus(status))
+	fmt.Fprintf(h.out, "gRPC health of service %q set to %s\n", r.Form.Get("service"), r.Form.Get("status"))
+	w.WriteHeader(http.StatusOK)
+}
+
+func shutdownServer(srv *http.Server) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer func() {
 		// extra handling here
@@ -84,112 +329,201 @@ func runHttpServer(wg *sync.WaitGroup, done chan os.Signal) {
 	}()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Println("server Shutdown Failed:%+v", err)
+		log.Printf("server Shutdown Failed:%+v", err)
 		return
 	}
 	log.Print("Server Exited Properly")
 }
 
-func httpGETHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("============== Received request")
-	fmt.Println(r.URL.Path)
+// tlsConfig returns the TLS configuration of the HTTPS server. When no certificate is given,
+// a self-signed one is generated for localhost and written to certDir so that probes can trust it.
+func (opt clientOptions) tlsConfig() (*tls.Config, error) {
+	certFile, keyFile := opt.tlsCertFile, opt.tlsKeyFile
+	if certFile == "" && keyFile == "" {
+		certPEM, keyPEM, err := cert.GenerateSelfSignedCertKey("prober-demo", []net.IP{net.ParseIP("127.0.0.1")}, []string{"localhost"})
+		if err != nil {
+			return nil, err
+		}
+		if err = os.MkdirAll(opt.certDir, 0755); err != nil {
+			return nil, err
+		}
+		certFile, keyFile = filepath.Join(opt.certDir, "tls.crt"), filepath.Join(opt.certDir, "tls.key")
+		if err = ioutil.WriteFile(certFile, certPEM, 0644); err != nil {
+			return nil, err
+		}
+		if err = ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(opt.out, "Generated self-signed certificate", certFile)
+	}
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{pair}}
+	if opt.clientCAFile != "" {
+		pool, err := cert.NewPool(opt.clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+func (h demoHandlers) httpGETHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(h.out, "============== Received request")
+	fmt.Fprintln(h.out, r.Method, r.URL.Path)
 	switch r.URL.Path {
 	case "/success":
-		fmt.Println("Request in path: /success")
+		fmt.Fprintln(h.out, "Request in path: /success")
 		w.WriteHeader(http.StatusOK)
 	case "/fail":
-		fmt.Println("Request in path: /fail")
+		fmt.Fprintln(h.out, "Request in path: /fail")
 		w.WriteHeader(http.StatusForbidden)
 	}
 }
 
-func httpPostHandler(w http.ResponseWriter, r *http.Request) {
-	contentType := r.Header.Get(httpprobe.ContentType)
+// httpAuthHandler accepts requests authenticated with the token of the AUTH_TOKEN env,
+// either as a Bearer token or as the password of any user.
+func (h demoHandlers) httpAuthHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(h.out, "Request in path:", r.URL.Path)
+	token := os.Getenv("AUTH_TOKEN")
+	if token != "" {
+		if user, password, ok := r.BasicAuth(); ok && password == token {
+			fmt.Fprintf(w, "authenticated as %s", user)
+			return
+		}
+		if r.Header.Get("Authorization") == "Bearer "+token {
+			fmt.Fprint(w, "authenticated with bearer token")
+			return
+		}
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="prober-demo"`)
+	w.WriteHeader(http.StatusUnauthorized)
+}
 
-	code:=http.StatusOK
-	var resp []byte
+// httpOptionsHandler lists the methods accepted by /post-demo.
+func (h demoHandlers) httpOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(h.out, "Request in path:", r.URL.Path, "method:", r.Method)
+	w.Header().Set("Allow", "OPTIONS, POST, PUT, PATCH, DELETE")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// demoData is the payload of the /post-demo requests, it tells the status code and the body to reply with.
+type demoData struct {
+	ExpectedCode     string `json:"expectedCode" xml:"expectedCode"`
+	ExpectedResponse string `json:"expectedResponse" xml:"expectedResponse"`
+}
+
+// statusCode returns the status code asked by the payload, 200 if it asks for none.
+func (d demoData) statusCode() (int, error) {
+	if d.ExpectedCode == "" {
+		return http.StatusOK, nil
+	}
+	code, err := strconv.Atoi(d.ExpectedCode)
+	if err != nil {
+		return 0, fmt.Errorf("expectedCode %q is not a number", d.ExpectedCode)
+	}
+	if code < 100 || code > 599 {
+		return 0, fmt.Errorf("expectedCode %d is not a valid HTTP status code", code)
+	}
+	return code, nil
+}
+
+func (h demoHandlers) httpPostHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(h.out, "Request in path:", r.URL.Path, "method:", r.Method)
+	defer r.Body.Close()
+	contentType := r.Header.Get(prober_http.ContentType)
+	// parameters like charset don't change how the body is decoded here.
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	var data demoData
 	var err error
-	switch contentType {
-	case httpprobe.ContentJson:
-		type DemoData struct {
-			ExpectedCode    string `json:"expectedCode"`
-			ExpectedRespone string `json:"expectedResponse"`
-		}
-		var demoData DemoData
-		err := json.NewDecoder(r.Body).Decode(&demoData)
-		utilruntime.Must(err)
-		code, err = strconv.Atoi(demoData.ExpectedCode)
-		utilruntime.Must(err)
-		resp = []byte(demoData.ExpectedRespone)
-
-	case httpprobe.ContentUrlEncodedForm:
-		err := r.ParseForm()
-		utilruntime.Must(err)
-
-		for key, value := range r.Form {
-			if key == "expectedCode" {
-				code, err = strconv.Atoi(value[0])
-				utilruntime.Must(err)
-			}
-			if key == "expectedResponse" {
-				resp = []byte(value[0])
-			}
+	switch mediaType {
+	case prober_http.ContentJson:
+		err = json.NewDecoder(r.Body).Decode(&data)
+	case prober_http.ContentUrlEncodedForm:
+		if err = r.ParseForm(); err == nil {
+			data = demoData{ExpectedCode: r.Form.Get("expectedCode"), ExpectedResponse: r.Form.Get("expectedResponse")}
 		}
+	case httpprobe.ContentMultipartForm:
+		data, err = h.decodeMultipart(r)
+	case httpprobe.ContentXML, "text/xml":
+		err = xml.NewDecoder(r.Body).Decode(&data)
+	case httpprobe.ContentProtobuf, "application/protobuf":
+		data, err = decodeProtobuf(r)
 	default:
-		resp, err = ioutil.ReadAll(r.Body)
-		utilruntime.Must(err)
-		defer r.Body.Close()
+		var body []byte
+		if body, err = ioutil.ReadAll(r.Body); err == nil {
+			// anything else is echoed back.
+			data = demoData{ExpectedResponse: string(body)}
+		}
+	}
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request body", fmt.Sprintf("failed to decode %s body: %v", mediaType, err))
+		return
+	}
+	code, err := data.statusCode()
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Invalid expectedCode", err.Error())
+		return
+	}
+
+	// headers must be set before the status is written, or they are not sent.
+	if contentType != "" {
+		w.Header().Set(prober_http.ContentType, contentType)
 	}
 	w.WriteHeader(code)
-	w.Header().Set(httpprobe.ContentType, contentType)
-	_, err = w.Write(resp)
-	utilruntime.Must(err)
+	if _, err = w.Write([]byte(data.ExpectedResponse)); err != nil {
+		log.Println("failed to write response:", err)
+	}
 }
 
-func runTCPServer(wg *sync.WaitGroup, done chan os.Signal) {
-	defer wg.Done()
-	listener, err := net.Listen("tcp", ":9090")
-	if err != nil {
-		log.Fatal("tcp server listener error:", err)
+// decodeMultipart reads the payload from the fields of a multipart form.
+// A file sent as expectedResponse is replied as is.
+func (h demoHandlers) decodeMultipart(r *http.Request) (demoData, error) {
+	var data demoData
+	if err := r.ParseMultipartForm(maxMultipartMemory); err != nil {
+		return data, err
 	}
+	defer r.MultipartForm.RemoveAll()
 
-	fmt.Println("Starting TCP server...........")
-	var wg2 sync.WaitGroup
-	go func(done chan os.Signal) {
-		<-done
-		fmt.Println("Stop signal recieved. Stopping TCP server.............")
-		listener.Close()
-		wg2.Wait()
-		return
-	}(done)
-	for {
-		fmt.Println("listening.....")
-		conn, err := listener.Accept()
-		fmt.Println("new request..............")
+	data.ExpectedCode = r.FormValue("expectedCode")
+	data.ExpectedResponse = r.FormValue("expectedResponse")
+	for key, files := range r.MultipartForm.File {
+		fmt.Fprintf(h.out, "Received file %q as %s, %d bytes\n", files[0].Filename, key, files[0].Size)
+		if key != "expectedResponse" {
+			continue
+		}
+		f, err := files[0].Open()
 		if err != nil {
-			fmt.Println("tcp server accept error", err)
-			os.Exit(1)
+			return data, err
 		}
-		wg2.Add(1)
-		go handleConnection(&wg2, conn)
+		resp, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return data, err
+		}
+		data.ExpectedResponse = string(resp)
 	}
+	return data, nil
 }
 
-func handleConnection(wg2 *sync.WaitGroup, conn net.Conn) {
-	fmt.Println("Handling Request.....")
-	defer wg2.Done()
-	defer conn.Close()
-
-	// Make a buffer to hold incoming data.
-	buf := make([]byte, 1024)
-	// Read the incoming connection into the buffer.
-	_, err := conn.Read(buf)
+// decodeProtobuf reads the payload from a binary google.protobuf.Struct message.
+func decodeProtobuf(r *http.Request) (demoData, error) {
+	var data demoData
+	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		fmt.Println("Error reading:", err.Error())
+		return data, err
+	}
+	var msg structpb.Struct
+	if err = proto.Unmarshal(body, &msg); err != nil {
+		return data, err
 	}
-	// Send a response back to person contacting us.
-	conn.Write([]byte("Message received."))
-	// Close the connection when you're done with it.
-	fmt.Println("Request Handling Done. Closing.....")
-	//return
+	data.ExpectedCode = msg.Fields["expectedCode"].GetStringValue()
+	data.ExpectedResponse = msg.Fields["expectedResponse"].GetStringValue()
+	return data, nil
 }
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+	httpprobe "stash.appscode.dev/prober-demo/pkg/probe/http"
+)
+
+func TestPostDemoMalformedBody(t *testing.T) {
+	srv := httptest.NewServer(newRouter(ioutil.Discard))
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"multipart without closing boundary", "multipart/form-data; boundary=xyz", "--xyz\r\nbroken"},
+		{"multipart without boundary", "multipart/form-data", "--xyz\r\n\r\n--xyz--\r\n"},
+		{"json", "application/json", `{"expectedCode":`},
+		{"xml", "application/xml", "<demo><expectedCode>2"},
+		{"protobuf", "application/x-protobuf", "\xff\xff\xff"},
+		{"expectedCode not a number", "application/json", `{"expectedCode":"ok"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := http.Post(srv.URL+"/post-demo", test.contentType, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusBadRequest {
+				body, _ := ioutil.ReadAll(res.Body)
+				t.Errorf("status = %d, want %d: %s", res.StatusCode, http.StatusBadRequest, body)
+			}
+		})
+	}
+}
+
+// TestPostDemoEncodings checks that /post-demo decodes the bodies encoded by the prober.
+func TestPostDemoEncodings(t *testing.T) {
+	srv := httptest.NewServer(newRouter(ioutil.Discard))
+	defer srv.Close()
+
+	multipartBody, multipartType, err := httpprobe.MultipartBody([]api_v1.MultipartPart{
+		{Name: "expectedCode", Value: "201"},
+		{Name: "expectedResponse", Value: "multipart"},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	protobufBody, err := httpprobe.ProtobufBody(`{"expectedCode": "202", "expectedResponse": "protobuf"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		code        int
+		response    string
+	}{
+		{"json", "application/json; charset=utf-8", `{"expectedCode":"200","expectedResponse":"json"}`, 200, "json"},
+		{"form", "application/x-www-form-urlencoded", "expectedCode=204", 204, ""},
+		{"multipart", multipartType, multipartBody, 201, "multipart"},
+		{"xml", httpprobe.ContentXML, "<demo><expectedCode>203</expectedCode><expectedResponse>xml</expectedResponse></demo>", 203, "xml"},
+		{"protobuf", httpprobe.ContentProtobuf, protobufBody, 202, "protobuf"},
+		{"other", "text/plain", "echo", 200, "echo"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := http.Post(srv.URL+"/post-demo", test.contentType, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != test.code || string(body) != test.response {
+				t.Errorf("reply = %d %q, want %d %q", res.StatusCode, body, test.code, test.response)
+			}
+		})
+	}
+}
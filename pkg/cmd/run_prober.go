@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"time"
@@ -16,137 +18,1323 @@ import (
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/clientcmd"
+	api "kmodules.xyz/prober/api"
 	prober_v1 "kmodules.xyz/prober/api/v1"
-	"kmodules.xyz/prober/probe"
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+	"stash.appscode.dev/prober-demo/pkg/probe"
 )
 
+// ProbeOptions holds the settings that apply to every probe run by RunProbes.
+type ProbeOptions struct {
+	// TLS is the default TLS configuration of HTTPS probes.
+	TLS api_v1.TLSConfig
+	// Output is the format of the results, text or json.
+	Output string
+	// MetricsFile is the path the metrics of the probes are written to in the Prometheus text format, if set.
+	MetricsFile string
+	// RecordEvents records an Event on the probed pod for every probe that does not succeed.
+	RecordEvents bool
+}
+
 func NewCmdRunProbe() *cobra.Command {
+	opt := ProbeOptions{}
 	cmd := &cobra.Command{
 		Use:   "run-probe",
 		Short: "run probe",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			fmt.Println("Running... probe")
+			if opt.Output != outputText && opt.Output != outputJSON {
+				return fmt.Errorf("invalid output %q, must be one of %s or %s", opt.Output, outputText, outputJSON)
+			}
+			if opt.Output == outputText {
+				fmt.Println("Running... probe")
+			}
 			kubeconfigPath := os.Getenv("KUBECONFIG")
 			config, err := clientcmd.BuildConfigFromFlags("", kubeconfigPath)
 			if err != nil {
 				log.Fatalf("Could not get Kubernetes config: %s", err)
 			}
 
-			return RunProbes(config)
+			return RunProbes(config, opt)
 		},
 	}
+	cmd.Flags().StringVar(&opt.TLS.CAFile, "tls-ca-file", "", "PEM encoded CA bundle used to verify HTTPS probe targets. If no TLS flag is set, verification is skipped.")
+	cmd.Flags().StringVar(&opt.TLS.CertFile, "tls-cert-file", "", "PEM encoded client certificate presented by HTTPS probes.")
+	cmd.Flags().StringVar(&opt.TLS.KeyFile, "tls-key-file", "", "PEM encoded private key of --tls-cert-file.")
+	cmd.Flags().StringVar(&opt.TLS.ServerName, "tls-server-name", "", "Server name used for SNI and certificate verification. Defaults to the probe host.")
+	cmd.Flags().StringVar(&opt.TLS.MinVersion, "tls-min-version", "", "Minimum TLS version accepted by HTTPS probes. One of 1.0, 1.1, 1.2 or 1.3.")
+	cmd.Flags().StringVarP(&opt.Output, "output", "o", outputText, "Format of the results. One of text or json.")
+	cmd.Flags().BoolVar(&opt.RecordEvents, "record-events", false, "Record a Warning Event on the probed pod for every probe that does not succeed, with the failure code as reason.")
+	cmd.Flags().StringVar(&opt.MetricsFile, "metrics-file", "", "File the metrics of the probes are written to in the Prometheus text format, e.g. for the textfile collector of node_exporter.")
 	return cmd
 }
 
-func RunProbes(config *rest.Config) error {
+func RunProbes(config *rest.Config, opt ProbeOptions) error {
+	probes := demoProbes()
+
+	kubeClient := kubernetes.NewForConfigOrDie(config)
+	pod, err := kubeClient.CoreV1().Pods("default").Get("prober-demo", metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	status := pod.Status
+	container := pod.Spec.Containers[0]
+
+	pb := probe.NewProber(config)
+	if opt.TLS != (api_v1.TLSConfig{}) {
+		pb.TLS = &opt.TLS
+	}
+
+	metrics := newProbeMetrics()
+	var outputs []probeOutput
+	for i := range probes {
+		p := &probes[i].handler
+		report, err := pb.Run(p, pod, status, container, time.Second*30)
+		if err != nil {
+			return err
+		}
+		metrics.observe(i, p, report)
+		if opt.RecordEvents {
+			if err := recordProbeEvent(kubeClient, pod, i, p, report); err != nil {
+				log.Printf("failed to record event of probe %d: %v", i, err)
+			}
+		}
+		if opt.Output == outputJSON {
+			outputs = append(outputs, newProbeOutput(i, p, report))
+		} else {
+			writeProbeText(os.Stdout, i, report)
+		}
+	}
+	if opt.Output == outputJSON {
+		if err := writeProbesJSON(os.Stdout, outputs); err != nil {
+			return err
+		}
+	}
+
+	if opt.MetricsFile != "" {
+		var buf bytes.Buffer
+		metrics.write(&buf)
+		if err := ioutil.WriteFile(opt.MetricsFile, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write metrics: %v", err)
+		}
+	}
+	return nil
+}
+
+// demoProbe is a probe of run-probe with the result selftest expects of it.
+type demoProbe struct {
+	handler api_v1.Handler
+	expect  api.Result
+}
 
-	probes := []prober_v1.Handler{
+// demoProbes returns the probes run by run-probe against the prober-demo pod, and by selftest against in-process servers.
+func demoProbes() []demoProbe {
+	dbPassword := &api_v1.ValueSource{
+		SecretKeyRef: &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+			Key:                  "db-password",
+		},
+	}
+	// the name of the cluster resolves only in a cluster.
+	clusterDNS := api.Failure
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		clusterDNS = api.Success
+	}
+	return []demoProbe{
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				HTTPGet: &v1.HTTPGetAction{
+					Path:        "/success",
+					Port:        intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+					Host:        "127.0.0.1",
+					Scheme:      "HTTP",
+					HTTPHeaders: nil,
+				},
+			}},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				HTTPGet: &v1.HTTPGetAction{
+					Path:        "/fail",
+					Port:        intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+					Host:        "127.0.0.1",
+					Scheme:      "HTTP",
+					HTTPHeaders: nil,
+				},
+			}},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				HTTPPost: &prober_v1.HTTPPostAction{
+					Path:        "/post-demo",
+					Port:        intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+					Host:        "127.0.0.1",
+					Scheme:      "HTTP",
+					HTTPHeaders: nil,
+					Body:        `{"expectedCode":"200","expectedResponse":"success"}`,
+				},
+			}},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				HTTPPost: &prober_v1.HTTPPostAction{
+					Path:        "/post-demo",
+					Port:        intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+					Host:        "127.0.0.1",
+					Scheme:      "HTTP",
+					HTTPHeaders: nil,
+					Body:        `{"expectedCode":"400","expectedResponse":"failure"}`,
+				},
+			}},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				HTTPPost: &prober_v1.HTTPPostAction{
+					Path:        "/post-demo",
+					Port:        intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+					Host:        "127.0.0.1",
+					Scheme:      "HTTP",
+					HTTPHeaders: nil,
+					Form: &url.Values{
+						"expectedResponse": {"success"},
+						"expectedCode":     {"202"},
+					},
+				},
+			}},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				HTTPPost: &prober_v1.HTTPPostAction{
+					Path:        "/post-demo",
+					Port:        intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+					Host:        "127.0.0.1",
+					Scheme:      "HTTP",
+					HTTPHeaders: nil,
+					Form: &url.Values{
+						"expectedResponse": {"failure"},
+						"expectedCode":     {"404"},
+					},
+				},
+			}},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				HTTPGet: &v1.HTTPGetAction{
+					Path:   "/success",
+					Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8443},
+					Host:   "127.0.0.1",
+					Scheme: "HTTPS",
+				},
+			}},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPGet: &v1.HTTPGetAction{
+						Path:   "/success",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8443},
+						Host:   "127.0.0.1",
+						Scheme: "HTTPS",
+					},
+				},
+				// run-client serves a self-signed certificate by default,
+				// so this fails unless --tls-ca-file points to it.
+				TLS: &api_v1.TLSConfig{
+					ServerName: "localhost",
+					MinVersion: "1.2",
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				HTTPPost: &prober_v1.HTTPPostAction{
+					Path:   "/post-demo",
+					Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8443},
+					Host:   "127.0.0.1",
+					Scheme: "HTTPS",
+					Body:   `{"expectedCode":"200","expectedResponse":"success"}`,
+				},
+			}},
+		},
 		{
-			HTTPGet: &v1.HTTPGetAction{
-				Path:        "/success",
-				Port:        intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
-				Host:        "127.0.0.1",
-				Scheme:      "HTTP",
-				HTTPHeaders: nil,
+			expect: api.Success,
+			handler: api_v1.Handler{
+				TLSCert: &api_v1.TLSCertAction{
+					Port: intstr.IntOrString{Type: intstr.String, StrVal: "https-server"},
+					Host: "127.0.0.1",
+				},
 			},
 		},
 		{
-			HTTPGet: &v1.HTTPGetAction{
-				Path:        "/fail",
-				Port:        intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
-				Host:        "127.0.0.1",
-				Scheme:      "HTTP",
-				HTTPHeaders: nil,
+			expect: api.Warning,
+			handler: api_v1.Handler{
+				// the self-signed certificate of run-client is valid for a year.
+				TLSCert: &api_v1.TLSCertAction{
+					Port:       intstr.IntOrString{Type: intstr.String, StrVal: "https-server"},
+					Host:       "127.0.0.1",
+					WarnBefore: &metav1.Duration{Duration: 2 * 365 * 24 * time.Hour},
+				},
 			},
 		},
 		{
-			HTTPPost: &prober_v1.HTTPPostAction{
-				Path:        "/post-demo",
-				Port:        intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
-				Host:        "127.0.0.1",
-				Scheme:      "HTTP",
-				HTTPHeaders: nil,
-				Body:        `{"expectedCode":"200","expectedResponse":"success"}`,
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				TLSCert: &api_v1.TLSCertAction{
+					Port: intstr.IntOrString{Type: intstr.Int, IntVal: 8443},
+					Host: "127.0.0.1",
+				},
+				TLS: &api_v1.TLSConfig{
+					ServerName: "example.com",
+				},
 			},
 		},
 		{
-			HTTPPost: &prober_v1.HTTPPostAction{
-				Path:        "/post-demo",
-				Port:        intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
-				Host:        "127.0.0.1",
-				Scheme:      "HTTP",
-				HTTPHeaders: nil,
-				Body:        `{"expectedCode":"400","expectedResponse":"failure"}`,
+			expect: api.Success,
+			handler: api_v1.Handler{
+				GRPC: &api_v1.GRPCAction{
+					Port: intstr.IntOrString{Type: intstr.String, StrVal: "grpc-server"},
+					Host: "127.0.0.1",
+				},
 			},
 		},
 		{
-			HTTPPost: &prober_v1.HTTPPostAction{
-				Path:        "/post-demo",
-				Port:        intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
-				Host:        "127.0.0.1",
-				Scheme:      "HTTP",
-				HTTPHeaders: nil,
-				Form: &url.Values{
-					"expectedResponse": {"success"},
-					"expectedCode":     {"202"},
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				GRPC: &api_v1.GRPCAction{
+					Port:    intstr.IntOrString{Type: intstr.Int, IntVal: 9095},
+					Host:    "127.0.0.1",
+					Service: "unhealthy",
 				},
 			},
 		},
 		{
-			HTTPPost: &prober_v1.HTTPPostAction{
-				Path:        "/post-demo",
-				Port:        intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
-				Host:        "127.0.0.1",
-				Scheme:      "HTTP",
-				HTTPHeaders: nil,
-				Form: &url.Values{
-					"expectedResponse": {"failure"},
-					"expectedCode":     {"404"},
+			expect: api.Success,
+			handler: api_v1.Handler{
+				GRPC: &api_v1.GRPCAction{
+					Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8443},
+					Host:   "127.0.0.1",
+					Scheme: "HTTPS",
 				},
 			},
 		},
 		{
-			TCPSocket: &v1.TCPSocketAction{
-				Port: intstr.IntOrString{Type: intstr.Int, IntVal: 9090},
-				Host: "127.0.0.1",
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPPost: &prober_v1.HTTPPostAction{
+						Path:   "/post-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+						Body:   `{"expectedCode":"200","expectedResponse":"success"}`,
+					},
+				},
+				HTTPAssertions: &api_v1.HTTPAssertions{
+					BodyEquals: stringPtr("success"),
+					Headers:    []api_v1.HeaderAssertion{{Name: "Content-Length", Value: "7"}},
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPPost: &prober_v1.HTTPPostAction{
+						Path:   "/post-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+						Body:   `{"expectedCode":"200","expectedResponse":"failure"}`,
+					},
+				},
+				HTTPAssertions: &api_v1.HTTPAssertions{
+					BodyContains: "success",
+				},
 			},
 		},
 		{
-			TCPSocket: &v1.TCPSocketAction{
-				Port: intstr.IntOrString{Type: intstr.Int, IntVal: 9091},
-				Host: "127.0.0.1",
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPPost: &prober_v1.HTTPPostAction{
+						Path:   "/post-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+						Body:   `{"expectedCode":"503","expectedResponse":"{\"status\":\"maintenance\",\"retry\":[30]}"}`,
+					},
+				},
+				HTTPAssertions: &api_v1.HTTPAssertions{
+					StatusCodes: []int{200, 503},
+					BodyRegex:   `"status":\s*"\w+"`,
+					JSONPath: []api_v1.JSONPathAssertion{
+						{Path: "$.status", Value: "maintenance"},
+						{Path: "$.retry[0]", Value: "30"},
+					},
+				},
 			},
 		},
 		{
-			Exec: &v1.ExecAction{
-				Command: []string{"/bin/sh", "-c", `exit $EXIT_CODE_SUCCESS`},
+			expect: api.Success,
+			handler: api_v1.Handler{
+				HTTP: &api_v1.HTTPAction{
+					Method: "PUT",
+					HTTPPostAction: prober_v1.HTTPPostAction{
+						Path:   "/post-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+						Body:   `{"expectedCode":"201","expectedResponse":"created"}`,
+					},
+				},
 			},
 		},
 		{
-			Exec: &v1.ExecAction{
-				Command: []string{"/bin/sh", "-c", `exit $EXIT_CODE_FAIL`},
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				HTTP: &api_v1.HTTPAction{
+					Method: "PATCH",
+					HTTPPostAction: prober_v1.HTTPPostAction{
+						Path:   "/post-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+						Form: &url.Values{
+							"expectedResponse": {"conflict"},
+							"expectedCode":     {"409"},
+						},
+					},
+				},
 			},
 		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				HTTP: &api_v1.HTTPAction{
+					Method: "DELETE",
+					HTTPPostAction: prober_v1.HTTPPostAction{
+						Path:   "/post-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+						Body:   `{"expectedCode":"204","expectedResponse":""}`,
+					},
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				HTTP: &api_v1.HTTPAction{
+					Method: "HEAD",
+					HTTPPostAction: prober_v1.HTTPPostAction{
+						Path:   "/success",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+					},
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				HTTP: &api_v1.HTTPAction{
+					Method: "HEAD",
+					HTTPPostAction: prober_v1.HTTPPostAction{
+						Path:   "/fail",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+					},
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				HTTP: &api_v1.HTTPAction{
+					Method: "OPTIONS",
+					HTTPPostAction: prober_v1.HTTPPostAction{
+						Path:   "/post-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+					},
+				},
+				HTTPAssertions: &api_v1.HTTPAssertions{
+					StatusCodes: []int{204},
+					Headers:     []api_v1.HeaderAssertion{{Name: "Allow"}},
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPPost: &prober_v1.HTTPPostAction{
+						Path:   "/post-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+						Body:   ` {"expectedCode":"202","expectedResponse":"accepted"}`,
+					},
+				},
+				HTTPBody: &api_v1.HTTPBodySource{
+					ContentType: "application/json; charset=utf-8",
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPPost: &prober_v1.HTTPPostAction{
+						Path:   "/post-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+					},
+				},
+				HTTPBody: &api_v1.HTTPBodySource{
+					ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+						Key:                  "body",
+					},
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPPost: &prober_v1.HTTPPostAction{
+						Path:   "/post-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+					},
+				},
+				// run-client echoes plain text bodies, the reason shows it redacted.
+				HTTPBody: &api_v1.HTTPBodySource{
+					ContentType: "text/plain",
+					SecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+						Key:                  "token",
+					},
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPGet: &v1.HTTPGetAction{
+						Path:   "/auth-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+					},
+				},
+				HTTPAuth: &api_v1.HTTPAuth{
+					Bearer: &api_v1.ValueSource{
+						SecretKeyRef: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+							Key:                  "token",
+						},
+					},
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPGet: &v1.HTTPGetAction{
+						Path:   "/auth-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+					},
+				},
+				HTTPAuth: &api_v1.HTTPAuth{
+					Basic: &api_v1.BasicAuth{
+						Username: "demo",
+						Password: api_v1.ValueSource{
+							SecretKeyRef: &v1.SecretKeySelector{
+								LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+								Key:                  "token",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPGet: &v1.HTTPGetAction{
+						Path:   "/auth-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+					},
+				},
+				// the token is sent in a header that run-client doesn't check.
+				HTTPHeadersFrom: []api_v1.HTTPHeaderSource{
+					{
+						Name: "X-Demo-Token",
+						ValueFrom: api_v1.ValueSource{
+							SecretKeyRef: &v1.SecretKeySelector{
+								LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+								Key:                  "token",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPPost: &prober_v1.HTTPPostAction{
+						Path:   "/post-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+					},
+				},
+				HTTPBody: &api_v1.HTTPBodySource{
+					Multipart: []api_v1.MultipartPart{
+						{Name: "expectedCode", Value: "200"},
+						{Name: "expectedResponse", Value: "multipart"},
+						{Name: "attachment", File: "/etc/hosts", ContentType: "text/plain"},
+					},
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPPost: &prober_v1.HTTPPostAction{
+						Path:   "/post-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+						Body:   `<demo><expectedCode>200</expectedCode><expectedResponse>xml</expectedResponse></demo>`,
+					},
+				},
+				HTTPBody: &api_v1.HTTPBodySource{
+					ContentType: "application/xml",
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPPost: &prober_v1.HTTPPostAction{
+						Path:   "/post-demo",
+						Port:   intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host:   "127.0.0.1",
+						Scheme: "HTTP",
+					},
+				},
+				HTTPBody: &api_v1.HTTPBodySource{
+					Protobuf: `{"expectedCode":"500","expectedResponse":"protobuf"}`,
+				},
+			},
+		},
+		// local redirects are followed, up to 10 of them.
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				HTTPGet: &v1.HTTPGetAction{
+					Path: "/redirect/3",
+					Port: intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+					Host: "127.0.0.1",
+				},
+			}},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				HTTPGet: &v1.HTTPGetAction{
+					Path: "/redirect/11",
+					Port: intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+					Host: "127.0.0.1",
+				},
+			}},
+		},
+		// a redirect to another host is not followed, it results in a warning.
+		{
+			expect: api.Warning,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				HTTPGet: &v1.HTTPGetAction{
+					Path: "/absolute-redirect",
+					Port: intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+					Host: "127.0.0.1",
+				},
+			}},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPGet: &v1.HTTPGetAction{
+						Path: "/absolute-redirect",
+						Port: intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host: "127.0.0.1",
+					},
+				},
+				HTTPRedirects: &api_v1.HTTPRedirectPolicy{FollowNonLocal: true},
+			},
+		},
+		// any redirect is a failure.
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPGet: &v1.HTTPGetAction{
+						Path: "/redirect/1",
+						Port: intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host: "127.0.0.1",
+					},
+				},
+				HTTPRedirects: &api_v1.HTTPRedirectPolicy{MaxRedirects: int32Ptr(0)},
+				HTTPStatusResults: []api_v1.HTTPStatusResult{
+					{Codes: []string{"300-399"}, Result: api.Failure},
+				},
+			},
+		},
+		// a service in maintenance is only a warning.
+		{
+			expect: api.Warning,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPGet: &v1.HTTPGetAction{
+						Path: "/status/503",
+						Port: intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+						Host: "127.0.0.1",
+					},
+				},
+				HTTPStatusResults: []api_v1.HTTPStatusResult{
+					{Codes: []string{"503"}, Result: api.Warning},
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				HTTPGet: &v1.HTTPGetAction{
+					Path: "/status/503",
+					Port: intstr.IntOrString{Type: intstr.Int, IntVal: 8080},
+					Host: "127.0.0.1",
+				},
+			}},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				TCPSocket: &v1.TCPSocketAction{
+					Port: intstr.IntOrString{Type: intstr.Int, IntVal: 9090},
+					Host: "127.0.0.1",
+				},
+			}},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				TCPSocket: &v1.TCPSocketAction{
+					Port: intstr.IntOrString{Type: intstr.Int, IntVal: 9091},
+					Host: "127.0.0.1",
+				},
+			}},
+		},
+		// the banner must be received, the connection alone is not enough.
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					TCPSocket: &v1.TCPSocketAction{
+						Port: intstr.FromString("tcp-session"),
+						Host: "127.0.0.1",
+					},
+				},
+				TCPExchange: &api_v1.TCPExchange{
+					ExpectRegex: `^220 `,
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					TCPSocket: &v1.TCPSocketAction{
+						Port: intstr.FromString("tcp-session"),
+						Host: "127.0.0.1",
+					},
+				},
+				TCPExchange: &api_v1.TCPExchange{
+					Send:        `PING\r\n`,
+					ExpectBytes: `PONG\r\n`,
+				},
+			},
+		},
+		// a server that accepts but never answers fails after the read timeout.
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					TCPSocket: &v1.TCPSocketAction{
+						Port: intstr.FromString("tcp-silent"),
+						Host: "127.0.0.1",
+					},
+				},
+				TCPExchange: &api_v1.TCPExchange{
+					Send:        `PING\r\n`,
+					ExpectBytes: `PONG`,
+					ReadTimeout: &metav1.Duration{Duration: 2 * time.Second},
+				},
+			},
+		},
+		// connecting succeeds whatever the server does next.
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				TCPSocket: &v1.TCPSocketAction{
+					Port: intstr.FromString("tcp-silent"),
+					Host: "127.0.0.1",
+				},
+			}},
+		},
+		// the accept queue is full, connecting times out.
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				TCPSocket: &v1.TCPSocketAction{
+					Port: intstr.FromString("tcp-refuse"),
+					Host: "127.0.0.1",
+				},
+			}},
+		},
+		// the server resets the connections it accepts, the probe fails with ConnectionReset.
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					TCPSocket: &v1.TCPSocketAction{
+						Port: intstr.FromString("tcp-reset"),
+						Host: "127.0.0.1",
+					},
+				},
+				TCPExchange: &api_v1.TCPExchange{
+					Send:        `PING\r\n`,
+					ExpectBytes: `PONG`,
+				},
+			},
+		},
+		// the .invalid TLD never resolves, the probe fails with NXDomain.
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				HTTPGet: &v1.HTTPGetAction{
+					Path: "/success",
+					Port: intstr.FromInt(8080),
+					Host: "does-not-exist.invalid",
+				},
+			}},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				UDP: &api_v1.UDPAction{
+					Port:        intstr.FromString("udp-echo"),
+					Host:        "127.0.0.1",
+					Send:        `ping\n`,
+					ExpectBytes: `ping`,
+				},
+			},
+		},
+		// no reply is expected, only an unreachable port fails.
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				UDP: &api_v1.UDPAction{
+					Port: intstr.FromString("udp-ignore"),
+					Host: "127.0.0.1",
+					Send: `prober-demo.probe:1|c`,
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				UDP: &api_v1.UDPAction{
+					Port: intstr.IntOrString{Type: intstr.Int, IntVal: 9102},
+					Host: "127.0.0.1",
+					Send: `prober-demo.probe:1|c`,
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				DNS: &api_v1.DNSAction{
+					Name:   "multi.prober-demo.test",
+					Server: "127.0.0.1:5353",
+					Expect: []string{"127.0.0.1", "127.0.0.2"},
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				DNS: &api_v1.DNSAction{
+					Name:   "_http._tcp.prober-demo.test",
+					Type:   "SRV",
+					Server: "127.0.0.1:5353",
+					Expect: []string{"10 5 8080 prober-demo.test."},
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				DNS: &api_v1.DNSAction{
+					Name:   "missing.prober-demo.test",
+					Server: "127.0.0.1:5353",
+				},
+			},
+		},
+		{
+			expect: api.Warning,
+			handler: api_v1.Handler{
+				DNS: &api_v1.DNSAction{
+					Name:        "slow.prober-demo.test",
+					Server:      "127.0.0.1:5353",
+					WarnLatency: &metav1.Duration{Duration: 100 * time.Millisecond},
+					MaxLatency:  &metav1.Duration{Duration: 800 * time.Millisecond},
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				DNS: &api_v1.DNSAction{
+					Name:       "slow.prober-demo.test",
+					Server:     "127.0.0.1:5353",
+					MaxLatency: &metav1.Duration{Duration: 100 * time.Millisecond},
+				},
+			},
+		},
+		{
+			expect: clusterDNS,
+			handler: api_v1.Handler{
+				DNS: &api_v1.DNSAction{
+					Name: "kubernetes.default.svc.cluster.local",
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Postgres: &api_v1.DatabaseAction{
+					Port:     intstr.FromString("postgres"),
+					Host:     "127.0.0.1",
+					Database: "prober-demo",
+					Username: "prober",
+					Password: &api_v1.ValueSource{
+						SecretKeyRef: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+							Key:                  "db-password",
+						},
+					},
+					Query:           "SELECT 1",
+					RequireWritable: true,
+				},
+			},
+		},
+		// the token is not the password of the database user.
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Postgres: &api_v1.DatabaseAction{
+					Port:     intstr.FromString("postgres"),
+					Host:     "127.0.0.1",
+					Database: "prober-demo",
+					Username: "prober",
+					Password: &api_v1.ValueSource{
+						SecretKeyRef: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+							Key:                  "token",
+						},
+					},
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Postgres: &api_v1.DatabaseAction{
+					Port:     intstr.FromString("postgres"),
+					Host:     "127.0.0.1",
+					Database: "replica",
+					Username: "prober",
+					Password: &api_v1.ValueSource{
+						SecretKeyRef: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+							Key:                  "db-password",
+						},
+					},
+					RequireWritable: true,
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Postgres: &api_v1.DatabaseAction{
+					Port:     intstr.FromString("postgres"),
+					Host:     "127.0.0.1",
+					Database: "starting-up",
+					Username: "prober",
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				MySQL: &api_v1.DatabaseAction{
+					Port:     intstr.FromString("mysql"),
+					Host:     "127.0.0.1",
+					Database: "prober-demo",
+					Username: "prober",
+					Password: &api_v1.ValueSource{
+						SecretKeyRef: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+							Key:                  "db-password",
+						},
+					},
+					Query: "SELECT @@version",
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				MySQL: &api_v1.DatabaseAction{
+					Port:     intstr.FromString("mysql"),
+					Host:     "127.0.0.1",
+					Database: "readonly",
+					Username: "prober",
+					Password: &api_v1.ValueSource{
+						SecretKeyRef: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+							Key:                  "db-password",
+						},
+					},
+					RequireWritable: true,
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				MySQL: &api_v1.DatabaseAction{
+					Port:     intstr.FromString("mysql"),
+					Host:     "127.0.0.1",
+					Database: "busy",
+					Username: "prober",
+					Password: &api_v1.ValueSource{
+						SecretKeyRef: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+							Key:                  "db-password",
+						},
+					},
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Redis: &api_v1.RedisAction{
+					Port: intstr.FromString("redis"),
+					Host: "127.0.0.1",
+					Password: &api_v1.ValueSource{
+						SecretKeyRef: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+							Key:                  "db-password",
+						},
+					},
+					Role: "master",
+				},
+			},
+		},
+		// the replica listener reports the replica role.
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Redis: &api_v1.RedisAction{
+					Port: intstr.FromString("redis-replica"),
+					Host: "127.0.0.1",
+					Password: &api_v1.ValueSource{
+						SecretKeyRef: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+							Key:                  "db-password",
+						},
+					},
+					Role: "master",
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Redis: &api_v1.RedisAction{
+					Port: intstr.FromString("redis"),
+					Host: "127.0.0.1",
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Redis: &api_v1.RedisAction{
+					Port: intstr.FromString("redis-loading"),
+					Host: "127.0.0.1",
+					Password: &api_v1.ValueSource{
+						SecretKeyRef: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+							Key:                  "db-password",
+						},
+					},
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				MongoDB: &api_v1.MongoDBAction{
+					Port:        intstr.FromString("mongodb"),
+					Host:        "127.0.0.1",
+					PrimaryOnly: true,
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				MongoDB: &api_v1.MongoDBAction{
+					Port:        intstr.FromString("mongodb-second"),
+					Host:        "127.0.0.1",
+					PrimaryOnly: true,
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				MongoDB: &api_v1.MongoDBAction{
+					Port: intstr.FromString("mongodb-legacy"),
+					Host: "127.0.0.1",
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Composite: &api_v1.CompositeAction{
+					AllOf: []api_v1.Handler{
+						{Handler: prober_v1.Handler{
+							HTTPGet: &v1.HTTPGetAction{
+								Path: "/success",
+								Port: intstr.FromInt(8080),
+								Host: "127.0.0.1",
+							},
+						}},
+						{MongoDB: &api_v1.MongoDBAction{Port: intstr.FromString("mongodb"), Host: "127.0.0.1", PrimaryOnly: true}},
+						{MongoDB: &api_v1.MongoDBAction{Port: intstr.FromString("mongodb-second"), Host: "127.0.0.1", PrimaryOnly: true}},
+					},
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Composite: &api_v1.CompositeAction{
+					AnyOf: []api_v1.Handler{
+						{MongoDB: &api_v1.MongoDBAction{Port: intstr.FromString("mongodb-second"), Host: "127.0.0.1", PrimaryOnly: true}},
+						{MongoDB: &api_v1.MongoDBAction{Port: intstr.FromString("mongodb"), Host: "127.0.0.1", PrimaryOnly: true}},
+					},
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Composite: &api_v1.CompositeAction{
+					Sequence: []api_v1.Handler{
+						{Handler: prober_v1.Handler{
+							HTTPGet: &v1.HTTPGetAction{
+								Path: "/success",
+								Port: intstr.FromInt(8080),
+								Host: "127.0.0.1",
+							},
+						}},
+						{Composite: &api_v1.CompositeAction{
+							AnyOf: []api_v1.Handler{
+								{Redis: &api_v1.RedisAction{Port: intstr.FromString("redis-loading"), Host: "127.0.0.1", Password: dbPassword}},
+								{Redis: &api_v1.RedisAction{Port: intstr.FromString("redis-replica"), Host: "127.0.0.1", Password: dbPassword, Role: "master"}},
+							},
+						}},
+						{Handler: prober_v1.Handler{
+							HTTPGet: &v1.HTTPGetAction{
+								Path: "/fail",
+								Port: intstr.FromInt(8080),
+								Host: "127.0.0.1",
+							},
+						}},
+					},
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				HTTPScenario: &api_v1.HTTPScenarioAction{
+					Port: intstr.FromInt(8080),
+					Host: "127.0.0.1",
+					Variables: []api_v1.HTTPScenarioVariable{
+						{
+							Name: "password",
+							ValueFrom: api_v1.ValueSource{
+								SecretKeyRef: &v1.SecretKeySelector{
+									LocalObjectReference: v1.LocalObjectReference{Name: "prober-demo"},
+									Key:                  "token",
+								},
+							},
+						},
+					},
+					Steps: []api_v1.HTTPScenarioStep{
+						{
+							Name:        "login",
+							Method:      "POST",
+							Path:        "/login",
+							HTTPHeaders: []v1.HTTPHeader{{Name: "Content-Type", Value: "application/json"}},
+							Body:        `{"username":"prober","password":"${password}"}`,
+							Assertions:  &api_v1.HTTPAssertions{StatusCodes: []int{200}},
+							Capture:     []api_v1.HTTPCapture{{Name: "token", JSONPath: "$.token", Secret: true}},
+						},
+						{
+							Name:        "me",
+							Path:        "/me",
+							HTTPHeaders: []v1.HTTPHeader{{Name: "Authorization", Value: "Bearer ${token}"}},
+							Assertions: &api_v1.HTTPAssertions{
+								JSONPath: []api_v1.JSONPathAssertion{{Path: "$.username", Value: "prober"}},
+							},
+						},
+						{
+							Name:   "logout",
+							Method: "POST",
+							Path:   "/logout",
+						},
+					},
+				},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				HTTPScenario: &api_v1.HTTPScenarioAction{
+					Port: intstr.FromInt(8080),
+					Host: "127.0.0.1",
+					Steps: []api_v1.HTTPScenarioStep{
+						{
+							Name:        "login with a wrong password",
+							Method:      "POST",
+							Path:        "/login",
+							Body:        "username=prober&password=wrong",
+							HTTPHeaders: []v1.HTTPHeader{{Name: "Content-Type", Value: "application/x-www-form-urlencoded"}},
+							Capture:     []api_v1.HTTPCapture{{Name: "token", JSONPath: "$.token", Secret: true}},
+						},
+						{
+							Name:        "me",
+							Path:        "/me",
+							HTTPHeaders: []v1.HTTPHeader{{Name: "Authorization", Value: "Bearer ${token}"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					HTTPGet: &v1.HTTPGetAction{
+						Path: "/flaky/2",
+						Port: intstr.FromInt(8080),
+						Host: "127.0.0.1",
+					},
+				},
+				Retries: 3,
+				RetryBackoff: &api_v1.RetryBackoff{
+					Type:  "exponential",
+					Delay: &metav1.Duration{Duration: 200 * time.Millisecond},
+				},
+				RetryOn: []string{"502-504", "connectionError", "timeout"},
+			},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{
+				Handler: prober_v1.Handler{
+					TCPSocket: &v1.TCPSocketAction{
+						Port: intstr.FromInt(9091),
+						Host: "127.0.0.1",
+					},
+				},
+				Retries: 2,
+				RetryBackoff: &api_v1.RetryBackoff{
+					Delay: &metav1.Duration{Duration: 500 * time.Millisecond},
+				},
+				RetryOn: []string{"connectionError"},
+			},
+		},
+		{
+			expect: api.Success,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				Exec: &v1.ExecAction{
+					Command: []string{"/bin/sh", "-c", `exit $EXIT_CODE_SUCCESS`},
+				},
+			}},
+		},
+		{
+			expect: api.Failure,
+			handler: api_v1.Handler{Handler: prober_v1.Handler{
+				Exec: &v1.ExecAction{
+					Command: []string{"/bin/sh", "-c", `exit $EXIT_CODE_FAIL`},
+				},
+			}},
+		},
 	}
+}
 
-	kubeClient := kubernetes.NewForConfigOrDie(config)
-	pod, err := kubeClient.CoreV1().Pods("default").Get("prober-demo", metav1.GetOptions{})
-	if err != nil {
-		return err
-	}
-	status := pod.Status
-	container := pod.Spec.Containers[0]
-
-	pb := probe.NewProber(config)
-
-	for i := range probes {
-		fmt.Printf("============== Probe No: %d =================\n", i)
-		result, ss, err := pb.RunProbe(&probes[i], pod, status, container, time.Second*30)
-		if err != nil {
-			return err
-		}
-		fmt.Printf("Result: %v\nReason: %v\n", result, ss)
-	}
+func stringPtr(s string) *string {
+	return &s
+}
 
-	return nil
+func int32Ptr(i int32) *int32 {
+	return &i
 }
@@ -0,0 +1,418 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/rest"
+	api "kmodules.xyz/prober/api"
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+	"stash.appscode.dev/prober-demo/pkg/mysql"
+	"stash.appscode.dev/prober-demo/pkg/postgres"
+	"stash.appscode.dev/prober-demo/pkg/probe"
+	execprobe "stash.appscode.dev/prober-demo/pkg/probe/exec"
+	"stash.appscode.dev/prober-demo/pkg/probe/failure"
+)
+
+// selftestPort is a port of the prober-demo pod of hack/prober-demo.yaml.
+// Ports without a name are closed on purpose, the demo probes them to fail.
+type selftestPort struct {
+	name     string
+	port     int
+	protocol v1.Protocol
+}
+
+var selftestPorts = []selftestPort{
+	{"http-server", 8080, v1.ProtocolTCP},
+	{"https-server", 8443, v1.ProtocolTCP},
+	{"tcp-server", 9090, v1.ProtocolTCP},
+	{"tcp-echo", 9092, v1.ProtocolTCP},
+	{"tcp-banner", 9093, v1.ProtocolTCP},
+	{"tcp-script", 9094, v1.ProtocolTCP},
+	{"tcp-session", 9099, v1.ProtocolTCP},
+	{"tcp-silent", 9096, v1.ProtocolTCP},
+	{"tcp-reset", 9097, v1.ProtocolTCP},
+	{"tcp-refuse", 9098, v1.ProtocolTCP},
+	{"grpc-server", 9095, v1.ProtocolTCP},
+	{"dns", 5353, v1.ProtocolUDP},
+	{"dns-tcp", 5353, v1.ProtocolTCP},
+	{"udp-echo", 9100, v1.ProtocolUDP},
+	{"udp-ignore", 9101, v1.ProtocolUDP},
+	{"postgres", 5432, v1.ProtocolTCP},
+	{"mysql", 3306, v1.ProtocolTCP},
+	{"redis", 6379, v1.ProtocolTCP},
+	{"redis-replica", 6380, v1.ProtocolTCP},
+	{"redis-loading", 6381, v1.ProtocolTCP},
+	{"mongodb", 27017, v1.ProtocolTCP},
+	{"mongodb-second", 27018, v1.ProtocolTCP},
+	{"mongodb-legacy", 27019, v1.ProtocolTCP},
+	{"", 9091, v1.ProtocolTCP},
+	{"", 9102, v1.ProtocolUDP},
+}
+
+// The Secret and ConfigMap of hack/prober-demo.yaml, the only API objects the demo probes read.
+var (
+	selftestSecretData = map[string]string{
+		"token":       "s3cr3t-demo-token",
+		"db-password": "s3cr3t-db-password",
+	}
+	selftestConfigMapData = map[string]string{
+		"body": `{"expectedCode":"200","expectedResponse":"success"}`,
+	}
+)
+
+type selftestOptions struct {
+	timeout time.Duration
+	verbose bool
+}
+
+func NewCmdSelftest() *cobra.Command {
+	opt := selftestOptions{}
+	cmd := &cobra.Command{
+		Use:   "selftest",
+		Short: "run the demo probes against in-process servers and check their results",
+		Long: "Start the servers of run-client on free local ports, run the probes of run-probe against them, " +
+			"with exec probes run locally, and check that every probe gives the expected result.",
+		// a failed selftest is not a usage error.
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSelftest(opt, os.Stdout)
+		},
+	}
+	cmd.Flags().DurationVar(&opt.timeout, "timeout", 5*time.Second, "Timeout of each probe.")
+	cmd.Flags().BoolVarP(&opt.verbose, "verbose", "v", false, "Show the reason of every probe and the output of the servers.")
+	return cmd
+}
+
+// runSelftest writes the result of every probe to out, and the output of the servers too if verbose.
+func runSelftest(opt selftestOptions, out io.Writer) error {
+	probes := demoProbes()
+	for i := range probes {
+		if probes[i].expect == "" {
+			return fmt.Errorf("demo probe %d has no expected result", i)
+		}
+	}
+
+	// the servers print every request they receive.
+	serverOut := out
+	if !opt.verbose {
+		serverOut = ioutil.Discard
+		log.SetOutput(ioutil.Discard)
+		defer log.SetOutput(os.Stderr)
+	}
+
+	ports, err := allocatePorts()
+	if err != nil {
+		return err
+	}
+	certDir, err := ioutil.TempDir("", "prober-selftest")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(certDir)
+
+	// the servers check the credentials of the demo Secret.
+	os.Setenv("AUTH_TOKEN", selftestSecretData["token"])
+	os.Setenv(databasePasswordEnv, selftestSecretData["db-password"])
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+	err = startServers(clientOptions{
+		httpAddr:     localAddr(":8080", ports),
+		httpsAddr:    localAddr(":8443", ports),
+		grpcAddr:     localAddr(":9095", ports),
+		certDir:      certDir,
+		out:          serverOut,
+		tcpListeners: localListeners(defaultTCPListeners, ports),
+		tcpBanner:    defaultTCPBanner,
+		udpListeners: localListeners(defaultUDPListeners, ports),
+		dnsAddr:      localAddr(":5353", ports),
+		postgresAddr: localAddr(":5432", ports),
+		postgresAuth: postgres.AuthSCRAM,
+		mysqlAddr:    localAddr(":3306", ports),
+		mysqlAuth:    mysql.AuthCachingSHA2,
+		// run-client takes the fast path by default, this takes the RSA key exchange.
+		mysqlCacheMiss:   true,
+		databaseUser:     "prober",
+		redisListeners:   localListeners(defaultRedisListeners, ports),
+		mongodbListeners: localListeners(defaultMongoDBListeners, ports),
+	}, &wg, done)
+	if err != nil {
+		return err
+	}
+	if err := waitForServers(ports, 10*time.Second); err != nil {
+		return err
+	}
+
+	apiServer := newSelftestAPIServer()
+	defer apiServer.Close()
+	pb := probe.NewProber(&rest.Config{Host: apiServer.URL})
+	pb.Exec = execprobe.NewLocal(opt.timeout)
+
+	container := v1.Container{
+		Name: "prober-demo",
+		Env: []v1.EnvVar{
+			{Name: "EXIT_CODE_SUCCESS", Value: "0"},
+			{Name: "EXIT_CODE_FAIL", Value: "1"},
+		},
+	}
+	for _, p := range selftestPorts {
+		if p.name != "" {
+			container.Ports = append(container.Ports, v1.ContainerPort{
+				Name:          p.name,
+				ContainerPort: int32(ports[p.port]),
+				Protocol:      p.protocol,
+			})
+		}
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "prober-demo", Namespace: "default"},
+		Spec:       v1.PodSpec{Containers: []v1.Container{container}},
+		Status:     v1.PodStatus{PodIP: "127.0.0.1"},
+	}
+
+	failed := 0
+	for i := range probes {
+		p := &probes[i].handler
+		localizePorts(p, ports)
+		report, err := pb.Run(p, pod, pod.Status, container, opt.timeout)
+		if err != nil {
+			report = &probe.Report{Result: api.Unknown, Reason: "error: " + err.Error()}
+		}
+		ok := report.Result == probes[i].expect
+		if !ok {
+			failed++
+		}
+		writeSelftestResult(out, i, p, report, probes[i].expect, ok, opt.verbose)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("selftest failed, %d of %d probes did not give the expected result", failed, len(probes))
+	}
+	fmt.Fprintf(out, "selftest passed, all %d probes gave the expected result\n", len(probes))
+	return nil
+}
+
+func writeSelftestResult(w io.Writer, i int, p *api_v1.Handler, report *probe.Report, expected api.Result, ok, verbose bool) {
+	status := "PASS"
+	if !ok {
+		status = "FAIL"
+	}
+	fmt.Fprintf(w, "%s %3d %s: %s", status, i, probe.Describe(p), report.Result)
+	if !ok {
+		fmt.Fprintf(w, ", expected %s", expected)
+	}
+	fmt.Fprintln(w)
+	if (!ok || verbose) && report.Reason != "" {
+		fmt.Fprintf(w, "         %s\n", strings.Replace(report.Reason, "\n", "\n         ", -1))
+	}
+}
+
+// allocatePorts returns a free local port for each port of selftestPorts, by number.
+func allocatePorts() (map[int]int, error) {
+	ports := map[int]int{}
+	used := map[int]bool{}
+	for _, p := range selftestPorts {
+		if _, ok := ports[p.port]; ok {
+			continue
+		}
+		port, err := freePort(used)
+		if err != nil {
+			return nil, err
+		}
+		ports[p.port] = port
+		used[port] = true
+	}
+	return ports, nil
+}
+
+// freePort returns a port of 127.0.0.1 that is free for both TCP and UDP, and not in used.
+func freePort(used map[int]bool) (int, error) {
+	for i := 0; i < 100; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return 0, err
+		}
+		port := l.Addr().(*net.TCPAddr).Port
+		conn, err := net.ListenPacket("udp", l.Addr().String())
+		l.Close()
+		if err != nil {
+			continue
+		}
+		conn.Close()
+		if !used[port] {
+			return port, nil
+		}
+	}
+	return 0, errors.New("failed to find a free local port")
+}
+
+// localAddr returns the local address of the allocated port of addr, e.g. 127.0.0.1:41234 for :8080.
+func localAddr(addr string, ports map[int]int) string {
+	_, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return addr
+	}
+	if local, ok := ports[port]; ok {
+		port = local
+	}
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+}
+
+// localListeners returns listener specs of the form MODE=ADDR with the local addresses of their ports.
+func localListeners(specs []string, ports map[int]int) []string {
+	out := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		if i := strings.Index(spec, "="); i >= 0 {
+			spec = spec[:i+1] + localAddr(spec[i+1:], ports)
+		}
+		out = append(out, spec)
+	}
+	return out
+}
+
+// waitForServers waits until the named TCP ports accept connections, or at least do not refuse them.
+// The servers listen from their own goroutines, so they may not be ready when startServers returns.
+func waitForServers(ports map[int]int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for _, p := range selftestPorts {
+		if p.name == "" || p.protocol != v1.ProtocolTCP {
+			continue
+		}
+		addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(ports[p.port]))
+		for {
+			conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+			if err == nil {
+				conn.Close()
+				break
+			}
+			// the refuse listener never accepts.
+			if failure.Classify(err) != failure.ConnectionRefused {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("server %s did not start on %s", p.name, addr)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	return nil
+}
+
+// localizePorts replaces the port numbers of p, and of its children, by their allocated ports.
+// Named ports are left as is, they are resolved against the container of selftest.
+func localizePorts(p *api_v1.Handler, ports map[int]int) {
+	localize := func(port *intstr.IntOrString) {
+		if port.Type != intstr.Int {
+			return
+		}
+		if local, ok := ports[port.IntValue()]; ok {
+			*port = intstr.FromInt(local)
+		}
+	}
+	if p.HTTPGet != nil {
+		localize(&p.HTTPGet.Port)
+	}
+	if p.HTTPPost != nil {
+		localize(&p.HTTPPost.Port)
+	}
+	if p.TCPSocket != nil {
+		localize(&p.TCPSocket.Port)
+	}
+	if p.HTTP != nil {
+		localize(&p.HTTP.Port)
+	}
+	if p.TLSCert != nil {
+		localize(&p.TLSCert.Port)
+	}
+	if p.GRPC != nil {
+		localize(&p.GRPC.Port)
+	}
+	if p.UDP != nil {
+		localize(&p.UDP.Port)
+	}
+	if p.DNS != nil && p.DNS.Server != "" {
+		p.DNS.Server = localAddr(p.DNS.Server, ports)
+	}
+	if p.Postgres != nil {
+		localize(&p.Postgres.Port)
+	}
+	if p.MySQL != nil {
+		localize(&p.MySQL.Port)
+	}
+	if p.Redis != nil {
+		localize(&p.Redis.Port)
+	}
+	if p.MongoDB != nil {
+		localize(&p.MongoDB.Port)
+	}
+	if p.HTTPScenario != nil {
+		localize(&p.HTTPScenario.Port)
+	}
+	if c := p.Composite; c != nil {
+		for _, children := range [][]api_v1.Handler{c.AllOf, c.AnyOf, c.Sequence} {
+			for i := range children {
+				localizePorts(&children[i], ports)
+			}
+		}
+	}
+}
+
+// newSelftestAPIServer serves the Secret and the ConfigMap of the demo, like the API server of a cluster does.
+func newSelftestAPIServer() *httptest.Server {
+	secretData := map[string][]byte{}
+	for k, v := range selftestSecretData {
+		secretData[k] = []byte(v)
+	}
+	objects := map[string]interface{}{
+		"/api/v1/namespaces/default/secrets/prober-demo": &v1.Secret{
+			TypeMeta:   metav1.TypeMeta{Kind: "Secret", APIVersion: "v1"},
+			ObjectMeta: metav1.ObjectMeta{Name: "prober-demo", Namespace: "default"},
+			Data:       secretData,
+		},
+		"/api/v1/namespaces/default/configmaps/prober-demo": &v1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+			ObjectMeta: metav1.ObjectMeta{Name: "prober-demo", Namespace: "default"},
+			Data:       selftestConfigMapData,
+		},
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		obj, ok := objects[r.URL.Path]
+		if !ok || r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			obj = &metav1.Status{
+				TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+				Status:   metav1.StatusFailure,
+				Reason:   metav1.StatusReasonNotFound,
+				Message:  fmt.Sprintf("%s not found", r.URL.Path),
+				Code:     http.StatusNotFound,
+			}
+		}
+		json.NewEncoder(w).Encode(obj)
+	}))
+}
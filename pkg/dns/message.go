@@ -0,0 +1,262 @@
+// Package dns implements the part of the DNS wire format (RFC 1035) needed to answer
+// A, AAAA, CNAME and SRV queries from a small authoritative zone.
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Type is the type of a resource record.
+type Type uint16
+
+const (
+	TypeA     Type = 1
+	TypeCNAME Type = 5
+	TypeAAAA  Type = 28
+	TypeSRV   Type = 33
+)
+
+var typeNames = map[Type]string{
+	TypeA:     "A",
+	TypeCNAME: "CNAME",
+	TypeAAAA:  "AAAA",
+	TypeSRV:   "SRV",
+}
+
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE%d", uint16(t))
+}
+
+// ParseType returns the Type named s, e.g. "AAAA".
+func ParseType(s string) (Type, error) {
+	for t, name := range typeNames {
+		if strings.EqualFold(name, s) {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported DNS record type %q", s)
+}
+
+// RCode is the response code of a message.
+type RCode uint16
+
+const (
+	RCodeSuccess        RCode = 0
+	RCodeFormatError    RCode = 1
+	RCodeServerFailure  RCode = 2
+	RCodeNameError      RCode = 3
+	RCodeNotImplemented RCode = 4
+	RCodeRefused        RCode = 5
+)
+
+// classINET is the only class served.
+const classINET = 1
+
+const (
+	headerLen = 12
+	// maxUDPMessage is the size of the largest message sent over UDP without EDNS0.
+	maxUDPMessage = 512
+
+	flagResponse      = 1 << 15
+	flagAuthoritative = 1 << 10
+	flagTruncated     = 1 << 9
+	flagRecursionDes  = 1 << 8
+	opcodeMask        = 0xf << 11
+)
+
+var errTruncatedMessage = errors.New("truncated DNS message")
+
+// Question is the question of a query.
+type Question struct {
+	Name string
+	Type Type
+}
+
+// Query is a parsed DNS query. Only its first question is kept, like every server does.
+type Query struct {
+	ID       uint16
+	Flags    uint16
+	Question Question
+	// raw is the question as it was sent, echoed in the response.
+	raw []byte
+}
+
+// ParseQuery parses the header and the first question of msg.
+func ParseQuery(msg []byte) (*Query, error) {
+	if len(msg) < headerLen {
+		return nil, errTruncatedMessage
+	}
+	q := &Query{
+		ID:    binary.BigEndian.Uint16(msg[0:]),
+		Flags: binary.BigEndian.Uint16(msg[2:]),
+	}
+	if q.Flags&flagResponse != 0 {
+		return nil, errors.New("not a DNS query")
+	}
+	if binary.BigEndian.Uint16(msg[4:]) != 1 {
+		return nil, errors.New("DNS query must have one question")
+	}
+	name, off, err := readName(msg, headerLen)
+	if err != nil {
+		return nil, err
+	}
+	if off+4 > len(msg) {
+		return nil, errTruncatedMessage
+	}
+	q.Question = Question{Name: name, Type: Type(binary.BigEndian.Uint16(msg[off:]))}
+	if class := binary.BigEndian.Uint16(msg[off+2:]); class != classINET {
+		return nil, fmt.Errorf("unsupported DNS class %d", class)
+	}
+	q.raw = msg[headerLen : off+4]
+	return q, nil
+}
+
+// readName reads the domain name at off, following compression pointers.
+// It returns the name in lower case with a trailing dot, and the offset following it.
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for hops := 0; ; hops++ {
+		if off >= len(msg) || hops > 64 {
+			return "", 0, errTruncatedMessage
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.ToLower(strings.Join(labels, ".")) + ".", end, nil
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) {
+				return "", 0, errTruncatedMessage
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+		case n&0xc0 != 0:
+			return "", 0, fmt.Errorf("invalid DNS label length %#x", n)
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errTruncatedMessage
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+// appendName appends name in the uncompressed wire format.
+func appendName(b []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, fmt.Errorf("invalid DNS name %q", name)
+			}
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	return append(b, 0), nil
+}
+
+// Record is a resource record of a zone.
+type Record struct {
+	Name string
+	Type Type
+	TTL  uint32
+	// IP is the address of A and AAAA records.
+	IP net.IP
+	// Target is the name that CNAME and SRV records point to.
+	Target string
+	// Priority, Weight and Port are the fields of SRV records.
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+}
+
+// String returns the record data like a probe shows answers, e.g. "10 5 8080 www.example.com.".
+func (r Record) String() string {
+	switch r.Type {
+	case TypeA, TypeAAAA:
+		return r.IP.String()
+	case TypeSRV:
+		return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, r.Target)
+	default:
+		return r.Target
+	}
+}
+
+func (r Record) appendTo(b []byte) ([]byte, error) {
+	b, err := appendName(b, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	b = appendUint16(b, uint16(r.Type))
+	b = appendUint16(b, classINET)
+	b = append(b, byte(r.TTL>>24), byte(r.TTL>>16), byte(r.TTL>>8), byte(r.TTL))
+	lenOff := len(b)
+	b = append(b, 0, 0)
+	switch r.Type {
+	case TypeA:
+		b = append(b, r.IP.To4()...)
+	case TypeAAAA:
+		b = append(b, r.IP.To16()...)
+	case TypeCNAME:
+		b, err = appendName(b, r.Target)
+	case TypeSRV:
+		b = appendUint16(b, r.Priority)
+		b = appendUint16(b, r.Weight)
+		b = appendUint16(b, r.Port)
+		b, err = appendName(b, r.Target)
+	default:
+		return nil, fmt.Errorf("unsupported DNS record type %v", r.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint16(b[lenOff:], uint16(len(b)-lenOff-2))
+	return b, nil
+}
+
+// Response builds the authoritative response to q with the given answers.
+// If the answers don't fit in a UDP message, it is truncated so that the client retries over TCP.
+// A query whose question could not be parsed is answered without question.
+func (q *Query) Response(rcode RCode, answers []Record, udp bool) ([]byte, error) {
+	flags := uint16(flagResponse|flagAuthoritative) | q.Flags&(opcodeMask|flagRecursionDes) | uint16(rcode)
+	b := make([]byte, headerLen, maxUDPMessage)
+	binary.BigEndian.PutUint16(b[0:], q.ID)
+	if q.raw != nil {
+		binary.BigEndian.PutUint16(b[4:], 1)
+		b = append(b, q.raw...)
+	}
+	count := 0
+	for _, rr := range answers {
+		next, err := rr.appendTo(b)
+		if err != nil {
+			return nil, err
+		}
+		if udp && len(next) > maxUDPMessage {
+			flags |= flagTruncated
+			break
+		}
+		b = next
+		count++
+	}
+	binary.BigEndian.PutUint16(b[2:], flags)
+	binary.BigEndian.PutUint16(b[6:], uint16(count))
+	return b, nil
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
@@ -0,0 +1,173 @@
+package dns
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/appscode/go/log"
+)
+
+// maxCNAMEChain bounds the CNAME records followed within a zone.
+const maxCNAMEChain = 8
+
+// Zone is the set of records a Server is authoritative for.
+type Zone struct {
+	// Origin is the name of the zone, with a trailing dot.
+	Origin  string
+	records map[string][]Record
+}
+
+// NewZone returns a zone with the given origin and records. Names are made fully qualified.
+func NewZone(origin string, records []Record) *Zone {
+	z := &Zone{Origin: fqdn(origin), records: map[string][]Record{}}
+	for _, rr := range records {
+		rr.Name = fqdn(rr.Name)
+		if rr.Target != "" {
+			rr.Target = fqdn(rr.Target)
+		}
+		z.records[rr.Name] = append(z.records[rr.Name], rr)
+	}
+	return z
+}
+
+func fqdn(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, ".")) + "."
+}
+
+// Lookup answers a question like an authoritative server does: REFUSED outside of the zone,
+// NXDOMAIN for unknown names, and CNAME records followed within the zone.
+func (z *Zone) Lookup(q Question) (RCode, []Record) {
+	if q.Name != z.Origin && !strings.HasSuffix(q.Name, "."+z.Origin) {
+		return RCodeRefused, nil
+	}
+	switch q.Type {
+	case TypeA, TypeAAAA, TypeCNAME, TypeSRV:
+	default:
+		// the name may exist, it just has no record of this type.
+		if _, ok := z.records[q.Name]; ok {
+			return RCodeSuccess, nil
+		}
+		return RCodeNameError, nil
+	}
+
+	var answers []Record
+	name := q.Name
+	for i := 0; i < maxCNAMEChain; i++ {
+		records, ok := z.records[name]
+		if !ok {
+			if i == 0 {
+				return RCodeNameError, nil
+			}
+			// the target of a CNAME is out of the zone, or does not exist.
+			return RCodeSuccess, answers
+		}
+		var cname *Record
+		for j := range records {
+			if records[j].Type == q.Type {
+				answers = append(answers, records[j])
+			} else if records[j].Type == TypeCNAME {
+				cname = &records[j]
+			}
+		}
+		if cname == nil || q.Type == TypeCNAME {
+			return RCodeSuccess, answers
+		}
+		answers = append(answers, *cname)
+		name = cname.Target
+	}
+	return RCodeServerFailure, nil
+}
+
+// Server answers queries from a Zone over UDP and TCP.
+type Server struct {
+	Zone *Zone
+	// Delays maps fully qualified names to how long their answers are delayed, like from a slow upstream.
+	Delays map[string]time.Duration
+}
+
+// answer returns the response to msg, or nil if msg is not a query worth answering.
+func (s *Server) answer(msg []byte, udp bool) []byte {
+	q, err := ParseQuery(msg)
+	if err != nil {
+		log.Debugf("invalid DNS query: %v", err)
+		if len(msg) < headerLen || msg[2]&0x80 != 0 {
+			return nil
+		}
+		// answer what can be parsed with FORMERR.
+		q = &Query{ID: binary.BigEndian.Uint16(msg), Flags: binary.BigEndian.Uint16(msg[2:])}
+		resp, _ := q.Response(RCodeFormatError, nil, udp)
+		return resp
+	}
+	if delay := s.Delays[strings.ToLower(q.Question.Name)]; delay > 0 {
+		time.Sleep(delay)
+	}
+	rcode, answers := RCodeNotImplemented, []Record(nil)
+	if q.Flags&opcodeMask == 0 {
+		rcode, answers = s.Zone.Lookup(q.Question)
+	}
+	resp, err := q.Response(rcode, answers, udp)
+	if err != nil {
+		log.Errorf("failed to build DNS response: %v", err)
+		resp, _ = q.Response(RCodeServerFailure, nil, udp)
+	}
+	return resp
+}
+
+// ServeUDP answers the queries received on conn until it is closed.
+func (s *Server) ServeUDP(conn net.PacketConn) error {
+	buf := make([]byte, 64<<10)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		// answered concurrently, so that a delayed answer does not hold back the others.
+		go func(msg []byte, addr net.Addr) {
+			if resp := s.answer(msg, true); resp != nil {
+				if _, err := conn.WriteTo(resp, addr); err != nil {
+					log.Errorf("failed to write DNS response to %v: %v", addr, err)
+				}
+			}
+		}(append([]byte(nil), buf[:n]...), addr)
+	}
+}
+
+// ServeTCP answers the queries received on the connections accepted by l until it is closed.
+func (s *Server) ServeTCP(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	for {
+		// idle connections are closed, like most servers do.
+		if err := conn.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+			return
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		msg := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return
+		}
+		resp := s.answer(msg, false)
+		if resp == nil {
+			return
+		}
+		binary.BigEndian.PutUint16(length[:], uint16(len(resp)))
+		if _, err := conn.Write(append(length[:], resp...)); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package dns
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func testZone() *Zone {
+	return NewZone("demo.test", []Record{
+		{Name: "www.demo.test", Type: TypeA, IP: net.ParseIP("10.0.0.1")},
+		{Name: "www.demo.test", Type: TypeA, IP: net.ParseIP("10.0.0.2")},
+		{Name: "www.demo.test", Type: TypeAAAA, IP: net.ParseIP("fd00::1")},
+		{Name: "alias.demo.test", Type: TypeCNAME, Target: "www.demo.test"},
+		{Name: "external.demo.test", Type: TypeCNAME, Target: "example.com"},
+		{Name: "loop.demo.test", Type: TypeCNAME, Target: "loop.demo.test"},
+		{Name: "_http._tcp.demo.test", Type: TypeSRV, Priority: 10, Weight: 5, Port: 8080, Target: "www.demo.test"},
+	})
+}
+
+func TestZoneLookup(t *testing.T) {
+	tests := []struct {
+		name    string
+		q       Question
+		rcode   RCode
+		answers []string
+	}{
+		{"a", Question{"www.demo.test.", TypeA}, RCodeSuccess, []string{"10.0.0.1", "10.0.0.2"}},
+		{"aaaa", Question{"www.demo.test.", TypeAAAA}, RCodeSuccess, []string{"fd00::1"}},
+		{"cname followed", Question{"alias.demo.test.", TypeA}, RCodeSuccess, []string{"www.demo.test.", "10.0.0.1", "10.0.0.2"}},
+		{"cname asked", Question{"alias.demo.test.", TypeCNAME}, RCodeSuccess, []string{"www.demo.test."}},
+		{"cname out of zone", Question{"external.demo.test.", TypeA}, RCodeSuccess, []string{"example.com."}},
+		{"cname loop", Question{"loop.demo.test.", TypeA}, RCodeServerFailure, nil},
+		{"srv", Question{"_http._tcp.demo.test.", TypeSRV}, RCodeSuccess, []string{"10 5 8080 www.demo.test."}},
+		{"no record of type", Question{"www.demo.test.", TypeSRV}, RCodeSuccess, nil},
+		{"other type of known name", Question{"www.demo.test.", Type(16)}, RCodeSuccess, nil},
+		{"unknown name", Question{"missing.demo.test.", TypeA}, RCodeNameError, nil},
+		{"other type of unknown name", Question{"missing.demo.test.", Type(16)}, RCodeNameError, nil},
+		{"out of zone", Question{"www.example.com.", TypeA}, RCodeRefused, nil},
+	}
+	zone := testZone()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rcode, answers := zone.Lookup(test.q)
+			var got []string
+			for _, rr := range answers {
+				got = append(got, rr.String())
+			}
+			if rcode != test.rcode || !reflect.DeepEqual(got, test.answers) {
+				t.Errorf("Lookup(%v) = %d %v, want %d %v", test.q, rcode, got, test.rcode, test.answers)
+			}
+		})
+	}
+}
+
+// query returns a query with a single question for name, with recursion desired.
+func query(id uint16, name string, qtype Type) []byte {
+	msg := []byte{byte(id >> 8), byte(id), 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0}
+	msg, _ = appendName(msg, name)
+	msg = appendUint16(msg, uint16(qtype))
+	return appendUint16(msg, classINET)
+}
+
+func TestParseQuery(t *testing.T) {
+	q, err := ParseQuery(query(7, "WWW.Demo.Test", TypeAAAA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.ID != 7 || q.Question != (Question{"www.demo.test.", TypeAAAA}) {
+		t.Errorf("ParseQuery() = %d %v, want 7 AAAA www.demo.test.", q.ID, q.Question)
+	}
+
+	valid := query(1, "www.demo.test", TypeA)
+	response := append([]byte(nil), valid...)
+	response[2] |= 0x80
+	twoQuestions := append([]byte(nil), valid...)
+	twoQuestions[5] = 2
+	chaos := append([]byte(nil), valid...)
+	chaos[len(chaos)-1] = 3
+	tests := []struct {
+		name string
+		msg  []byte
+	}{
+		{"short header", valid[:headerLen-1]},
+		{"truncated name", valid[:headerLen+5]},
+		{"truncated question", valid[:len(valid)-2]},
+		{"response", response},
+		{"two questions", twoQuestions},
+		{"other class", chaos},
+		{"pointer loop", append(valid[:headerLen:headerLen], 0xc0, headerLen, 0, 1, 0, 1)},
+		{"invalid label", append(valid[:headerLen:headerLen], 0x80, 0, 0, 1, 0, 1)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if q, err := ParseQuery(test.msg); err == nil {
+				t.Errorf("ParseQuery() = %v, want an error", q.Question)
+			}
+		})
+	}
+}
+
+func TestReadNameCompressed(t *testing.T) {
+	// "demo.test." at 0, then "www" pointing to it.
+	msg := []byte{4, 'd', 'e', 'm', 'o', 4, 't', 'e', 's', 't', 0, 3, 'w', 'w', 'w', 0xc0, 0}
+	name, off, err := readName(msg, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "www.demo.test." || off != len(msg) {
+		t.Errorf("readName() = %q %d, want www.demo.test. %d", name, off, len(msg))
+	}
+}
+
+func TestResponse(t *testing.T) {
+	q, err := ParseQuery(query(42, "www.demo.test", TypeA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var answers []Record
+	for i := 0; i < 40; i++ {
+		answers = append(answers, Record{Name: "www.demo.test.", Type: TypeA, TTL: 60, IP: net.IPv4(10, 0, 0, byte(i))})
+	}
+
+	tests := []struct {
+		name      string
+		udp       bool
+		count     int
+		truncated bool
+	}{
+		{"udp", true, 16, true},
+		{"tcp", false, 40, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := q.Response(RCodeSuccess, answers, test.udp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			flags := binary.BigEndian.Uint16(resp[2:])
+			if id := binary.BigEndian.Uint16(resp); id != 42 {
+				t.Errorf("ID = %d, want 42", id)
+			}
+			if flags&flagResponse == 0 || flags&flagAuthoritative == 0 || flags&flagRecursionDes == 0 {
+				t.Errorf("flags = %#x, want an authoritative response with RD echoed", flags)
+			}
+			if count := int(binary.BigEndian.Uint16(resp[6:])); count != test.count {
+				t.Errorf("answers = %d, want %d", count, test.count)
+			}
+			if truncated := flags&flagTruncated != 0; truncated != test.truncated || (test.udp && len(resp) > maxUDPMessage) {
+				t.Errorf("truncated = %v in %d bytes, want %v", truncated, len(resp), test.truncated)
+			}
+		})
+	}
+
+	if _, err := q.Response(RCodeSuccess, []Record{{Name: "www.demo.test.", Type: Type(16)}}, true); err == nil {
+		t.Error("Response() of an unsupported record succeeded")
+	}
+}
+
+func TestServerAnswer(t *testing.T) {
+	s := &Server{Zone: testZone()}
+	tests := []struct {
+		name  string
+		msg   []byte
+		rcode RCode
+	}{
+		{"query", query(1, "www.demo.test", TypeA), RCodeSuccess},
+		{"unknown name", query(2, "missing.demo.test", TypeA), RCodeNameError},
+		{"malformed", query(3, "www.demo.test", TypeA)[:headerLen+3], RCodeFormatError},
+		{"other opcode", append([]byte{0, 4, 0x10}, query(4, "www.demo.test", TypeA)[3:]...), RCodeNotImplemented},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := s.answer(test.msg, true)
+			if resp == nil {
+				t.Fatal("no response")
+			}
+			if rcode := RCode(binary.BigEndian.Uint16(resp[2:]) & 0xf); rcode != test.rcode {
+				t.Errorf("rcode = %d, want %d", rcode, test.rcode)
+			}
+		})
+	}
+	if resp := s.answer([]byte{0, 1, 0x80}, true); resp != nil {
+		t.Errorf("answer() of a short message = %v, want none", resp)
+	}
+}
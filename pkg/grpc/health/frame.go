@@ -0,0 +1,80 @@
+package health
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	ContentType = "application/grpc"
+
+	HeaderStatus  = "Grpc-Status"
+	HeaderMessage = "Grpc-Message"
+	HeaderTimeout = "Grpc-Timeout"
+
+	// maxMessageLength bounds the messages read by this package, the health messages are only a few bytes.
+	maxMessageLength = 4 * 1 << 10 // 4KB
+)
+
+// Code is a gRPC status code, as sent in the grpc-status trailer.
+type Code int
+
+// The gRPC status codes used by this package.
+const (
+	OK            Code = 0
+	Unknown       Code = 2
+	NotFound      Code = 5
+	Unimplemented Code = 12
+	Internal      Code = 13
+)
+
+var codeNames = []string{
+	"OK", "Canceled", "Unknown", "InvalidArgument", "DeadlineExceeded", "NotFound", "AlreadyExists", "PermissionDenied",
+	"ResourceExhausted", "FailedPrecondition", "Aborted", "OutOfRange", "Unimplemented", "Internal", "Unavailable",
+	"DataLoss", "Unauthenticated",
+}
+
+func (c Code) String() string {
+	if c >= 0 && int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+	return fmt.Sprintf("Code(%d)", int(c))
+}
+
+// WriteMessage writes m to w as an uncompressed length-prefixed gRPC message.
+func WriteMessage(w io.Writer, m proto.Message) error {
+	data, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	prefix := make([]byte, 5)
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+	if _, err = w.Write(prefix); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ReadMessage reads one length-prefixed gRPC message from r into m.
+func ReadMessage(r io.Reader, m proto.Message) error {
+	prefix := make([]byte, 5)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return fmt.Errorf("failed to read message prefix: %v", err)
+	}
+	if prefix[0] != 0 {
+		return fmt.Errorf("compressed messages are not supported")
+	}
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if length > maxMessageLength {
+		return fmt.Errorf("message of %d bytes exceeds the limit of %d bytes", length, maxMessageLength)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return fmt.Errorf("failed to read message: %v", err)
+	}
+	return proto.Unmarshal(data, m)
+}
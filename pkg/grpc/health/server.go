@@ -0,0 +1,75 @@
+package health
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server serves the grpc.health.v1.Health/Check RPC over HTTP/2,
+// reporting a serving status per service that can be changed at runtime.
+type Server struct {
+	mu       sync.RWMutex
+	statuses map[string]HealthCheckResponse_ServingStatus
+}
+
+// NewServer returns a Server that reports the overall health, service "", as SERVING.
+func NewServer() *Server {
+	return &Server{
+		statuses: map[string]HealthCheckResponse_ServingStatus{
+			"": HealthCheckResponse_SERVING,
+		},
+	}
+}
+
+// SetServingStatus sets the status reported for service.
+func (s *Server) SetServingStatus(service string, status HealthCheckResponse_ServingStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[service] = status
+}
+
+// ServingStatus returns the status reported for service and whether the service is known.
+func (s *Server) ServingStatus(service string) (HealthCheckResponse_ServingStatus, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status, ok := s.statuses[service]
+	return status, ok
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), ContentType) {
+		http.Error(w, "expected a gRPC request", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	if r.URL.Path != CheckPath {
+		writeStatus(w, Unimplemented, "unknown method "+r.URL.Path)
+		return
+	}
+	var req HealthCheckRequest
+	if err := ReadMessage(r.Body, &req); err != nil {
+		writeStatus(w, Internal, err.Error())
+		return
+	}
+	status, ok := s.ServingStatus(req.Service)
+	if !ok {
+		writeStatus(w, NotFound, "unknown service")
+		return
+	}
+
+	w.Header().Set("Trailer", HeaderStatus+", "+HeaderMessage)
+	w.WriteHeader(http.StatusOK)
+	if err := WriteMessage(w, &HealthCheckResponse{Status: status}); err != nil {
+		return
+	}
+	writeStatus(w, OK, "")
+}
+
+// writeStatus sets the gRPC status of a call. It is sent as a trailer if the response
+// has been started, or in the headers of a trailers-only response otherwise.
+func writeStatus(w http.ResponseWriter, code Code, message string) {
+	w.Header().Set(HeaderStatus, strconv.Itoa(int(code)))
+	w.Header().Set(HeaderMessage, message)
+}
@@ -0,0 +1,289 @@
+package mongo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// BSON element types.
+const (
+	bsonDouble    = 0x01
+	bsonString    = 0x02
+	bsonDocument  = 0x03
+	bsonArray     = 0x04
+	bsonBinary    = 0x05
+	bsonObjectID  = 0x07
+	bsonBool      = 0x08
+	bsonDateTime  = 0x09
+	bsonNull      = 0x0a
+	bsonInt32     = 0x10
+	bsonTimestamp = 0x11
+	bsonInt64     = 0x12
+)
+
+// Document is a BSON document, whose elements are kept in order as the first key of a command names it.
+// Values are float64, string, Document, []interface{}, []byte, ObjectID, bool, time.Time, nil,
+// int32, Timestamp or int64.
+type Document []Element
+
+// Element is a key and its value.
+type Element struct {
+	Key   string
+	Value interface{}
+}
+
+// ObjectID is a BSON ObjectId.
+type ObjectID [12]byte
+
+// Timestamp is a BSON timestamp, used internally by MongoDB.
+type Timestamp uint64
+
+// Lookup returns the value of key, or nil if there is none.
+func (d Document) Lookup(key string) interface{} {
+	for _, e := range d {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+	return nil
+}
+
+// String returns the string value of key, or "" if it is not a string.
+func (d Document) String(key string) string {
+	s, _ := d.Lookup(key).(string)
+	return s
+}
+
+// Bool returns the boolean value of key, or false if it is not a boolean.
+func (d Document) Bool(key string) bool {
+	b, _ := d.Lookup(key).(bool)
+	return b
+}
+
+// Number returns the numeric value of key, or 0 if it is not a number.
+func (d Document) Number(key string) float64 {
+	switch v := d.Lookup(key).(type) {
+	case float64:
+		return v
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return 0
+}
+
+var errShortDocument = errors.New("short BSON document")
+
+func appendDocument(b []byte, d Document) ([]byte, error) {
+	start := len(b)
+	b = append(b, 0, 0, 0, 0)
+	for _, e := range d {
+		var err error
+		if b, err = appendElement(b, e.Key, e.Value); err != nil {
+			return nil, err
+		}
+	}
+	b = append(b, 0)
+	binary.LittleEndian.PutUint32(b[start:], uint32(len(b)-start))
+	return b, nil
+}
+
+func appendElement(b []byte, key string, value interface{}) ([]byte, error) {
+	typeOff := len(b)
+	b = append(b, 0)
+	b = append(append(b, key...), 0)
+	var typ byte
+	var err error
+	switch v := value.(type) {
+	case float64:
+		typ = bsonDouble
+		b = appendUint64(b, math.Float64bits(v))
+	case string:
+		typ = bsonString
+		b = appendUint32(b, uint32(len(v)+1))
+		b = append(append(b, v...), 0)
+	case Document:
+		typ = bsonDocument
+		b, err = appendDocument(b, v)
+	case []interface{}:
+		typ = bsonArray
+		array := make(Document, len(v))
+		for i := range v {
+			array[i] = Element{Key: fmt.Sprint(i), Value: v[i]}
+		}
+		b, err = appendDocument(b, array)
+	case []byte:
+		typ = bsonBinary
+		b = appendUint32(b, uint32(len(v)))
+		b = append(append(b, 0), v...)
+	case ObjectID:
+		typ = bsonObjectID
+		b = append(b, v[:]...)
+	case bool:
+		typ = bsonBool
+		if v {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+	case time.Time:
+		typ = bsonDateTime
+		b = appendUint64(b, uint64(v.UnixNano()/int64(time.Millisecond)))
+	case nil:
+		typ = bsonNull
+	case int32:
+		typ = bsonInt32
+		b = appendUint32(b, uint32(v))
+	case int:
+		typ = bsonInt32
+		b = appendUint32(b, uint32(v))
+	case Timestamp:
+		typ = bsonTimestamp
+		b = appendUint64(b, uint64(v))
+	case int64:
+		typ = bsonInt64
+		b = appendUint64(b, uint64(v))
+	default:
+		return nil, fmt.Errorf("unsupported BSON value %T of %q", value, key)
+	}
+	b[typeOff] = typ
+	return b, err
+}
+
+func readDocument(b []byte) (Document, []byte, error) {
+	if len(b) < 5 {
+		return nil, nil, errShortDocument
+	}
+	n := int(binary.LittleEndian.Uint32(b))
+	if n < 5 || n > len(b) || b[n-1] != 0 {
+		return nil, nil, errShortDocument
+	}
+	rest, body := b[n:], b[4:n-1]
+	d := Document{}
+	for len(body) > 0 {
+		typ := body[0]
+		key, value, err := cString(body[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		var v interface{}
+		if v, body, err = readValue(typ, value); err != nil {
+			return nil, nil, fmt.Errorf("invalid BSON value of %q: %v", key, err)
+		}
+		d = append(d, Element{Key: key, Value: v})
+	}
+	return d, rest, nil
+}
+
+func readValue(typ byte, b []byte) (interface{}, []byte, error) {
+	fixed := func(n int) ([]byte, []byte, error) {
+		if len(b) < n {
+			return nil, nil, errShortDocument
+		}
+		return b[:n], b[n:], nil
+	}
+	switch typ {
+	case bsonDouble:
+		v, rest, err := fixed(8)
+		if err != nil {
+			return nil, nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(v)), rest, nil
+	case bsonString:
+		v, rest, err := fixed(4)
+		if err != nil {
+			return nil, nil, err
+		}
+		n := int(binary.LittleEndian.Uint32(v))
+		if n < 1 || n > len(rest) || rest[n-1] != 0 {
+			return nil, nil, errShortDocument
+		}
+		return string(rest[:n-1]), rest[n:], nil
+	case bsonDocument:
+		d, rest, err := readDocument(b)
+		return d, rest, err
+	case bsonArray:
+		d, rest, err := readDocument(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		array := make([]interface{}, len(d))
+		for i := range d {
+			array[i] = d[i].Value
+		}
+		return array, rest, nil
+	case bsonBinary:
+		v, rest, err := fixed(5)
+		if err != nil {
+			return nil, nil, err
+		}
+		n := int(binary.LittleEndian.Uint32(v))
+		if n < 0 || n > len(rest) {
+			return nil, nil, errShortDocument
+		}
+		return append([]byte(nil), rest[:n]...), rest[n:], nil
+	case bsonObjectID:
+		v, rest, err := fixed(12)
+		if err != nil {
+			return nil, nil, err
+		}
+		var id ObjectID
+		copy(id[:], v)
+		return id, rest, nil
+	case bsonBool:
+		v, rest, err := fixed(1)
+		if err != nil {
+			return nil, nil, err
+		}
+		return v[0] != 0, rest, nil
+	case bsonDateTime:
+		v, rest, err := fixed(8)
+		if err != nil {
+			return nil, nil, err
+		}
+		ms := int64(binary.LittleEndian.Uint64(v))
+		return time.Unix(ms/1000, ms%1000*int64(time.Millisecond)).UTC(), rest, nil
+	case bsonNull:
+		return nil, b, nil
+	case bsonInt32:
+		v, rest, err := fixed(4)
+		if err != nil {
+			return nil, nil, err
+		}
+		return int32(binary.LittleEndian.Uint32(v)), rest, nil
+	case bsonTimestamp:
+		v, rest, err := fixed(8)
+		if err != nil {
+			return nil, nil, err
+		}
+		return Timestamp(binary.LittleEndian.Uint64(v)), rest, nil
+	case bsonInt64:
+		v, rest, err := fixed(8)
+		if err != nil {
+			return nil, nil, err
+		}
+		return int64(binary.LittleEndian.Uint64(v)), rest, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported BSON type %#x", typ)
+}
+
+func cString(b []byte) (string, []byte, error) {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i]), b[i+1:], nil
+		}
+	}
+	return "", nil, errShortDocument
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v)), uint32(v>>32))
+}
@@ -0,0 +1,53 @@
+package mongo
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+)
+
+// Conn is a client connection.
+type Conn struct {
+	conn      net.Conn
+	r         *bufio.Reader
+	requestID int32
+}
+
+// NewConn returns a client for conn.
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// Command runs cmd against the database db and returns its reply.
+// A reply whose ok field is not 1 is returned along with a *CommandError.
+func (c *Conn) Command(db string, cmd Document) (Document, error) {
+	c.requestID++
+	body := append(append(Document(nil), cmd...), Element{Key: "$db", Value: db})
+	if err := writeMessage(c.conn, &message{requestID: c.requestID, body: body}); err != nil {
+		return nil, err
+	}
+	reply, err := readMessage(c.r)
+	if err != nil {
+		return nil, err
+	}
+	if reply.responseTo != c.requestID {
+		return nil, fmt.Errorf("reply to request %d, expected %d", reply.responseTo, c.requestID)
+	}
+	return reply.body, commandError(reply.body)
+}
+
+// Hello runs the hello command, or isMaster on servers older than 4.4.2 that don't know it.
+// It returns the reply and the name of the command that answered it.
+func (c *Conn) Hello() (Document, string, error) {
+	reply, err := c.Command("admin", Document{{Key: "hello", Value: int32(1)}})
+	if e, ok := err.(*CommandError); ok && e.Code == CodeCommandNotFound {
+		reply, err = c.Command("admin", Document{{Key: "isMaster", Value: int32(1)}})
+		return reply, "isMaster", err
+	}
+	return reply, "hello", err
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,232 @@
+package mongo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	d := Document{
+		{Key: "hello", Value: int32(1)},
+		{Key: "double", Value: 1.5},
+		{Key: "string", Value: "prober-demo"},
+		{Key: "document", Value: Document{{Key: "nested", Value: true}}},
+		{Key: "array", Value: []interface{}{"a", int64(2), nil}},
+		{Key: "binary", Value: []byte{0, 1, 2}},
+		{Key: "objectId", Value: ObjectID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}},
+		{Key: "false", Value: false},
+		{Key: "date", Value: time.Date(2020, 1, 2, 3, 4, 5, 6e6, time.UTC)},
+		{Key: "null", Value: nil},
+		{Key: "timestamp", Value: Timestamp(1 << 40)},
+		{Key: "int64", Value: int64(-1)},
+	}
+	b, err := appendDocument(nil, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, rest, err := readDocument(append(b, "rest"...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, d) {
+		t.Errorf("readDocument() = %v, want %v", got, d)
+	}
+	if string(rest) != "rest" {
+		t.Errorf("rest = %q, want the bytes following the document", rest)
+	}
+
+	// int is written as int32.
+	b, err = appendDocument(nil, Document{{Key: "n", Value: 7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err = readDocument(b); err != nil || got.Lookup("n") != int32(7) {
+		t.Errorf("readDocument() = %v, %v, want n int32 7", got, err)
+	}
+	if _, err = appendDocument(nil, Document{{Key: "f", Value: float32(1)}}); err == nil {
+		t.Error("appendDocument() of a float32 succeeded")
+	}
+}
+
+func TestDocumentAccessors(t *testing.T) {
+	d := Document{
+		{Key: "ok", Value: 1.0},
+		{Key: "code", Value: int32(59)},
+		{Key: "size", Value: int64(48)},
+		{Key: "codeName", Value: "CommandNotFound"},
+		{Key: "secondary", Value: true},
+	}
+	if d.Number("ok") != 1 || d.Number("code") != 59 || d.Number("size") != 48 || d.Number("codeName") != 0 {
+		t.Errorf("Number() of ok, code, size, codeName = %v %v %v %v", d.Number("ok"), d.Number("code"), d.Number("size"), d.Number("codeName"))
+	}
+	if d.String("codeName") != "CommandNotFound" || d.String("ok") != "" {
+		t.Errorf("String() of codeName, ok = %q %q", d.String("codeName"), d.String("ok"))
+	}
+	if !d.Bool("secondary") || d.Bool("missing") {
+		t.Errorf("Bool() of secondary, missing = %v %v", d.Bool("secondary"), d.Bool("missing"))
+	}
+}
+
+func TestReadDocumentInvalid(t *testing.T) {
+	valid, err := appendDocument(nil, Document{{Key: "s", Value: "abc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	badString := append([]byte(nil), valid...)
+	badString[7] = 0xff
+	badType := append([]byte(nil), valid...)
+	badType[4] = 0x7f
+	tests := []struct {
+		name string
+		doc  []byte
+	}{
+		{"empty", nil},
+		{"short", valid[:len(valid)-1]},
+		{"not terminated", append(valid[:len(valid)-1:len(valid)-1], 1)},
+		{"string length", badString},
+		{"unsupported type", badType},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if d, _, err := readDocument(test.doc); err == nil {
+				t.Errorf("readDocument() = %v, want an error", d)
+			}
+		})
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	body := Document{{Key: "ping", Value: int32(1)}, {Key: "$db", Value: "admin"}}
+	if err := writeMessage(&buf, &message{requestID: 3, responseTo: 2, body: body}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := readMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.requestID != 3 || m.responseTo != 2 || !reflect.DeepEqual(m.body, body) {
+		t.Errorf("readMessage() = %d %d %v, want 3 2 %v", m.requestID, m.responseTo, m.body, body)
+	}
+}
+
+// rawMessage returns an OP_MSG message of the given flags and sections.
+func rawMessage(opCode uint32, flags uint32, sections ...[]byte) []byte {
+	b := make([]byte, headerLen)
+	binary.LittleEndian.PutUint32(b[12:], opCode)
+	b = appendUint32(b, flags)
+	for _, section := range sections {
+		b = append(b, section...)
+	}
+	binary.LittleEndian.PutUint32(b, uint32(len(b)))
+	return b
+}
+
+func TestReadMessageSections(t *testing.T) {
+	doc, err := appendDocument(nil, Document{{Key: "hello", Value: int32(1)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := append([]byte{sectionBody}, doc...)
+	sequence := append(appendUint32([]byte{sectionSequence}, uint32(4+len("docs\x00")+len(doc))), "docs\x00"...)
+	sequence = append(sequence, doc...)
+
+	tests := []struct {
+		name    string
+		msg     []byte
+		wantErr bool
+	}{
+		{"body", rawMessage(opMsg, 0, body), false},
+		{"document sequence skipped", rawMessage(opMsg, 0, sequence, body), false},
+		{"checksum", rawMessage(opMsg, flagChecksumPresent, body, []byte{1, 2, 3, 4}), false},
+		{"no body", rawMessage(opMsg, 0, sequence), true},
+		{"unknown section", rawMessage(opMsg, 0, []byte{2}, doc), true},
+		{"other opcode", rawMessage(2004, 0, body), true},
+		{"short", rawMessage(opMsg, 0)[:headerLen], true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := readMessage(bytes.NewReader(test.msg))
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("readMessage() = %v, want an error", m.body)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.body.Number("hello") != 1 {
+				t.Errorf("body = %v, want hello", m.body)
+			}
+		})
+	}
+}
+
+// dial serves a connection of s and returns the client end of it.
+func dial(t *testing.T, s *Server) *Conn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	conn, err := net.Dial("tcp", l.Addr().String())
+	// the accepted connection outlives the listener.
+	l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewConn(conn)
+}
+
+func TestHello(t *testing.T) {
+	members := []string{"mongo-0:27017", "mongo-1:27017"}
+	tests := []struct {
+		name      string
+		server    Server
+		command   string
+		primary   string
+		writable  bool
+		secondary bool
+		setName   string
+	}{
+		{"primary", Server{State: StatePrimary, SetName: "rs0", Primary: members[0], Hosts: members}, "hello", "isWritablePrimary", true, false, "rs0"},
+		{"secondary", Server{State: StateSecondary, SetName: "rs0", Primary: members[0], Hosts: members}, "hello", "isWritablePrimary", false, true, "rs0"},
+		{"standalone", Server{State: StateStandalone}, "hello", "isWritablePrimary", true, false, ""},
+		{"legacy", Server{State: StateSecondary, SetName: "rs0", Legacy: true}, "isMaster", "ismaster", false, true, "rs0"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := dial(t, &test.server)
+			defer c.Close()
+			reply, command, err := c.Hello()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if command != test.command || reply.Bool(test.primary) != test.writable ||
+				reply.Bool("secondary") != test.secondary || reply.String("setName") != test.setName {
+				t.Errorf("%s = %v, want %s=%v secondary=%v setName=%q", command, reply, test.primary, test.writable, test.secondary, test.setName)
+			}
+		})
+	}
+}
+
+func TestCommandError(t *testing.T) {
+	c := dial(t, &Server{State: StateStandalone})
+	defer c.Close()
+	if _, err := c.Command("admin", Document{{Key: "ping", Value: int32(1)}}); err != nil {
+		t.Errorf("ping: %v", err)
+	}
+	_, err := c.Command("admin", Document{{Key: "find", Value: "demo"}})
+	e, ok := err.(*CommandError)
+	if !ok || e.Code != CodeCommandNotFound || e.CodeName != "CommandNotFound" {
+		t.Fatalf("find: %v, want a CommandNotFound error", err)
+	}
+	if want := "no such command: 'find' (CommandNotFound, code 59)"; e.Error() != want {
+		t.Errorf("Error() = %q, want %q", e.Error(), want)
+	}
+}
@@ -0,0 +1,140 @@
+package mongo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/appscode/go/log"
+)
+
+// States of a Server.
+const (
+	StatePrimary    = "primary"
+	StateSecondary  = "secondary"
+	StateStandalone = "standalone"
+)
+
+const (
+	// maxWireVersion is the wire version of MongoDB 6.0, reported by servers that know hello.
+	maxWireVersion = 17
+	// legacyMaxWireVersion is the wire version of MongoDB 4.2, reported by servers that only know isMaster.
+	legacyMaxWireVersion = 8
+)
+
+// Server is a fake MongoDB server that answers hello, isMaster and ping over OP_MSG.
+type Server struct {
+	// State is the replication state of the server, one of StatePrimary, StateSecondary or StateStandalone.
+	State string
+	// SetName is the name of the replica set of primaries and secondaries.
+	SetName string
+	// Me is the host:port of the server in its replica set.
+	Me string
+	// Primary is the host:port of the primary of the replica set.
+	Primary string
+	// Hosts are the members of the replica set.
+	Hosts []string
+	// Legacy makes the server answer hello with CommandNotFound, like servers older than 4.4.2.
+	Legacy bool
+
+	connectionID int32
+}
+
+// Serve serves the connections accepted by l until it is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := s.serveConn(conn); err != nil && err != io.EOF {
+				log.Debugf("mongodb connection from %v: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	connectionID := atomic.AddInt32(&s.connectionID, 1)
+	for {
+		m, err := readMessage(r)
+		if err != nil {
+			return err
+		}
+		reply := s.answer(m.body, connectionID)
+		if err = writeMessage(conn, &message{requestID: m.requestID + 1, responseTo: m.requestID, body: reply}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) answer(cmd Document, connectionID int32) Document {
+	if len(cmd) == 0 {
+		return errorReply(CodeCommandNotFound, "CommandNotFound", "no command given")
+	}
+	name := cmd[0].Key
+	switch strings.ToLower(name) {
+	case "hello":
+		if s.Legacy {
+			break
+		}
+		return s.hello("isWritablePrimary", maxWireVersion, connectionID)
+	case "ismaster":
+		version := int32(maxWireVersion)
+		if s.Legacy {
+			version = legacyMaxWireVersion
+		}
+		return s.hello("ismaster", version, connectionID)
+	case "ping":
+		return Document{{Key: "ok", Value: 1.0}}
+	}
+	return errorReply(CodeCommandNotFound, "CommandNotFound", fmt.Sprintf("no such command: '%s'", name))
+}
+
+// hello returns the reply of hello and isMaster, which name the writable primary field differently.
+func (s *Server) hello(primaryField string, wireVersion int32, connectionID int32) Document {
+	reply := Document{
+		{Key: primaryField, Value: s.State != StateSecondary},
+		{Key: "secondary", Value: s.State == StateSecondary},
+	}
+	if s.State != StateStandalone {
+		hosts := make([]interface{}, len(s.Hosts))
+		for i, host := range s.Hosts {
+			hosts[i] = host
+		}
+		reply = append(reply,
+			Element{Key: "setName", Value: s.SetName},
+			Element{Key: "hosts", Value: hosts},
+			Element{Key: "primary", Value: s.Primary},
+			Element{Key: "me", Value: s.Me},
+		)
+	}
+	return append(reply,
+		Element{Key: "maxBsonObjectSize", Value: int32(16 << 20)},
+		Element{Key: "maxMessageSizeBytes", Value: int32(48000000)},
+		Element{Key: "maxWriteBatchSize", Value: int32(100000)},
+		Element{Key: "localTime", Value: time.Now()},
+		Element{Key: "logicalSessionTimeoutMinutes", Value: int32(30)},
+		Element{Key: "connectionId", Value: connectionID},
+		Element{Key: "minWireVersion", Value: int32(0)},
+		Element{Key: "maxWireVersion", Value: wireVersion},
+		Element{Key: "readOnly", Value: false},
+		Element{Key: "ok", Value: 1.0},
+	)
+}
+
+func errorReply(code int32, codeName, message string) Document {
+	return Document{
+		{Key: "ok", Value: 0.0},
+		{Key: "errmsg", Value: message},
+		{Key: "code", Value: code},
+		{Key: "codeName", Value: codeName},
+	}
+}
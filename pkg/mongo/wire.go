@@ -0,0 +1,134 @@
+// Package mongo implements the part of the MongoDB wire protocol (OP_MSG and a subset of BSON)
+// needed to run the hello command, on the client and on the server side.
+package mongo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	opMsg = 2013
+
+	headerLen = 16
+	// flagChecksumPresent tells that a CRC-32C checksum follows the sections.
+	flagChecksumPresent = 1 << 0
+	// sectionBody is the kind of the section holding the command or its reply.
+	sectionBody = 0
+	// sectionSequence is the kind of the sections holding document sequences.
+	sectionSequence = 1
+	// maxMessageLength bounds the messages read, the protocol allows much more than a probe needs.
+	maxMessageLength = 16 << 20
+)
+
+// CodeCommandNotFound is the error code of unknown commands.
+const CodeCommandNotFound = 59
+
+// CommandError is the reply of a command that failed.
+type CommandError struct {
+	Code     int32
+	CodeName string
+	Message  string
+}
+
+func (e *CommandError) Error() string {
+	if e.CodeName == "" {
+		return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+	}
+	return fmt.Sprintf("%s (%s, code %d)", e.Message, e.CodeName, e.Code)
+}
+
+// commandError returns the error of a reply whose ok field is not 1, or nil.
+func commandError(reply Document) error {
+	if reply.Number("ok") == 1 {
+		return nil
+	}
+	return &CommandError{
+		Code:     int32(reply.Number("code")),
+		CodeName: reply.String("codeName"),
+		Message:  reply.String("errmsg"),
+	}
+}
+
+// message is an OP_MSG message.
+type message struct {
+	requestID  int32
+	responseTo int32
+	body       Document
+}
+
+// readMessage reads an OP_MSG message. Document sequences are skipped, as hello does not use them.
+func readMessage(r io.Reader) (*message, error) {
+	var header [headerLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	n := int(binary.LittleEndian.Uint32(header[:]))
+	if n < headerLen+5 || n > maxMessageLength {
+		return nil, fmt.Errorf("invalid message length %d", n)
+	}
+	payload := make([]byte, n-headerLen)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	if opCode := binary.LittleEndian.Uint32(header[12:]); opCode != opMsg {
+		return nil, fmt.Errorf("unsupported opcode %d", opCode)
+	}
+	m := &message{
+		requestID:  int32(binary.LittleEndian.Uint32(header[4:])),
+		responseTo: int32(binary.LittleEndian.Uint32(header[8:])),
+	}
+	flags := binary.LittleEndian.Uint32(payload)
+	sections := payload[4:]
+	if flags&flagChecksumPresent != 0 {
+		if len(sections) < 4 {
+			return nil, errShortDocument
+		}
+		sections = sections[:len(sections)-4]
+	}
+	for len(sections) > 0 {
+		kind := sections[0]
+		sections = sections[1:]
+		switch kind {
+		case sectionBody:
+			d, rest, err := readDocument(sections)
+			if err != nil {
+				return nil, err
+			}
+			m.body, sections = d, rest
+		case sectionSequence:
+			if len(sections) < 4 {
+				return nil, errShortDocument
+			}
+			size := int(binary.LittleEndian.Uint32(sections))
+			if size < 4 || size > len(sections) {
+				return nil, errShortDocument
+			}
+			sections = sections[size:]
+		default:
+			return nil, fmt.Errorf("unsupported section kind %d", kind)
+		}
+	}
+	if m.body == nil {
+		return nil, fmt.Errorf("message has no body")
+	}
+	return m, nil
+}
+
+// writeMessage writes an OP_MSG message with a single body section.
+func writeMessage(w io.Writer, m *message) error {
+	b := make([]byte, headerLen, 256)
+	binary.LittleEndian.PutUint32(b[4:], uint32(m.requestID))
+	binary.LittleEndian.PutUint32(b[8:], uint32(m.responseTo))
+	binary.LittleEndian.PutUint32(b[12:], opMsg)
+	b = appendUint32(b, 0) // flags
+	b = append(b, sectionBody)
+	b, err := appendDocument(b, m.body)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(b, uint32(len(b)))
+	_, err = w.Write(b)
+	return err
+}
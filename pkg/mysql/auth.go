@@ -0,0 +1,126 @@
+package mysql
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// Authentication plugins of a Server.
+const (
+	AuthNativePassword = "mysql_native_password"
+	AuthCachingSHA2    = "caching_sha2_password"
+)
+
+// scrambleLength is the length of the random data that passwords are scrambled with.
+const scrambleLength = 20
+
+// caching_sha2_password states sent after the scrambled password.
+const (
+	fastAuthSuccess = 0x03
+	performFullAuth = 0x04
+)
+
+// requestPublicKey is sent by a caching_sha2_password client asked for full authentication without TLS,
+// to get the RSA public key of the server.
+const requestPublicKey = 0x02
+
+// scramblePassword returns the auth response of plugin for password and the scramble of the server.
+func scramblePassword(plugin, password string, scramble []byte) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	switch plugin {
+	case AuthNativePassword:
+		return nativePassword(password, scramble), nil
+	case AuthCachingSHA2:
+		return cachingSHA2Password(password, scramble), nil
+	}
+	return nil, fmt.Errorf("unsupported authentication plugin %q", plugin)
+}
+
+// nativePassword returns SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password))).
+func nativePassword(password string, scramble []byte) []byte {
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+	h := sha1.New()
+	h.Write(scramble)
+	h.Write(stage2[:])
+	return xor(stage1[:], h.Sum(nil))
+}
+
+// cachingSHA2Password returns SHA256(password) XOR SHA256(SHA256(SHA256(password)) + scramble).
+func cachingSHA2Password(password string, scramble []byte) []byte {
+	stage1 := sha256.Sum256([]byte(password))
+	stage2 := sha256.Sum256(stage1[:])
+	h := sha256.New()
+	h.Write(stage2[:])
+	h.Write(scramble)
+	return xor(stage1[:], h.Sum(nil))
+}
+
+// encryptPassword returns the password, NUL terminated and XORed with the scramble, encrypted with RSA-OAEP
+// and the public key of the server in PEM.
+func encryptPassword(password string, scramble []byte, pemKey []byte) ([]byte, error) {
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, errors.New("invalid public key of server: no PEM data")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key of server: %v", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid public key of server: %T is not an RSA key", key)
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, rsaKey, xorScramble(append([]byte(password), 0), scramble), nil)
+}
+
+// decryptPassword returns the password encrypted by encryptPassword.
+func decryptPassword(data []byte, scramble []byte, key *rsa.PrivateKey) (string, error) {
+	plain, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, key, data, nil)
+	if err != nil {
+		return "", err
+	}
+	plain = xorScramble(plain, scramble)
+	if len(plain) == 0 || plain[len(plain)-1] != 0 {
+		return "", errors.New("password is not NUL terminated")
+	}
+	return string(plain[:len(plain)-1]), nil
+}
+
+// xorScramble XORs data with the scramble repeated to its length.
+func xorScramble(data []byte, scramble []byte) []byte {
+	out := make([]byte, len(data))
+	for i := range data {
+		out[i] = data[i] ^ scramble[i%len(scramble)]
+	}
+	return out
+}
+
+func xor(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+// newScramble returns random printable data, without the NUL that terminates it in the handshake.
+func newScramble() []byte {
+	b := make([]byte, scrambleLength)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail on supported platforms.
+		panic(err)
+	}
+	for i := range b {
+		b[i] = b[i]&0x7f | 1
+	}
+	return b
+}
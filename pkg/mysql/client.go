@@ -0,0 +1,248 @@
+package mysql
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// clientCapabilities are the capabilities of Conn, a result set is therefore terminated with an EOF packet.
+const clientCapabilities = clientLongPassword | clientProtocol41 | clientTransactions | clientSecureConnection | clientPluginAuth
+
+// Conn is an authenticated client connection.
+type Conn struct {
+	conn net.Conn
+	pc   *packetConn
+	// ServerVersion is the version announced by the server in its handshake.
+	ServerVersion string
+}
+
+// handshake is the initial handshake packet of the server.
+type handshake struct {
+	serverVersion string
+	capabilities  uint32
+	scramble      []byte
+	plugin        string
+}
+
+func parseHandshake(payload []byte) (*handshake, error) {
+	if len(payload) > 0 && payload[0] == headerErr {
+		return nil, parseError(payload)
+	}
+	if len(payload) == 0 {
+		return nil, errShortPacket
+	}
+	if payload[0] != protocolVersion {
+		return nil, fmt.Errorf("unsupported protocol version %d", payload[0])
+	}
+	h := &handshake{plugin: AuthNativePassword}
+	version, rest := cString(payload[1:])
+	h.serverVersion = version
+	// connection id, first part of the scramble and a filler.
+	if len(rest) < 4+8+1+2 {
+		return nil, errShortPacket
+	}
+	h.scramble = append(h.scramble, rest[4:12]...)
+	h.capabilities = uint32(binary.LittleEndian.Uint16(rest[13:]))
+	rest = rest[15:]
+	if len(rest) < 1+2+2+1+10 {
+		return h, nil
+	}
+	h.capabilities |= uint32(binary.LittleEndian.Uint16(rest[3:])) << 16
+	scrambleLen := int(rest[5]) - 8
+	rest = rest[16:]
+	if h.capabilities&clientSecureConnection != 0 {
+		if scrambleLen < 13 {
+			scrambleLen = 13
+		}
+		if len(rest) < scrambleLen {
+			return nil, errShortPacket
+		}
+		h.scramble = append(h.scramble, bytes.TrimRight(rest[:scrambleLen], "\x00")...)
+		rest = rest[scrambleLen:]
+	}
+	if h.capabilities&clientPluginAuth != 0 {
+		h.plugin, _ = cString(rest)
+	}
+	return h, nil
+}
+
+// Connect authenticates as user to database on conn, with the mysql_native_password or the
+// caching_sha2_password plugin. If caching_sha2_password asks for full authentication, the password is sent
+// as is over TLS, and encrypted with the RSA public key of the server otherwise.
+// An ERR packet of the server is returned as an *Error.
+func Connect(conn net.Conn, user, password, database string) (*Conn, error) {
+	c := &Conn{conn: conn, pc: newPacketConn(conn)}
+	payload, err := c.pc.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	h, err := parseHandshake(payload)
+	if err != nil {
+		return nil, err
+	}
+	if h.capabilities&clientProtocol41 == 0 || h.capabilities&clientSecureConnection == 0 {
+		return nil, fmt.Errorf("server %s does not support protocol 41", h.serverVersion)
+	}
+	c.ServerVersion = h.serverVersion
+
+	plugin, scramble := h.plugin, h.scramble
+	auth, err := scramblePassword(plugin, password, scramble)
+	if err != nil {
+		// answer with the default plugin, the server may switch to it.
+		plugin = AuthNativePassword
+		auth = nativePassword(password, h.scramble)
+	}
+	capabilities := uint32(clientCapabilities)
+	if database != "" {
+		capabilities |= clientConnectWithDB
+	}
+	resp := appendUint32(nil, capabilities)
+	resp = appendUint32(resp, maxPacketLength)
+	resp = append(resp, charsetUTF8)
+	resp = append(resp, make([]byte, 23)...)
+	resp = appendCString(resp, user)
+	resp = append(resp, byte(len(auth)))
+	resp = append(resp, auth...)
+	if database != "" {
+		resp = appendCString(resp, database)
+	}
+	resp = appendCString(resp, plugin)
+	if err = c.pc.writePacket(resp); err != nil {
+		return nil, err
+	}
+
+	for {
+		payload, err := c.pc.readPacket()
+		if err != nil {
+			return nil, err
+		}
+		if len(payload) == 0 {
+			return nil, errShortPacket
+		}
+		switch payload[0] {
+		case headerOK:
+			return c, nil
+		case headerErr:
+			return nil, parseError(payload)
+		case headerAuthSwitch:
+			var data []byte
+			plugin, data = cString(payload[1:])
+			scramble = bytes.TrimRight(data, "\x00")
+			if auth, err = scramblePassword(plugin, password, scramble); err != nil {
+				return nil, err
+			}
+			if err = c.pc.writePacket(auth); err != nil {
+				return nil, err
+			}
+		case headerAuthMore:
+			if plugin != AuthCachingSHA2 || len(payload) < 2 {
+				return nil, fmt.Errorf("unexpected auth data for plugin %s", plugin)
+			}
+			switch payload[1] {
+			case fastAuthSuccess:
+				// the OK packet follows.
+			case performFullAuth:
+				if err = c.fullAuth(password, scramble); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, fmt.Errorf("unexpected caching_sha2_password state %#x", payload[1])
+			}
+		default:
+			return nil, fmt.Errorf("unexpected packet %#x during authentication", payload[0])
+		}
+	}
+}
+
+// fullAuth sends the password asked for by caching_sha2_password. The OK or ERR packet of the server follows.
+func (c *Conn) fullAuth(password string, scramble []byte) error {
+	if _, ok := c.conn.(*tls.Conn); ok {
+		return c.pc.writePacket(append([]byte(password), 0))
+	}
+	if err := c.pc.writePacket([]byte{requestPublicKey}); err != nil {
+		return err
+	}
+	payload, err := c.pc.readPacket()
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(payload) > 0 && payload[0] == headerErr:
+		return parseError(payload)
+	case len(payload) == 0 || payload[0] != headerAuthMore:
+		return errors.New("server did not send its public key for caching_sha2_password full authentication")
+	}
+	encrypted, err := encryptPassword(password, scramble, payload[1:])
+	if err != nil {
+		return err
+	}
+	return c.pc.writePacket(encrypted)
+}
+
+// Query runs sql and returns the rows of its result as text, NULL columns are returned empty.
+func (c *Conn) Query(sql string) ([][]string, error) {
+	c.pc.seq = 0
+	if err := c.pc.writePacket(append([]byte{comQuery}, sql...)); err != nil {
+		return nil, err
+	}
+	payload, err := c.pc.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) == 0 {
+		return nil, errShortPacket
+	}
+	switch payload[0] {
+	case headerOK:
+		return nil, nil
+	case headerErr:
+		return nil, parseError(payload)
+	}
+	columns, _, _, err := readLenencInt(payload)
+	if err != nil {
+		return nil, err
+	}
+	// the column definitions are not needed, every column is read as text.
+	for i := uint64(0); i <= columns; i++ {
+		if payload, err = c.pc.readPacket(); err != nil {
+			return nil, err
+		}
+	}
+	if !isEOF(payload) {
+		return nil, fmt.Errorf("expected EOF packet after %d column definitions", columns)
+	}
+
+	var rows [][]string
+	for {
+		payload, err := c.pc.readPacket()
+		if err != nil {
+			return nil, err
+		}
+		if isEOF(payload) {
+			return rows, nil
+		}
+		if len(payload) > 0 && payload[0] == headerErr {
+			return nil, parseError(payload)
+		}
+		row := make([]string, 0, columns)
+		for i := uint64(0); i < columns; i++ {
+			var value string
+			if value, payload, err = readLenencString(payload); err != nil {
+				return nil, err
+			}
+			row = append(row, value)
+		}
+		rows = append(rows, row)
+	}
+}
+
+// Close quits the session and closes the connection.
+func (c *Conn) Close() error {
+	c.pc.seq = 0
+	c.pc.writePacket([]byte{comQuit})
+	return c.conn.Close()
+}
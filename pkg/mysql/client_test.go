@@ -0,0 +1,60 @@
+package mysql
+
+import (
+	"net"
+	"testing"
+)
+
+func TestConnect(t *testing.T) {
+	tests := []struct {
+		name      string
+		auth      string
+		cacheMiss bool
+		password  string
+		wantAuth  bool
+	}{
+		{"native", AuthNativePassword, false, "s3cr3t", true},
+		{"native wrong password", AuthNativePassword, false, "wrong", false},
+		{"caching sha2 fast auth", AuthCachingSHA2, false, "s3cr3t", true},
+		{"caching sha2 fast auth wrong password", AuthCachingSHA2, false, "wrong", false},
+		{"caching sha2 full auth", AuthCachingSHA2, true, "s3cr3t", true},
+		{"caching sha2 full auth wrong password", AuthCachingSHA2, true, "wrong", false},
+		{"caching sha2 full auth no password", AuthCachingSHA2, true, "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := &Server{
+				Users:     map[string]string{"prober": "s3cr3t"},
+				Auth:      test.auth,
+				CacheMiss: test.cacheMiss,
+			}
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			go srv.Serve(l)
+
+			conn, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := Connect(conn, "prober", test.password, "")
+			if !test.wantAuth {
+				conn.Close()
+				if myErr, ok := err.(*Error); !ok || !myErr.IsAuthError() {
+					t.Fatalf("Connect() error = %v, want an authentication error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Connect() error = %v", err)
+			}
+			defer c.Close()
+			rows, err := c.Query("SELECT 1")
+			if err != nil || len(rows) != 1 || rows[0][0] != "1" {
+				t.Errorf("Query() = %v, %v, want [[1]]", rows, err)
+			}
+		})
+	}
+}
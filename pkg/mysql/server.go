@@ -0,0 +1,351 @@
+package mysql
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/appscode/go/log"
+)
+
+// serverVersion is announced by a Server in its handshake.
+const serverVersion = "8.0.0-prober-demo"
+
+// serverCapabilities are the capabilities of a Server. Result sets are always terminated with an EOF packet.
+const serverCapabilities = clientLongPassword | clientConnectWithDB | clientProtocol41 | clientTransactions |
+	clientSecureConnection | clientPluginAuth | clientPluginAuthLenenc
+
+// Database is a database served by a Server, with the state that probes check.
+type Database struct {
+	// ReadOnly makes the server read-only, like a replica.
+	ReadOnly bool
+	// Busy rejects every connection with "Too many connections".
+	Busy bool
+	// Hang makes the server stop answering once the client is authenticated, before telling it so.
+	Hang bool
+}
+
+// Server is a fake MySQL server that authenticates clients and answers a few trivial queries.
+type Server struct {
+	// Users maps user names to their password.
+	Users map[string]string
+	// Auth is the authentication plugin, AuthNativePassword or AuthCachingSHA2.
+	// The handshake always proposes caching_sha2_password, so other plugins are negotiated with an auth switch.
+	Auth string
+	// Databases maps database names to their state. Clients that select no database get the zero state.
+	Databases map[string]Database
+	// CacheMiss makes caching_sha2_password miss its cache for every user, like right after the server started.
+	// Clients then send their password encrypted with the RSA key of the server.
+	CacheMiss bool
+
+	connectionID uint32
+
+	keyOnce sync.Once
+	key     *rsa.PrivateKey
+	keyErr  error
+}
+
+// Serve serves the connections accepted by l until it is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := s.serveConn(conn); err != nil && err != io.EOF {
+				log.Debugf("mysql connection from %v: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// handshakeResponse is the HandshakeResponse41 packet of a client.
+type handshakeResponse struct {
+	capabilities uint32
+	user         string
+	auth         []byte
+	database     string
+	plugin       string
+}
+
+func parseHandshakeResponse(payload []byte) (*handshakeResponse, error) {
+	if len(payload) < 32 {
+		return nil, errShortPacket
+	}
+	r := &handshakeResponse{capabilities: binary.LittleEndian.Uint32(payload)}
+	if r.capabilities&clientProtocol41 == 0 {
+		return nil, fmt.Errorf("client does not support protocol 41")
+	}
+	r.user, payload = cString(payload[32:])
+	switch {
+	case r.capabilities&clientPluginAuthLenenc != 0:
+		auth, rest, err := readLenencString(payload)
+		if err != nil {
+			return nil, err
+		}
+		r.auth, payload = []byte(auth), rest
+	case r.capabilities&clientSecureConnection != 0:
+		if len(payload) == 0 || len(payload) < 1+int(payload[0]) {
+			return nil, errShortPacket
+		}
+		r.auth, payload = payload[1:1+payload[0]], payload[1+payload[0]:]
+	default:
+		var auth string
+		auth, payload = cString(payload)
+		r.auth = []byte(auth)
+	}
+	if r.capabilities&clientConnectWithDB != 0 {
+		r.database, payload = cString(payload)
+	}
+	if r.capabilities&clientPluginAuth != 0 {
+		r.plugin, _ = cString(payload)
+	}
+	return r, nil
+}
+
+func (s *Server) serveConn(conn net.Conn) error {
+	pc := newPacketConn(conn)
+	scramble := newScramble()
+	if err := pc.writePacket(s.handshake(scramble)); err != nil {
+		return err
+	}
+	payload, err := pc.readPacket()
+	if err != nil {
+		return err
+	}
+	resp, err := parseHandshakeResponse(payload)
+	if err != nil {
+		return writeError(pc, ErrAccessDenied, "08S01", "Bad handshake")
+	}
+
+	auth := resp.auth
+	if resp.plugin != s.Auth {
+		switchRequest := append([]byte{headerAuthSwitch}, appendCString(nil, s.Auth)...)
+		switchRequest = appendCString(switchRequest, string(scramble))
+		if err = pc.writePacket(switchRequest); err != nil {
+			return err
+		}
+		if auth, err = pc.readPacket(); err != nil {
+			return err
+		}
+	}
+	password, known := s.Users[resp.user]
+	var ok bool
+	if s.Auth == AuthCachingSHA2 && s.CacheMiss && len(auth) > 0 {
+		if ok, err = s.fullAuth(pc, password, scramble); err != nil {
+			return err
+		}
+	} else {
+		expected, err := scramblePassword(s.Auth, password, scramble)
+		if err != nil {
+			return writeError(pc, ErrAccessDenied, "28000", err.Error())
+		}
+		ok = bytes.Equal(auth, expected)
+	}
+	if !known || !ok {
+		using := "NO"
+		if len(auth) > 0 {
+			using = "YES"
+		}
+		host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+		return writeError(pc, ErrAccessDenied, "28000", fmt.Sprintf("Access denied for user '%s'@'%s' (using password: %s)", resp.user, host, using))
+	}
+	if s.Auth == AuthCachingSHA2 && len(auth) > 0 && !s.CacheMiss {
+		// the password was found in the cache.
+		if err = pc.writePacket([]byte{headerAuthMore, fastAuthSuccess}); err != nil {
+			return err
+		}
+	}
+
+	db, found := s.Databases[resp.database]
+	switch {
+	case resp.database != "" && !found:
+		return writeError(pc, ErrBadDB, "42000", fmt.Sprintf("Unknown database '%s'", resp.database))
+	case db.Busy:
+		return writeError(pc, ErrTooManyConnections, "08004", "Too many connections")
+	}
+	if db.Hang {
+		// wait for the client to give up.
+		_, err = io.Copy(ioutil.Discard, conn)
+		return err
+	}
+	if err = pc.writePacket(okPacket()); err != nil {
+		return err
+	}
+
+	for {
+		pc.seq = 0
+		payload, err := pc.readPacket()
+		if err != nil {
+			return err
+		}
+		if len(payload) == 0 {
+			return errShortPacket
+		}
+		switch payload[0] {
+		case comQuit:
+			return nil
+		case comPing:
+			err = pc.writePacket(okPacket())
+		case comQuery:
+			err = s.answer(pc, db, string(payload[1:]))
+		default:
+			err = sendError(pc, ErrUnknownCommand, "08S01", "Unknown command")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// fullAuth asks the client for its password, encrypted with the RSA key of the server as there is no TLS,
+// and returns true if it is password.
+func (s *Server) fullAuth(pc *packetConn, password string, scramble []byte) (bool, error) {
+	if err := pc.writePacket([]byte{headerAuthMore, performFullAuth}); err != nil {
+		return false, err
+	}
+	payload, err := pc.readPacket()
+	if err != nil {
+		return false, err
+	}
+	if len(payload) != 1 || payload[0] != requestPublicKey {
+		// a password in clear text, only accepted over TLS.
+		return false, writeError(pc, ErrAccessDenied, "28000", "Authentication requires secure connection")
+	}
+	key, err := s.rsaKey()
+	if err != nil {
+		return false, writeError(pc, ErrAccessDenied, "28000", err.Error())
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return false, err
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	if err = pc.writePacket(append([]byte{headerAuthMore}, pemKey...)); err != nil {
+		return false, err
+	}
+	if payload, err = pc.readPacket(); err != nil {
+		return false, err
+	}
+	sent, err := decryptPassword(payload, scramble, key)
+	return err == nil && sent == password, nil
+}
+
+// rsaKey returns the RSA key of the server, generated when it is first needed.
+func (s *Server) rsaKey() (*rsa.PrivateKey, error) {
+	s.keyOnce.Do(func() {
+		s.key, s.keyErr = rsa.GenerateKey(rand.Reader, 2048)
+	})
+	return s.key, s.keyErr
+}
+
+// handshake returns the initial handshake packet.
+func (s *Server) handshake(scramble []byte) []byte {
+	b := []byte{protocolVersion}
+	b = appendCString(b, serverVersion)
+	b = appendUint32(b, atomic.AddUint32(&s.connectionID, 1))
+	b = append(b, scramble[:8]...)
+	b = append(b, 0)
+	b = appendUint16(b, uint16(serverCapabilities&0xffff))
+	b = append(b, charsetUTF8)
+	b = appendUint16(b, serverStatusAutocommit)
+	b = appendUint16(b, uint16(serverCapabilities>>16))
+	b = append(b, byte(len(scramble)+1))
+	b = append(b, make([]byte, 10)...)
+	b = appendCString(b, string(scramble[8:]))
+	return appendCString(b, AuthCachingSHA2)
+}
+
+var selectNumber = regexp.MustCompile(`^select\s+(-?\d+)$`)
+
+// answer answers the few queries that probes run, and fails any other.
+func (s *Server) answer(pc *packetConn, db Database, sql string) error {
+	query := strings.ToLower(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(sql), ";")))
+	var column, value string
+	switch {
+	case query == "":
+		return sendError(pc, ErrEmptyQuery, "42000", "Query was empty")
+	case selectNumber.MatchString(query):
+		value = selectNumber.FindStringSubmatch(query)[1]
+		column = value
+	case query == "select @@global.read_only" || query == "select @@read_only":
+		column, value = "@@global.read_only", boolText(db.ReadOnly)
+	case query == "select @@global.super_read_only" || query == "select @@super_read_only":
+		column, value = "@@global.super_read_only", boolText(db.ReadOnly)
+	case query == "select version()" || query == "select @@version":
+		column, value = "version()", serverVersion
+	default:
+		return sendError(pc, ErrNotSupported, "42000", fmt.Sprintf("query %q is not supported by this server", sql))
+	}
+
+	if err := pc.writePacket(appendLenencInt(nil, 1)); err != nil {
+		return err
+	}
+	def := appendLenencString(nil, "def")
+	def = appendLenencString(def, "") // schema
+	def = appendLenencString(def, "") // table
+	def = appendLenencString(def, "") // original table
+	def = appendLenencString(def, column)
+	def = appendLenencString(def, "") // original name
+	def = appendLenencInt(def, 0x0c)  // length of the fixed fields
+	def = appendUint16(def, charsetUTF8)
+	def = appendUint32(def, 255)
+	def = append(def, 0xfd)    // VAR_STRING
+	def = appendUint16(def, 0) // flags
+	def = append(def, 0, 0, 0) // decimals and filler
+	if err := pc.writePacket(def); err != nil {
+		return err
+	}
+	if err := pc.writePacket(eofPacket()); err != nil {
+		return err
+	}
+	if err := pc.writePacket(appendLenencString(nil, value)); err != nil {
+		return err
+	}
+	return pc.writePacket(eofPacket())
+}
+
+func okPacket() []byte {
+	b := []byte{headerOK, 0, 0} // no affected rows nor insert id
+	b = appendUint16(b, serverStatusAutocommit)
+	return appendUint16(b, 0)
+}
+
+func eofPacket() []byte {
+	b := []byte{headerEOF, 0, 0} // no warnings
+	return appendUint16(b, serverStatusAutocommit)
+}
+
+// sendError sends an error that leaves the session usable.
+func sendError(pc *packetConn, code uint16, state, message string) error {
+	return pc.writePacket((&Error{Code: code, SQLState: state, Message: message}).encode())
+}
+
+// writeError sends an error that ends the session, and returns it.
+func writeError(pc *packetConn, code uint16, state, message string) error {
+	e := &Error{Code: code, SQLState: state, Message: message}
+	if err := pc.writePacket(e.encode()); err != nil {
+		return err
+	}
+	return e
+}
+
+func boolText(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
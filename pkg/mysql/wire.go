@@ -0,0 +1,228 @@
+// Package mysql implements the part of the MySQL client/server protocol (protocol 41)
+// needed to authenticate and run simple queries, on the client and on the server side.
+package mysql
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Capability flags exchanged in the handshake.
+const (
+	clientLongPassword     = 1 << 0
+	clientConnectWithDB    = 1 << 3
+	clientProtocol41       = 1 << 9
+	clientTransactions     = 1 << 13
+	clientSecureConnection = 1 << 15
+	clientPluginAuth       = 1 << 19
+	clientPluginAuthLenenc = 1 << 21
+)
+
+// Packet headers and commands.
+const (
+	headerOK         = 0x00
+	headerAuthMore   = 0x01
+	headerEOF        = 0xfe
+	headerAuthSwitch = 0xfe
+	headerErr        = 0xff
+
+	comQuit  = 0x01
+	comQuery = 0x03
+	comPing  = 0x0e
+
+	protocolVersion        = 10
+	charsetUTF8            = 33
+	serverStatusAutocommit = 0x0002
+	// maxPacketLength bounds the packets read, the protocol allows much more than a probe needs.
+	maxPacketLength = 1 << 20
+)
+
+// Error numbers told apart by probes.
+const (
+	ErrTooManyConnections      = 1040
+	ErrDBAccessDenied          = 1044
+	ErrAccessDenied            = 1045
+	ErrUnknownCommand          = 1047
+	ErrBadDB                   = 1049
+	ErrEmptyQuery              = 1065
+	ErrNotSupported            = 1235
+	ErrOptionPreventsStatement = 1290
+	ErrAccessDeniedNoPassword  = 1698
+)
+
+// Error is an ERR packet of the server.
+type Error struct {
+	Code     uint16
+	SQLState string
+	Message  string
+}
+
+func (e *Error) Error() string {
+	if e.SQLState == "" {
+		return fmt.Sprintf("Error %d: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("Error %d (%s): %s", e.Code, e.SQLState, e.Message)
+}
+
+// IsAuthError returns whether the error tells that the credentials were rejected.
+func (e *Error) IsAuthError() bool {
+	switch e.Code {
+	case ErrAccessDenied, ErrDBAccessDenied, ErrAccessDeniedNoPassword:
+		return true
+	}
+	return false
+}
+
+func parseError(payload []byte) *Error {
+	e := &Error{}
+	if len(payload) < 3 {
+		e.Message = "malformed error packet"
+		return e
+	}
+	e.Code = binary.LittleEndian.Uint16(payload[1:])
+	rest := payload[3:]
+	if len(rest) >= 6 && rest[0] == '#' {
+		e.SQLState = string(rest[1:6])
+		rest = rest[6:]
+	}
+	e.Message = string(rest)
+	return e
+}
+
+func (e *Error) encode() []byte {
+	b := []byte{headerErr, byte(e.Code), byte(e.Code >> 8), '#'}
+	b = append(b, e.SQLState...)
+	return append(b, e.Message...)
+}
+
+// packetConn reads and writes packets, keeping track of their sequence id.
+type packetConn struct {
+	r   *bufio.Reader
+	w   io.Writer
+	seq byte
+}
+
+func newPacketConn(rw io.ReadWriter) *packetConn {
+	return &packetConn{r: bufio.NewReader(rw), w: rw}
+}
+
+// readPacket reads a packet. Packets split over several frames are not needed, and rejected.
+func (c *packetConn) readPacket() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return nil, err
+	}
+	n := int(uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16)
+	if n > maxPacketLength {
+		return nil, fmt.Errorf("invalid packet length %d", n)
+	}
+	if header[3] != c.seq {
+		return nil, fmt.Errorf("packets out of order: expected sequence id %d, got %d", c.seq, header[3])
+	}
+	c.seq++
+	payload := make([]byte, n)
+	_, err := io.ReadFull(c.r, payload)
+	return payload, err
+}
+
+func (c *packetConn) writePacket(payload []byte) error {
+	b := make([]byte, 4, 4+len(payload))
+	b[0], b[1], b[2], b[3] = byte(len(payload)), byte(len(payload)>>8), byte(len(payload)>>16), c.seq
+	c.seq++
+	_, err := c.w.Write(append(b, payload...))
+	return err
+}
+
+var errShortPacket = errors.New("short packet")
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendCString(b []byte, s string) []byte {
+	b = append(b, s...)
+	return append(b, 0)
+}
+
+// appendLenencInt appends a length encoded integer.
+func appendLenencInt(b []byte, v uint64) []byte {
+	switch {
+	case v < 0xfb:
+		return append(b, byte(v))
+	case v <= 0xffff:
+		return append(b, 0xfc, byte(v), byte(v>>8))
+	case v <= 0xffffff:
+		return append(b, 0xfd, byte(v), byte(v>>8), byte(v>>16))
+	}
+	b = append(b, 0xfe)
+	for i := uint(0); i < 8; i++ {
+		b = append(b, byte(v>>(8*i)))
+	}
+	return b
+}
+
+func appendLenencString(b []byte, s string) []byte {
+	b = appendLenencInt(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// cString splits the NUL terminated string at the start of b from the rest of b.
+func cString(b []byte) (string, []byte) {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i]), b[i+1:]
+		}
+	}
+	return string(b), nil
+}
+
+// readLenencInt reads a length encoded integer. null is true for the 0xfb NULL marker.
+func readLenencInt(b []byte) (v uint64, null bool, rest []byte, err error) {
+	if len(b) == 0 {
+		return 0, false, nil, errShortPacket
+	}
+	var n int
+	switch b[0] {
+	case 0xfb:
+		return 0, true, b[1:], nil
+	case 0xfc:
+		n = 2
+	case 0xfd:
+		n = 3
+	case 0xfe:
+		n = 8
+	default:
+		return uint64(b[0]), false, b[1:], nil
+	}
+	if len(b) < 1+n {
+		return 0, false, nil, errShortPacket
+	}
+	for i := 0; i < n; i++ {
+		v |= uint64(b[1+i]) << (8 * uint(i))
+	}
+	return v, false, b[1+n:], nil
+}
+
+// readLenencString reads a length encoded string, NULL is returned empty.
+func readLenencString(b []byte) (string, []byte, error) {
+	n, null, rest, err := readLenencInt(b)
+	if err != nil || null {
+		return "", rest, err
+	}
+	if uint64(len(rest)) < n {
+		return "", nil, errShortPacket
+	}
+	return string(rest[:n]), rest[n:], nil
+}
+
+// isEOF returns whether payload is an EOF packet, as opposed to a row starting with 0xfe.
+func isEOF(payload []byte) bool {
+	return len(payload) > 0 && len(payload) < 9 && payload[0] == headerEOF
+}
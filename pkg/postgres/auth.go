@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Authentication methods of a Server, named like in pg_hba.conf.
+const (
+	AuthTrust    = "trust"
+	AuthPassword = "password"
+	AuthMD5      = "md5"
+	AuthSCRAM    = "scram-sha-256"
+)
+
+const scramMechanism = "SCRAM-SHA-256"
+
+// md5Password returns the response to an MD5 password request, "md5" + md5(md5(password + user) + salt).
+func md5Password(user, password string, salt []byte) string {
+	inner := md5.Sum([]byte(password + user))
+	outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt...))
+	return "md5" + hex.EncodeToString(outer[:])
+}
+
+// scramKeys are the keys derived from a password, as in RFC 5802.
+type scramKeys struct {
+	clientKey []byte
+	storedKey []byte
+	serverKey []byte
+}
+
+func newSCRAMKeys(password string, salt []byte, iterations int) scramKeys {
+	salted := pbkdf2SHA256([]byte(password), salt, iterations)
+	k := scramKeys{
+		clientKey: hmacSHA256(salted, []byte("Client Key")),
+		serverKey: hmacSHA256(salted, []byte("Server Key")),
+	}
+	stored := sha256.Sum256(k.clientKey)
+	k.storedKey = stored[:]
+	return k
+}
+
+// proof returns the ClientProof of authMessage.
+func (k scramKeys) proof(authMessage string) []byte {
+	signature := hmacSHA256(k.storedKey, []byte(authMessage))
+	proof := make([]byte, len(k.clientKey))
+	for i := range proof {
+		proof[i] = k.clientKey[i] ^ signature[i]
+	}
+	return proof
+}
+
+// serverSignature returns the ServerSignature of authMessage.
+func (k scramKeys) serverSignature(authMessage string) []byte {
+	return hmacSHA256(k.serverKey, []byte(authMessage))
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// pbkdf2SHA256 derives a 32 bytes key, the single block that SCRAM-SHA-256 uses.
+func pbkdf2SHA256(password, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+	key := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+func nonce() string {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail on supported platforms.
+		panic(err)
+	}
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+// scramAttributes parses a SCRAM message like "r=...,s=...,i=4096".
+func scramAttributes(msg string) map[byte]string {
+	attrs := map[byte]string{}
+	for _, attr := range strings.Split(msg, ",") {
+		if len(attr) >= 2 && attr[1] == '=' {
+			attrs[attr[0]] = attr[2:]
+		}
+	}
+	return attrs
+}
+
+// scramClient runs the client side of a SCRAM-SHA-256 exchange.
+type scramClient struct {
+	password        string
+	clientNonce     string
+	clientFirstBare string
+	authMessage     string
+	keys            scramKeys
+}
+
+func newSCRAMClient(password string) *scramClient {
+	c := &scramClient{password: password, clientNonce: nonce()}
+	// the user name is taken from the startup message, so it is left empty here.
+	c.clientFirstBare = "n=,r=" + c.clientNonce
+	return c
+}
+
+// initialResponse returns the payload of the SASLInitialResponse message.
+func (c *scramClient) initialResponse() []byte {
+	msg := "n,," + c.clientFirstBare
+	b := appendCString(nil, scramMechanism)
+	b = appendInt32(b, int32(len(msg)))
+	return append(b, msg...)
+}
+
+// finalMessage returns the client-final-message answering serverFirst.
+func (c *scramClient) finalMessage(serverFirst string) (string, error) {
+	attrs := scramAttributes(serverFirst)
+	serverNonce := attrs['r']
+	if !strings.HasPrefix(serverNonce, c.clientNonce) || len(serverNonce) == len(c.clientNonce) {
+		return "", fmt.Errorf("invalid SCRAM server nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs['s'])
+	if err != nil {
+		return "", fmt.Errorf("invalid SCRAM salt: %v", err)
+	}
+	iterations, err := strconv.Atoi(attrs['i'])
+	if err != nil || iterations < 1 {
+		return "", fmt.Errorf("invalid SCRAM iteration count %q", attrs['i'])
+	}
+	c.keys = newSCRAMKeys(c.password, salt, iterations)
+	withoutProof := "c=biws,r=" + serverNonce
+	c.authMessage = c.clientFirstBare + "," + serverFirst + "," + withoutProof
+	return withoutProof + ",p=" + base64.StdEncoding.EncodeToString(c.keys.proof(c.authMessage)), nil
+}
+
+// verify checks the server-final-message, proving that the server knows the password too.
+func (c *scramClient) verify(serverFinal string) error {
+	attrs := scramAttributes(serverFinal)
+	if e, ok := attrs['e']; ok {
+		return fmt.Errorf("SCRAM authentication failed: %s", e)
+	}
+	signature, err := base64.StdEncoding.DecodeString(attrs['v'])
+	if err != nil || !hmac.Equal(signature, c.keys.serverSignature(c.authMessage)) {
+		return fmt.Errorf("invalid SCRAM server signature")
+	}
+	return nil
+}
+
+// authRequest returns the payload of an Authentication message.
+func authRequest(code int32, data []byte) []byte {
+	b := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(b, uint32(code))
+	return append(b, data...)
+}
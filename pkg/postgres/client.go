@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+)
+
+// Conn is an authenticated client connection.
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	// Params are the run-time parameters reported by the server, e.g. server_version.
+	Params map[string]string
+}
+
+// Connect authenticates as user to database on conn, with a cleartext, MD5 or SCRAM-SHA-256 password.
+// An ErrorResponse of the server is returned as an *Error.
+func Connect(conn net.Conn, user, password, database string) (*Conn, error) {
+	c := &Conn{conn: conn, r: bufio.NewReader(conn), Params: map[string]string{}}
+
+	startup := appendInt32(nil, protocolVersion)
+	startup = appendCString(startup, "user")
+	startup = appendCString(startup, user)
+	if database != "" {
+		startup = appendCString(startup, "database")
+		startup = appendCString(startup, database)
+	}
+	startup = appendCString(startup, "application_name")
+	startup = appendCString(startup, "prober-demo")
+	startup = append(startup, 0)
+	if err := writeMessage(conn, 0, startup); err != nil {
+		return nil, err
+	}
+
+	var scram *scramClient
+	for {
+		typ, payload, err := readMessage(c.r)
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case msgErrorResponse:
+			return nil, parseError(payload)
+		case msgAuthentication:
+			if err = c.authenticate(payload, user, password, &scram); err != nil {
+				return nil, err
+			}
+		case msgParameterStatus:
+			name, rest := cString(payload)
+			value, _ := cString(rest)
+			c.Params[name] = value
+		case msgBackendKeyData, msgNoticeResponse:
+		case msgReadyForQuery:
+			return c, nil
+		default:
+			return nil, fmt.Errorf("unexpected message %q during startup", typ)
+		}
+	}
+}
+
+func (c *Conn) authenticate(payload []byte, user, password string, scram **scramClient) error {
+	code, data, err := readInt32(payload)
+	if err != nil {
+		return err
+	}
+	switch code {
+	case authOK:
+		return nil
+	case authCleartextPassword:
+		return writeMessage(c.conn, msgPassword, appendCString(nil, password))
+	case authMD5Password:
+		if len(data) < 4 {
+			return errShortMessage
+		}
+		return writeMessage(c.conn, msgPassword, appendCString(nil, md5Password(user, password, data[:4])))
+	case authSASL:
+		for mechanisms := data; len(mechanisms) > 1; {
+			var name string
+			name, mechanisms = cString(mechanisms)
+			if name == scramMechanism {
+				*scram = newSCRAMClient(password)
+				return writeMessage(c.conn, msgPassword, (*scram).initialResponse())
+			}
+		}
+		return fmt.Errorf("server offers no supported SASL mechanism")
+	case authSASLContinue:
+		if *scram == nil {
+			return fmt.Errorf("unexpected SASL continue message")
+		}
+		final, err := (*scram).finalMessage(string(data))
+		if err != nil {
+			return err
+		}
+		return writeMessage(c.conn, msgPassword, []byte(final))
+	case authSASLFinal:
+		if *scram == nil {
+			return fmt.Errorf("unexpected SASL final message")
+		}
+		return (*scram).verify(string(data))
+	}
+	return fmt.Errorf("unsupported authentication method %d", code)
+}
+
+// Query runs sql with the simple query protocol and returns the rows of its result as text.
+func (c *Conn) Query(sql string) ([][]string, error) {
+	if err := writeMessage(c.conn, msgQuery, appendCString(nil, sql)); err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	var queryErr error
+	for {
+		typ, payload, err := readMessage(c.r)
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case msgDataRow:
+			row, err := parseDataRow(payload)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		case msgErrorResponse:
+			queryErr = parseError(payload)
+		case msgReadyForQuery:
+			return rows, queryErr
+		}
+	}
+}
+
+// Close terminates the session and closes the connection.
+func (c *Conn) Close() error {
+	writeMessage(c.conn, msgTerminate, nil)
+	return c.conn.Close()
+}
@@ -0,0 +1,349 @@
+package postgres
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/appscode/go/log"
+)
+
+// serverVersion is reported by a Server as server_version.
+const serverVersion = "14.0 (prober-demo)"
+
+// scramIterations is the iteration count a Server asks SCRAM-SHA-256 clients for.
+const scramIterations = 4096
+
+// Database is a database served by a Server, with the state that probes check.
+type Database struct {
+	// StartingUp rejects every connection, like a server that is not accepting connections yet.
+	StartingUp bool
+	// InRecovery makes the database a hot standby: in recovery and read-only.
+	InRecovery bool
+	// ReadOnly makes the transactions read-only by default.
+	ReadOnly bool
+	// Hang makes the server stop answering once the client is authenticated.
+	Hang bool
+}
+
+// Server is a fake PostgreSQL server that authenticates clients and answers a few trivial queries.
+type Server struct {
+	// Users maps user names to their password.
+	Users map[string]string
+	// Auth is the authentication method, one of AuthTrust, AuthPassword, AuthMD5 and AuthSCRAM.
+	Auth string
+	// Databases maps database names to their state.
+	Databases map[string]Database
+}
+
+// Serve serves the connections accepted by l until it is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := s.serveConn(conn); err != nil && err != io.EOF {
+				log.Debugf("postgres connection from %v: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	params, err := readStartup(conn, r)
+	if err != nil || params == nil {
+		return err
+	}
+	user := params["user"]
+	dbName := params["database"]
+	if dbName == "" {
+		dbName = user
+	}
+
+	db, found := s.Databases[dbName]
+	if found && db.StartingUp {
+		return writeError(conn, CodeCannotConnectNow, "the database system is starting up")
+	}
+	if err = s.authenticate(conn, r, user); err != nil {
+		return err
+	}
+	if !found {
+		return writeError(conn, CodeInvalidCatalogName, fmt.Sprintf("database %q does not exist", dbName))
+	}
+	if err = writeMessage(conn, msgAuthentication, authRequest(authOK, nil)); err != nil {
+		return err
+	}
+	if db.Hang {
+		// wait for the client to give up.
+		_, err = io.Copy(ioutil.Discard, conn)
+		return err
+	}
+
+	for _, p := range [][2]string{
+		{"server_version", serverVersion},
+		{"server_encoding", "UTF8"},
+		{"client_encoding", "UTF8"},
+		{"in_hot_standby", onOff(db.InRecovery)},
+		{"default_transaction_read_only", onOff(db.ReadOnly || db.InRecovery)},
+	} {
+		if err = writeMessage(conn, msgParameterStatus, appendCString(appendCString(nil, p[0]), p[1])); err != nil {
+			return err
+		}
+	}
+	key := make([]byte, 8)
+	rand.Read(key)
+	if err = writeMessage(conn, msgBackendKeyData, key); err != nil {
+		return err
+	}
+	if err = writeMessage(conn, msgReadyForQuery, []byte{'I'}); err != nil {
+		return err
+	}
+
+	for {
+		typ, payload, err := readMessage(r)
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case msgQuery:
+			sql, _ := cString(payload)
+			err = s.answer(conn, db, sql)
+		case msgTerminate:
+			return nil
+		default:
+			err = sendError(conn, CodeFeatureNotSupported, fmt.Sprintf("message type %q is not supported", typ))
+		}
+		if err == nil {
+			err = writeMessage(conn, msgReadyForQuery, []byte{'I'})
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readStartup reads the startup message and returns its parameters.
+// SSL requests are declined, and cancel requests return no parameters.
+func readStartup(conn net.Conn, r *bufio.Reader) (map[string]string, error) {
+	for {
+		payload, err := readPayload(r)
+		if err != nil {
+			return nil, err
+		}
+		version, rest, err := readInt32(payload)
+		if err != nil {
+			return nil, err
+		}
+		switch version {
+		case sslRequestCode:
+			if _, err = conn.Write([]byte{'N'}); err != nil {
+				return nil, err
+			}
+			continue
+		case cancelRequest:
+			return nil, nil
+		case protocolVersion:
+		default:
+			return nil, writeError(conn, CodeProtocolViolation, fmt.Sprintf("unsupported frontend protocol %d.%d", version>>16, version&0xffff))
+		}
+		params := map[string]string{}
+		for len(rest) > 1 {
+			var name, value string
+			name, rest = cString(rest)
+			value, rest = cString(rest)
+			params[name] = value
+		}
+		return params, nil
+	}
+}
+
+// authenticate asks user for a password with the configured method.
+// A rejected password is sent to the client and returned as an error.
+func (s *Server) authenticate(conn net.Conn, r *bufio.Reader, user string) error {
+	password, known := s.Users[user]
+	failed := func() error {
+		return writeError(conn, CodeInvalidPassword, fmt.Sprintf("password authentication failed for user %q", user))
+	}
+
+	switch s.Auth {
+	case AuthTrust, "":
+		return nil
+	case AuthPassword:
+		sent, err := s.readPassword(conn, r, authRequest(authCleartextPassword, nil))
+		if err != nil {
+			return err
+		}
+		if !known || sent != password {
+			return failed()
+		}
+		return nil
+	case AuthMD5:
+		salt := make([]byte, 4)
+		rand.Read(salt)
+		sent, err := s.readPassword(conn, r, authRequest(authMD5Password, salt))
+		if err != nil {
+			return err
+		}
+		if !known || sent != md5Password(user, password, salt) {
+			return failed()
+		}
+		return nil
+	case AuthSCRAM:
+		ok, err := s.scram(conn, r, password)
+		if err != nil {
+			return err
+		}
+		if !ok || !known {
+			return failed()
+		}
+		return nil
+	}
+	return writeError(conn, CodeInvalidAuthorization, fmt.Sprintf("unsupported authentication method %q", s.Auth))
+}
+
+// readPassword sends an authentication request and returns the password message answering it.
+func (s *Server) readPassword(conn net.Conn, r *bufio.Reader, request []byte) (string, error) {
+	payload, err := s.exchange(conn, r, request)
+	if err != nil {
+		return "", err
+	}
+	password, _ := cString(payload)
+	return password, nil
+}
+
+// exchange sends an authentication request and returns the payload of the password message answering it.
+func (s *Server) exchange(conn net.Conn, r *bufio.Reader, request []byte) ([]byte, error) {
+	if err := writeMessage(conn, msgAuthentication, request); err != nil {
+		return nil, err
+	}
+	typ, payload, err := readMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	if typ != msgPassword {
+		return nil, writeError(conn, CodeProtocolViolation, fmt.Sprintf("expected password response, got message type %q", typ))
+	}
+	return payload, nil
+}
+
+// scram runs the server side of a SCRAM-SHA-256 exchange and returns whether the client proved to know password.
+func (s *Server) scram(conn net.Conn, r *bufio.Reader, password string) (bool, error) {
+	payload, err := s.exchange(conn, r, authRequest(authSASL, append(appendCString(nil, scramMechanism), 0)))
+	if err != nil {
+		return false, err
+	}
+	mechanism, rest := cString(payload)
+	length, rest, err := readInt32(rest)
+	if err != nil || mechanism != scramMechanism || int(length) != len(rest) || !strings.HasPrefix(string(rest), "n,,") {
+		return false, writeError(conn, CodeProtocolViolation, "invalid SASL initial response")
+	}
+	clientFirstBare := strings.TrimPrefix(string(rest), "n,,")
+	clientNonce := scramAttributes(clientFirstBare)['r']
+
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	serverNonce := clientNonce + nonce()
+	serverFirst := fmt.Sprintf("r=%s,s=%s,i=%d", serverNonce, base64.StdEncoding.EncodeToString(salt), scramIterations)
+	payload, err = s.exchange(conn, r, authRequest(authSASLContinue, []byte(serverFirst)))
+	if err != nil {
+		return false, err
+	}
+
+	clientFinal := string(payload)
+	i := strings.LastIndex(clientFinal, ",p=")
+	if i < 0 || scramAttributes(clientFinal)['r'] != serverNonce {
+		return false, writeError(conn, CodeProtocolViolation, "invalid SCRAM client final message")
+	}
+	proof, err := base64.StdEncoding.DecodeString(clientFinal[i+3:])
+	if err != nil {
+		return false, writeError(conn, CodeProtocolViolation, "invalid SCRAM client proof")
+	}
+	keys := newSCRAMKeys(password, salt, scramIterations)
+	authMessage := clientFirstBare + "," + serverFirst + "," + clientFinal[:i]
+	if !hmac.Equal(proof, keys.proof(authMessage)) {
+		return false, nil
+	}
+	serverFinal := "v=" + base64.StdEncoding.EncodeToString(keys.serverSignature(authMessage))
+	return true, writeMessage(conn, msgAuthentication, authRequest(authSASLFinal, []byte(serverFinal)))
+}
+
+var selectNumber = regexp.MustCompile(`^select\s+(-?\d+)$`)
+
+// answer answers the few queries that probes run, and fails any other.
+func (s *Server) answer(conn net.Conn, db Database, sql string) error {
+	query := strings.ToLower(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(sql), ";")))
+	var column, value, tag string
+	switch {
+	case query == "":
+		return writeMessage(conn, msgEmptyQuery, nil)
+	case selectNumber.MatchString(query):
+		column, value, tag = "?column?", selectNumber.FindStringSubmatch(query)[1], "SELECT 1"
+	case query == "select pg_is_in_recovery()":
+		column, value, tag = "pg_is_in_recovery", boolText(db.InRecovery), "SELECT 1"
+	case query == "show transaction_read_only":
+		column, value, tag = "transaction_read_only", onOff(db.ReadOnly || db.InRecovery), "SHOW"
+	case query == "select version()":
+		column, value, tag = "version", "PostgreSQL "+serverVersion, "SELECT 1"
+	default:
+		return sendError(conn, CodeFeatureNotSupported, fmt.Sprintf("query %q is not supported by this server", sql))
+	}
+
+	// one text column, described like a real server does.
+	desc := appendInt16(nil, 1)
+	desc = appendCString(desc, column)
+	desc = appendInt32(desc, 0)  // table OID
+	desc = appendInt16(desc, 0)  // column number
+	desc = appendInt32(desc, 25) // text type OID
+	desc = appendInt16(desc, -1) // variable length
+	desc = appendInt32(desc, -1) // type modifier
+	desc = appendInt16(desc, 0)  // text format
+	if err := writeMessage(conn, msgRowDescription, desc); err != nil {
+		return err
+	}
+	row := appendInt16(nil, 1)
+	row = appendInt32(row, int32(len(value)))
+	row = append(row, value...)
+	if err := writeMessage(conn, msgDataRow, row); err != nil {
+		return err
+	}
+	return writeMessage(conn, msgCommandComplete, appendCString(nil, tag))
+}
+
+// sendError sends an error that leaves the session usable.
+func sendError(conn net.Conn, code, message string) error {
+	return writeMessage(conn, msgErrorResponse, (&Error{Severity: "ERROR", Code: code, Message: message}).encode())
+}
+
+// writeError sends an error that ends the session, and returns it.
+func writeError(conn net.Conn, code, message string) error {
+	e := &Error{Severity: "FATAL", Code: code, Message: message}
+	if err := writeMessage(conn, msgErrorResponse, e.encode()); err != nil {
+		return err
+	}
+	return e
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func boolText(b bool) string {
+	if b {
+		return "t"
+	}
+	return "f"
+}
@@ -0,0 +1,204 @@
+// Package postgres implements the part of the PostgreSQL frontend/backend protocol (version 3.0)
+// needed to authenticate and run simple queries, on the client and on the server side.
+package postgres
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Message types, sent by the frontend (client) or the backend (server).
+const (
+	msgAuthentication  = 'R'
+	msgBackendKeyData  = 'K'
+	msgCommandComplete = 'C'
+	msgDataRow         = 'D'
+	msgEmptyQuery      = 'I'
+	msgErrorResponse   = 'E'
+	msgNoticeResponse  = 'N'
+	msgParameterStatus = 'S'
+	msgPassword        = 'p'
+	msgQuery           = 'Q'
+	msgReadyForQuery   = 'Z'
+	msgRowDescription  = 'T'
+	msgTerminate       = 'X'
+)
+
+// Authentication request codes of msgAuthentication.
+const (
+	authOK                = 0
+	authCleartextPassword = 3
+	authMD5Password       = 5
+	authSASL              = 10
+	authSASLContinue      = 11
+	authSASLFinal         = 12
+)
+
+const (
+	protocolVersion = 196608 // 3.0
+	sslRequestCode  = 80877103
+	cancelRequest   = 80877102
+	// maxMessageLength bounds the messages read, the protocol allows much more than a probe needs.
+	maxMessageLength = 1 << 20
+)
+
+// SQLSTATE codes told apart by probes.
+const (
+	CodeInvalidPassword       = "28P01"
+	CodeInvalidAuthorization  = "28000"
+	CodeCannotConnectNow      = "57P03"
+	CodeInvalidCatalogName    = "3D000"
+	CodeReadOnlyTransaction   = "25006"
+	CodeFeatureNotSupported   = "0A000"
+	CodeProtocolViolation     = "08P01"
+	CodeTooManyConnections    = "53300"
+	CodeInsufficientPrivilege = "42501"
+)
+
+// Error is an ErrorResponse of the server.
+type Error struct {
+	Severity string
+	Code     string
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s (SQLSTATE %s)", e.Severity, e.Message, e.Code)
+}
+
+// IsAuthError returns whether the error tells that the credentials were rejected.
+func (e *Error) IsAuthError() bool {
+	return strings.HasPrefix(e.Code, "28")
+}
+
+func parseError(payload []byte) *Error {
+	e := &Error{}
+	for len(payload) > 1 {
+		field := payload[0]
+		value, rest := cString(payload[1:])
+		switch field {
+		case 'S':
+			e.Severity = value
+		case 'C':
+			e.Code = value
+		case 'M':
+			e.Message = value
+		}
+		payload = rest
+	}
+	return e
+}
+
+func (e *Error) encode() []byte {
+	var b []byte
+	b = appendField(b, 'S', e.Severity)
+	b = appendField(b, 'V', e.Severity)
+	b = appendField(b, 'C', e.Code)
+	b = appendField(b, 'M', e.Message)
+	return append(b, 0)
+}
+
+func appendField(b []byte, field byte, value string) []byte {
+	b = append(b, field)
+	return appendCString(b, value)
+}
+
+// readMessage reads a typed message and returns its type and payload.
+func readMessage(r *bufio.Reader) (byte, []byte, error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	payload, err := readPayload(r)
+	return typ, payload, err
+}
+
+// readPayload reads the length prefixed payload of a message.
+func readPayload(r io.Reader) ([]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint32(length[:])) - 4
+	if n < 0 || n > maxMessageLength {
+		return nil, fmt.Errorf("invalid message length %d", n+4)
+	}
+	payload := make([]byte, n)
+	_, err := io.ReadFull(r, payload)
+	return payload, err
+}
+
+// writeMessage writes a message of the given type. Type 0 writes an untyped startup message.
+func writeMessage(w io.Writer, typ byte, payload []byte) error {
+	b := make([]byte, 0, 5+len(payload))
+	if typ != 0 {
+		b = append(b, typ)
+	}
+	b = appendInt32(b, int32(4+len(payload)))
+	b = append(b, payload...)
+	_, err := w.Write(b)
+	return err
+}
+
+func appendInt32(b []byte, v int32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendInt16(b []byte, v int16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendCString(b []byte, s string) []byte {
+	b = append(b, s...)
+	return append(b, 0)
+}
+
+// cString splits the NUL terminated string at the start of b from the rest of b.
+func cString(b []byte) (string, []byte) {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i]), b[i+1:]
+		}
+	}
+	return string(b), nil
+}
+
+var errShortMessage = errors.New("short message")
+
+func readInt32(b []byte) (int32, []byte, error) {
+	if len(b) < 4 {
+		return 0, nil, errShortMessage
+	}
+	return int32(binary.BigEndian.Uint32(b)), b[4:], nil
+}
+
+// parseDataRow returns the columns of a DataRow message, NULL columns are returned empty.
+func parseDataRow(payload []byte) ([]string, error) {
+	if len(payload) < 2 {
+		return nil, errShortMessage
+	}
+	n := int(binary.BigEndian.Uint16(payload))
+	payload = payload[2:]
+	row := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		length, rest, err := readInt32(payload)
+		if err != nil {
+			return nil, err
+		}
+		payload = rest
+		if length < 0 {
+			row = append(row, "")
+			continue
+		}
+		if int(length) > len(payload) {
+			return nil, errShortMessage
+		}
+		row = append(row, string(payload[:length]))
+		payload = payload[length:]
+	}
+	return row, nil
+}
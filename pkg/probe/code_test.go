@@ -0,0 +1,151 @@
+package probe
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	api "kmodules.xyz/prober/api"
+	prober_v1 "kmodules.xyz/prober/api/v1"
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+	"stash.appscode.dev/prober-demo/pkg/probe/failure"
+)
+
+// listen starts a TCP listener on a free local port that handles every connection with handle, until it is closed.
+func listen(t *testing.T, handle func(conn net.Conn)) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+	return l
+}
+
+func listenerPort(l net.Listener) int {
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// closedPort returns a local port nothing listens on, like the port 9091 of run-client.
+func closedPort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	return port
+}
+
+func tcpHandler(port int, exchange *api_v1.TCPExchange) api_v1.Handler {
+	return api_v1.Handler{
+		Handler: prober_v1.Handler{
+			TCPSocket: &core.TCPSocketAction{Port: intstr.FromInt(port), Host: "127.0.0.1"},
+		},
+		TCPExchange: exchange,
+	}
+}
+
+func httpHandler(t *testing.T, rawURL string) api_v1.Handler {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api_v1.Handler{Handler: prober_v1.Handler{
+		HTTPGet: &core.HTTPGetAction{
+			Path:   u.Path,
+			Port:   intstr.FromInt(port),
+			Host:   u.Hostname(),
+			Scheme: core.URIScheme(u.Scheme),
+		},
+	}}
+}
+
+func TestFailureCodes(t *testing.T) {
+	// the reset mode of run-client: accept, then close with a RST.
+	reset := listen(t, func(conn net.Conn) {
+		conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+	})
+	defer reset.Close()
+	// the silent mode of run-client: accept and never write.
+	silent := listen(t, func(conn net.Conn) {
+		time.Sleep(5 * time.Second)
+		conn.Close()
+	})
+	defer silent.Close()
+	// the reply mode of run-client: read the request, write once, then close.
+	reply := listen(t, func(conn net.Conn) {
+		conn.Read(make([]byte, 64))
+		conn.Write([]byte("Message received."))
+		conn.Close()
+	})
+	defer reply.Close()
+	status := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer status.Close()
+	loop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/", http.StatusFound)
+	}))
+	defer loop.Close()
+	// httptest signs its certificate with a CA no system trusts.
+	untrusted := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer untrusted.Close()
+	verified := httpHandler(t, untrusted.URL+"/")
+	verified.TLS = &api_v1.TLSConfig{}
+	unresolvable := httpHandler(t, status.URL+"/")
+	unresolvable.HTTPGet.Host = "does-not-exist.invalid"
+
+	tests := []struct {
+		name    string
+		handler api_v1.Handler
+		want    failure.Code
+	}{
+		{"tcp closed port", tcpHandler(closedPort(t), nil), failure.ConnectionRefused},
+		{"http closed port", httpHandler(t, "http://127.0.0.1:"+strconv.Itoa(closedPort(t))+"/"), failure.ConnectionRefused},
+		{"tcp reset", tcpHandler(listenerPort(reset), &api_v1.TCPExchange{Send: `PING\r\n`, ExpectBytes: "PONG"}), failure.ConnectionReset},
+		{"tcp silent", tcpHandler(listenerPort(silent), &api_v1.TCPExchange{Send: `PING\r\n`, ExpectBytes: "PONG", ReadTimeout: &metav1.Duration{Duration: 200 * time.Millisecond}}), failure.Timeout},
+		{"tcp closed by peer", tcpHandler(listenerPort(reply), &api_v1.TCPExchange{Send: `PING\r\n`, ExpectBytes: "PONG"}), failure.ConnectionClosed},
+		{"http unresolvable host", unresolvable, failure.NXDomain},
+		{"http untrusted CA", verified, failure.TLSError},
+		{"http status", httpHandler(t, status.URL+"/"), failure.HTTPStatus},
+		{"http redirect loop", httpHandler(t, loop.URL+"/"), failure.TooManyRedirects},
+	}
+	pb := NewProber(nil)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report, err := pb.Run(&test.handler, &core.Pod{}, core.PodStatus{}, core.Container{}, 2*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Result != api.Failure {
+				t.Fatalf("result = %s, want failure: %s", report.Result, report.Reason)
+			}
+			if test.want == failure.NXDomain && report.Code == failure.DNSError {
+				t.Skipf("no resolver answers for the .invalid TLD: %s", report.Reason)
+			}
+			if report.Code != test.want {
+				t.Errorf("code = %s, want %s: %s", report.Code, test.want, report.Reason)
+			}
+		})
+	}
+}
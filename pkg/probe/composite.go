@@ -0,0 +1,230 @@
+package probe
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+
+	core "k8s.io/api/core/v1"
+	api "kmodules.xyz/prober/api"
+)
+
+// Modes of a composite probe.
+const (
+	compositeAllOf    = "allOf"
+	compositeAnyOf    = "anyOf"
+	compositeSequence = "sequence"
+)
+
+// severity orders results from the best to the worst.
+var severity = map[api.Result]int{
+	api.Success: 0,
+	api.Warning: 1,
+	api.Unknown: 2,
+	api.Failure: 3,
+}
+
+// childResult is the outcome of a probe nested in a composite probe.
+type childResult struct {
+	result  api.Result
+	reason  string
+	latency time.Duration
+	// composite tells that reason is the tree of a nested composite probe.
+	composite bool
+	// skipped tells that the probe did not run, as a previous probe of the sequence failed.
+	skipped bool
+}
+
+func (pb *Prober) runComposite(p *api_v1.Handler, pod *core.Pod, status core.PodStatus, container core.Container, timeout time.Duration) (api.Result, string, error) {
+	mode, children, err := compositeChildren(p.Composite)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	start := time.Now()
+	results := make([]childResult, len(children))
+	run := func(i int, timeout time.Duration) {
+		childStart := time.Now()
+		result, reason, err := pb.RunProbe(&children[i], pod, status, container, timeout)
+		if err != nil {
+			result, reason = api.Unknown, "error: "+err.Error()
+		}
+		results[i] = childResult{
+			result:    result,
+			reason:    reason,
+			latency:   since(childStart),
+			composite: children[i].Composite != nil && err == nil,
+		}
+	}
+
+	if mode == compositeSequence {
+		deadline := start.Add(timeout)
+		for i := range children {
+			if i > 0 && severity[results[i-1].result] >= severity[api.Unknown] {
+				results[i] = childResult{skipped: true}
+				continue
+			}
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				results[i] = childResult{result: api.Failure, reason: fmt.Sprintf("timeout: no time left of %v", timeout)}
+				continue
+			}
+			run(i, remaining)
+		}
+	} else {
+		var wg sync.WaitGroup
+		for i := range children {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				run(i, timeout)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	result := combineResults(mode, results)
+	return result, compositeTree(mode, result, since(start), children, results), nil
+}
+
+// compositeChildren returns the mode and the nested probes of a composite probe.
+func compositeChildren(c *api_v1.CompositeAction) (string, []api_v1.Handler, error) {
+	var mode string
+	var children []api_v1.Handler
+	for _, m := range []struct {
+		mode     string
+		children []api_v1.Handler
+	}{
+		{compositeAllOf, c.AllOf},
+		{compositeAnyOf, c.AnyOf},
+		{compositeSequence, c.Sequence},
+	} {
+		if len(m.children) == 0 {
+			continue
+		}
+		if mode != "" {
+			return "", nil, fmt.Errorf("composite probe has both %s and %s, only one is allowed", mode, m.mode)
+		}
+		mode, children = m.mode, m.children
+	}
+	if mode == "" {
+		return "", nil, fmt.Errorf("composite probe has no probes, one of %s, %s or %s is required", compositeAllOf, compositeAnyOf, compositeSequence)
+	}
+	return mode, children, nil
+}
+
+// combineResults returns the best result of the probes run for anyOf, and the worst one otherwise.
+func combineResults(mode string, results []childResult) api.Result {
+	var combined api.Result
+	for _, r := range results {
+		if r.skipped {
+			continue
+		}
+		switch {
+		case combined == "":
+			combined = r.result
+		case mode == compositeAnyOf && severity[r.result] < severity[combined]:
+			combined = r.result
+		case mode != compositeAnyOf && severity[r.result] > severity[combined]:
+			combined = r.result
+		}
+	}
+	return combined
+}
+
+// compositeTree returns the reason of a composite probe, e.g.
+//
+//	allOf: failure (in 1.002s)
+//	|-- success (in 1.2ms) http GET :8080/healthz
+//	`-- failure (in 1.001s) tcpSocket :5432: dial tcp 10.0.0.5:5432: i/o timeout
+func compositeTree(mode string, result api.Result, latency time.Duration, children []api_v1.Handler, results []childResult) string {
+	nodes := make([]string, len(results))
+	for i, r := range results {
+		switch {
+		case r.skipped:
+			nodes[i] = "skipped " + Describe(&children[i])
+		case r.composite:
+			nodes[i] = r.reason
+		default:
+			nodes[i] = treeNode(r.result, r.latency, Describe(&children[i]), r.reason)
+		}
+	}
+	return formatTree(fmt.Sprintf("%s: %s (in %v)", mode, result, latency), nodes)
+}
+
+// treeNode formats the line of a tree for a probe or a step.
+func treeNode(result api.Result, latency time.Duration, name string, reason string) string {
+	node := fmt.Sprintf("%s (in %v) %s", result, latency, name)
+	if reason != "" {
+		node += ": " + reason
+	}
+	return node
+}
+
+// formatTree returns header followed by nodes drawn as its branches.
+// The lines of a node after the first one, e.g. the branches of a nested tree, are indented below it.
+func formatTree(header string, nodes []string) string {
+	var b strings.Builder
+	b.WriteString(header)
+	for i, node := range nodes {
+		branch, indent := "|-- ", "|   "
+		if i == len(nodes)-1 {
+			branch, indent = "`-- ", "    "
+		}
+		lines := strings.Split(strings.TrimRight(node, "\n"), "\n")
+		b.WriteString("\n" + branch + lines[0])
+		for _, line := range lines[1:] {
+			b.WriteString("\n" + indent + line)
+		}
+	}
+	return b.String()
+}
+
+// Describe returns the kind of action of a probe and its target, e.g. "tcpSocket :5432".
+func Describe(p *api_v1.Handler) string {
+	switch {
+	case p.HTTPScenario != nil:
+		return fmt.Sprintf("httpScenario %s of %d steps", target(p.HTTPScenario.Host, p.HTTPScenario.Port.String()), len(p.HTTPScenario.Steps))
+	case p.Composite != nil:
+		mode, children, err := compositeChildren(p.Composite)
+		if err != nil {
+			return "composite"
+		}
+		return fmt.Sprintf("%s of %d", mode, len(children))
+	case p.TLSCert != nil:
+		return "tlsCert " + target(p.TLSCert.Host, p.TLSCert.Port.String())
+	case p.GRPC != nil:
+		return "grpc " + target(p.GRPC.Host, p.GRPC.Port.String())
+	case p.UDP != nil:
+		return "udp " + target(p.UDP.Host, p.UDP.Port.String())
+	case p.DNS != nil:
+		return "dns " + p.DNS.Name
+	case p.Postgres != nil:
+		return "postgres " + target(p.Postgres.Host, p.Postgres.Port.String())
+	case p.MySQL != nil:
+		return "mysql " + target(p.MySQL.Host, p.MySQL.Port.String())
+	case p.Redis != nil:
+		return "redis " + target(p.Redis.Host, p.Redis.Port.String())
+	case p.MongoDB != nil:
+		return "mongodb " + target(p.MongoDB.Host, p.MongoDB.Port.String())
+	case p.TCPSocket != nil:
+		return "tcpSocket " + target(p.TCPSocket.Host, p.TCPSocket.Port.String())
+	case p.Exec != nil:
+		return "exec " + strings.Join(p.Exec.Command, " ")
+	}
+	if action := httpAction(p); action != nil {
+		method := strings.ToUpper(action.Method)
+		if method == "" {
+			method = "GET"
+		}
+		return fmt.Sprintf("http %s %s%s", method, target(action.Host, action.Port.String()), action.Path)
+	}
+	return "unknown action"
+}
+
+// target joins the host and port of a probe. The host is empty when it defaults to the pod IP.
+func target(host, port string) string {
+	return host + ":" + port
+}
@@ -0,0 +1,150 @@
+package probe
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+
+	core "k8s.io/api/core/v1"
+	api "kmodules.xyz/prober/api"
+)
+
+func TestCombineResults(t *testing.T) {
+	results := func(rs ...api.Result) []childResult {
+		out := make([]childResult, len(rs))
+		for i, r := range rs {
+			out[i] = childResult{result: r}
+		}
+		return out
+	}
+	tests := []struct {
+		name    string
+		mode    string
+		results []childResult
+		want    api.Result
+	}{
+		{"allOf success", compositeAllOf, results(api.Success, api.Success), api.Success},
+		{"allOf worst", compositeAllOf, results(api.Success, api.Warning, api.Failure, api.Unknown), api.Failure},
+		{"allOf unknown over warning", compositeAllOf, results(api.Warning, api.Unknown), api.Unknown},
+		{"anyOf best", compositeAnyOf, results(api.Failure, api.Warning, api.Unknown), api.Warning},
+		{"anyOf failure", compositeAnyOf, results(api.Failure, api.Failure), api.Failure},
+		{"sequence skipped", compositeSequence, append(results(api.Warning, api.Failure), childResult{skipped: true}), api.Failure},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := combineResults(test.mode, test.results); got != test.want {
+				t.Errorf("combineResults() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCompositeChildren(t *testing.T) {
+	child := []api_v1.Handler{tcpHandler(80, nil)}
+	tests := []struct {
+		name      string
+		composite api_v1.CompositeAction
+		mode      string
+	}{
+		{"allOf", api_v1.CompositeAction{AllOf: child}, compositeAllOf},
+		{"anyOf", api_v1.CompositeAction{AnyOf: child}, compositeAnyOf},
+		{"sequence", api_v1.CompositeAction{Sequence: child}, compositeSequence},
+		{"none", api_v1.CompositeAction{}, ""},
+		{"two modes", api_v1.CompositeAction{AllOf: child, Sequence: child}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mode, _, err := compositeChildren(&test.composite)
+			if mode != test.mode || (err == nil) != (test.mode != "") {
+				t.Errorf("compositeChildren() = %q, %v, want %q", mode, err, test.mode)
+			}
+		})
+	}
+}
+
+func TestFormatTree(t *testing.T) {
+	got := formatTree("allOf: failure (in 1s)", []string{
+		"success (in 1ms) tcpSocket :80",
+		"anyOf: failure (in 1s)\n|-- failure (in 1s) tcpSocket :81\n`-- failure (in 1s) tcpSocket :82",
+		"skipped tcpSocket :83",
+	})
+	want := strings.Join([]string{
+		"allOf: failure (in 1s)",
+		"|-- success (in 1ms) tcpSocket :80",
+		"|-- anyOf: failure (in 1s)",
+		"|   |-- failure (in 1s) tcpSocket :81",
+		"|   `-- failure (in 1s) tcpSocket :82",
+		"`-- skipped tcpSocket :83",
+	}, "\n")
+	if got != want {
+		t.Errorf("formatTree() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRunComposite(t *testing.T) {
+	open := listen(t, func(conn net.Conn) { conn.Close() })
+	defer open.Close()
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer redirect.Close()
+	up := tcpHandler(listenerPort(open), nil)
+	down := tcpHandler(closedPort(t), nil)
+	warning := httpHandler(t, redirect.URL+"/")
+	// a composite probe without probes is an error, its result is unknown.
+	invalid := api_v1.Handler{Composite: &api_v1.CompositeAction{}}
+
+	tests := []struct {
+		name      string
+		composite api_v1.CompositeAction
+		want      api.Result
+		// lines are the starts of the lines of the reason.
+		lines []string
+	}{
+		{"allOf", api_v1.CompositeAction{AllOf: []api_v1.Handler{up, warning}}, api.Warning, []string{
+			"allOf: warning", "|-- success", "`-- warning",
+		}},
+		{"allOf failure", api_v1.CompositeAction{AllOf: []api_v1.Handler{up, down}}, api.Failure, []string{
+			"allOf: failure", "|-- success", "`-- failure",
+		}},
+		{"anyOf", api_v1.CompositeAction{AnyOf: []api_v1.Handler{down, up}}, api.Success, []string{
+			"anyOf: success", "|-- failure", "`-- success",
+		}},
+		{"sequence", api_v1.CompositeAction{Sequence: []api_v1.Handler{warning, down, up}}, api.Failure, []string{
+			"sequence: failure", "|-- warning", "|-- failure", "`-- skipped tcpSocket 127.0.0.1:",
+		}},
+		{"sequence unknown", api_v1.CompositeAction{Sequence: []api_v1.Handler{invalid, up}}, api.Unknown, []string{
+			"sequence: unknown", "|-- unknown (in ", "`-- skipped",
+		}},
+		{"nested", api_v1.CompositeAction{AllOf: []api_v1.Handler{up, {Composite: &api_v1.CompositeAction{AnyOf: []api_v1.Handler{down, up}}}}}, api.Success, []string{
+			"allOf: success", "|-- success", "`-- anyOf: success", "    |-- failure", "    `-- success",
+		}},
+	}
+	pb := NewProber(nil)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := api_v1.Handler{Composite: &test.composite}
+			report, err := pb.Run(&handler, &core.Pod{}, core.PodStatus{}, core.Container{}, 2*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Result != test.want {
+				t.Errorf("result = %s, want %s:\n%s", report.Result, test.want, report.Reason)
+			}
+			lines := strings.Split(report.Reason, "\n")
+			if len(lines) != len(test.lines) {
+				t.Fatalf("reason has %d lines, want %d:\n%s", len(lines), len(test.lines), report.Reason)
+			}
+			for i := range lines {
+				if !strings.HasPrefix(lines[i], test.lines[i]) {
+					t.Errorf("line %d = %q, want it to start with %q", i, lines[i], test.lines[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"stash.appscode.dev/prober-demo/pkg/dns"
+
+	api "kmodules.xyz/prober/api"
+)
+
+// New creates Prober.
+func New() Prober {
+	return dnsProber{}
+}
+
+// Prober is an interface that defines the Probe function for doing DNS checks.
+type Prober interface {
+	Probe(name string, qtype dns.Type, server string, expect []string, warnLatency, maxLatency time.Duration, timeout time.Duration) (api.Result, string, error)
+}
+
+type dnsProber struct{}
+
+// Probe returns a ProbeRunner capable of running a DNS check.
+func (pr dnsProber) Probe(name string, qtype dns.Type, server string, expect []string, warnLatency, maxLatency time.Duration, timeout time.Duration) (api.Result, string, error) {
+	return DoDNSProbe(name, qtype, server, expect, warnLatency, maxLatency, timeout)
+}
+
+// DoDNSProbe resolves the records of the given type of name, with the system resolver or,
+// if server is not empty, by querying server (host:port) directly.
+// If the name can't be resolved, expect is set and differs from the set of answers, or resolving took longer
+// than maxLatency, it returns Failure. If resolving took longer than warnLatency, it returns Warning.
+// A zero latency is not checked. Otherwise, it returns Success. The reason shows the answers and how long resolving took.
+func DoDNSProbe(name string, qtype dns.Type, server string, expect []string, warnLatency, maxLatency time.Duration, timeout time.Duration) (api.Result, string, error) {
+	resolver := net.DefaultResolver
+	if server != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	answers, err := lookup(ctx, resolver, name, qtype)
+	latency := time.Since(start).Round(time.Microsecond)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok {
+			if server != "" {
+				// the resolver names the server of resolv.conf it dialed for.
+				dnsErr.Server = server
+			}
+			if dnsErr.IsNotFound {
+				return api.Failure, fmt.Sprintf("NXDOMAIN: %v (in %v)", err, latency), nil
+			}
+		}
+		// Convert errors to failures to handle timeouts.
+		return api.Failure, fmt.Sprintf("%v (in %v)", err, latency), nil
+	}
+	sort.Strings(answers)
+	if len(expect) > 0 && !sameSet(answers, expect) {
+		return api.Failure, fmt.Sprintf("expected %s %s to be %v, got %v (in %v)", qtype, name, expect, answers, latency), nil
+	}
+	resolved := fmt.Sprintf("%s %s = %v (in %v)", qtype, name, answers, latency)
+	switch {
+	case maxLatency > 0 && latency > maxLatency:
+		return api.Failure, fmt.Sprintf("resolving took longer than maxLatency %v: %s", maxLatency, resolved), nil
+	case warnLatency > 0 && latency > warnLatency:
+		return api.Warning, fmt.Sprintf("resolving took longer than warnLatency %v: %s", warnLatency, resolved), nil
+	}
+	return api.Success, resolved, nil
+}
+
+func lookup(ctx context.Context, resolver *net.Resolver, name string, qtype dns.Type) ([]string, error) {
+	switch qtype {
+	case dns.TypeA, dns.TypeAAAA:
+		network := "ip4"
+		if qtype == dns.TypeAAAA {
+			network = "ip6"
+		}
+		ips, err := resolver.LookupIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+		answers := make([]string, 0, len(ips))
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+		return answers, nil
+	case dns.TypeCNAME:
+		cname, err := resolver.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return []string{cname}, nil
+	case dns.TypeSRV:
+		_, srvs, err := resolver.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, err
+		}
+		answers := make([]string, 0, len(srvs))
+		for _, srv := range srvs {
+			answers = append(answers, strings.Join([]string{
+				strconv.Itoa(int(srv.Priority)), strconv.Itoa(int(srv.Weight)), strconv.Itoa(int(srv.Port)), srv.Target,
+			}, " "))
+		}
+		return answers, nil
+	}
+	return nil, fmt.Errorf("unsupported DNS record type %v", qtype)
+}
+
+// sameSet compares answers with the expected ones in any order, ignoring case and trailing dots.
+func sameSet(answers []string, expect []string) bool {
+	if len(answers) != len(expect) {
+		return false
+	}
+	a, b := normalize(answers), normalize(expect)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func normalize(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(v), "."))
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,101 @@
+package dns
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"stash.appscode.dev/prober-demo/pkg/dns"
+
+	api "kmodules.xyz/prober/api"
+)
+
+// serve starts a server of a small zone on UDP and TCP of the same local port, and returns its address.
+func serve(t *testing.T) (string, func()) {
+	var records []dns.Record
+	// too many for a UDP message, the resolver retries over TCP.
+	for i := 0; i < 40; i++ {
+		records = append(records, dns.Record{Name: "many.demo.test", Type: dns.TypeA, IP: net.IPv4(10, 0, 1, byte(i))})
+	}
+	records = append(records,
+		dns.Record{Name: "www.demo.test", Type: dns.TypeA, IP: net.ParseIP("10.0.0.1")},
+		dns.Record{Name: "www.demo.test", Type: dns.TypeA, IP: net.ParseIP("10.0.0.2")},
+		dns.Record{Name: "slow.demo.test", Type: dns.TypeA, IP: net.ParseIP("10.0.0.3")},
+		dns.Record{Name: "alias.demo.test", Type: dns.TypeCNAME, Target: "www.demo.test"},
+		dns.Record{Name: "_http._tcp.demo.test", Type: dns.TypeSRV, Priority: 10, Weight: 5, Port: 8080, Target: "www.demo.test"},
+	)
+	srv := &dns.Server{
+		Zone:   dns.NewZone("demo.test", records),
+		Delays: map[string]time.Duration{"slow.demo.test.": 100 * time.Millisecond},
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", conn.LocalAddr().String())
+	if err != nil {
+		conn.Close()
+		t.Fatal(err)
+	}
+	go srv.ServeUDP(conn)
+	go srv.ServeTCP(l)
+	return conn.LocalAddr().String(), func() {
+		conn.Close()
+		l.Close()
+	}
+}
+
+func TestDoDNSProbe(t *testing.T) {
+	server, stop := serve(t)
+	defer stop()
+	many := make([]string, 40)
+	for i := range many {
+		many[i] = "10.0.1." + strconv.Itoa(i)
+	}
+
+	tests := []struct {
+		name        string
+		qname       string
+		qtype       dns.Type
+		expect      []string
+		warnLatency time.Duration
+		maxLatency  time.Duration
+		want        api.Result
+		reason      string
+	}{
+		{"a", "www.demo.test.", dns.TypeA, nil, 0, 0, api.Success, "A www.demo.test. = [10.0.0.1 10.0.0.2]"},
+		{"expected in any order", "www.demo.test.", dns.TypeA, []string{"10.0.0.2", "10.0.0.1"}, 0, 0, api.Success, ""},
+		{"unexpected", "www.demo.test.", dns.TypeA, []string{"10.0.0.1"}, 0, 0, api.Failure, "expected A www.demo.test. to be [10.0.0.1]"},
+		{"cname", "alias.demo.test.", dns.TypeCNAME, []string{"WWW.demo.test"}, 0, 0, api.Success, ""},
+		{"srv", "_http._tcp.demo.test.", dns.TypeSRV, []string{"10 5 8080 www.demo.test."}, 0, 0, api.Success, ""},
+		{"truncated over udp", "many.demo.test.", dns.TypeA, many, 0, 0, api.Success, ""},
+		{"nxdomain", "missing.demo.test.", dns.TypeA, nil, 0, 0, api.Failure, "NXDOMAIN"},
+		{"refused", "www.example.com.", dns.TypeA, nil, 0, 0, api.Failure, server},
+		{"slow", "slow.demo.test.", dns.TypeA, nil, 10 * time.Millisecond, 0, api.Warning, "longer than warnLatency"},
+		{"too slow", "slow.demo.test.", dns.TypeA, nil, 10 * time.Millisecond, 50 * time.Millisecond, api.Failure, "longer than maxLatency"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, reason, err := DoDNSProbe(test.qname, test.qtype, server, test.expect, test.warnLatency, test.maxLatency, 2*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != test.want || !strings.Contains(reason, test.reason) {
+				t.Errorf("result = %s: %s, want %s: %s", result, reason, test.want, test.reason)
+			}
+		})
+	}
+}
+
+func TestDoDNSProbeUnsupportedType(t *testing.T) {
+	result, reason, err := DoDNSProbe("www.demo.test.", dns.Type(16), "127.0.0.1:53", nil, 0, 0, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != api.Failure || !strings.Contains(reason, "unsupported DNS record type TYPE16") {
+		t.Errorf("result = %s: %s, want %s for the unsupported type", result, reason, api.Failure)
+	}
+}
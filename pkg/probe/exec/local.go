@@ -0,0 +1,69 @@
+// Package exec runs the commands of exec probes on the local machine, for probes run outside of a cluster.
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	api "kmodules.xyz/prober/api"
+	execprobe "kmodules.xyz/prober/probe/exec"
+)
+
+const (
+	maxReadLength = 10 * 1 << 10 // 10KB
+)
+
+// NewLocal creates a Prober that runs the commands locally instead of in the container, killing them after timeout.
+func NewLocal(timeout time.Duration) execprobe.Prober {
+	return localProber{timeout: timeout}
+}
+
+type localProber struct {
+	timeout time.Duration
+}
+
+// Probe runs commands on the local machine, with the env of the process and the env of container that has a value.
+// Variables set from a source, like a Secret, are left out. The config and the pod are ignored.
+// If the command exits with 0, it returns Success. Otherwise, it returns Failure with the output of the command.
+func (pr localProber) Probe(config *rest.Config, pod *core.Pod, container core.Container, commands []string) (api.Result, string, error) {
+	if len(commands) == 0 {
+		return api.Unknown, "", errors.New("exec probe has no command")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pr.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, commands[0], commands[1:]...)
+	cmd.Env = os.Environ()
+	for _, env := range container.Env {
+		if env.ValueFrom == nil {
+			cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+		}
+	}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+
+	output := out.String()
+	if len(output) > maxReadLength {
+		output = output[:maxReadLength]
+	}
+	output = strings.TrimSpace(output)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return api.Failure, "command timed out after " + pr.timeout.String(), nil
+		}
+		if output == "" {
+			return api.Failure, err.Error(), nil
+		}
+		return api.Failure, err.Error() + ": " + output, nil
+	}
+	return api.Success, output, nil
+}
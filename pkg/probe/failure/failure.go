@@ -0,0 +1,135 @@
+// Package failure classifies the failures of network probes into stable reason codes,
+// so that alerts can tell a refused connection from a timeout without parsing error messages.
+package failure
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+)
+
+// Code is the class of a probe failure. Codes are CamelCase so that they can be used as Event reasons.
+type Code string
+
+const (
+	// ConnectionRefused means that nothing listens on the port, the host answered with a reset.
+	ConnectionRefused Code = "ConnectionRefused"
+	// ConnectionReset means that the peer reset an established connection.
+	ConnectionReset Code = "ConnectionReset"
+	// ConnectionClosed means that the peer closed the connection before the probe was done.
+	ConnectionClosed Code = "ConnectionClosed"
+	// Timeout means that the probe timed out, whatever it was waiting for.
+	Timeout Code = "Timeout"
+	// NXDomain means that the host name does not exist.
+	NXDomain Code = "NXDomain"
+	// DNSError means that the host name could not be resolved for another reason, e.g. SERVFAIL.
+	DNSError Code = "DNSError"
+	// NoRouteToHost means that the host is unreachable.
+	NoRouteToHost Code = "NoRouteToHost"
+	// NetworkUnreachable means that the network of the host is unreachable.
+	NetworkUnreachable Code = "NetworkUnreachable"
+	// TLSError means that the TLS handshake failed, e.g. because the certificate is not trusted.
+	TLSError Code = "TLSError"
+	// TooManyRedirects means that the probe stopped following redirects at its limit, e.g. in a redirect loop.
+	TooManyRedirects Code = "TooManyRedirects"
+	// HTTPStatus means that the server answered with a status code the probe does not accept.
+	HTTPStatus Code = "HTTPStatus"
+	// AssertionFailed means that the server answered with a successful status code,
+	// but the response does not meet the assertions of the probe.
+	AssertionFailed Code = "AssertionFailed"
+	// NetworkError is any other network error.
+	NetworkError Code = "NetworkError"
+)
+
+// Classify returns the code of err, NetworkError if it belongs to none of the other classes.
+// The errors wrapped by err are classified too, see causes.
+func Classify(err error) Code {
+	errs := causes(err)
+	for _, e := range errs {
+		if dnsErr, ok := e.(*net.DNSError); ok {
+			switch {
+			case dnsErr.IsNotFound:
+				return NXDomain
+			case dnsErr.IsTimeout:
+				return Timeout
+			}
+			return DNSError
+		}
+	}
+	for _, e := range errs {
+		if isTLSError(e) {
+			return TLSError
+		}
+	}
+	for _, e := range errs {
+		if errno, ok := e.(syscall.Errno); ok {
+			switch errno {
+			case syscall.ECONNREFUSED:
+				return ConnectionRefused
+			case syscall.ECONNRESET, syscall.EPIPE:
+				return ConnectionReset
+			case syscall.EHOSTUNREACH:
+				return NoRouteToHost
+			case syscall.ENETUNREACH:
+				return NetworkUnreachable
+			case syscall.ETIMEDOUT:
+				return Timeout
+			}
+		}
+	}
+	for _, e := range errs {
+		if netErr, ok := e.(net.Error); ok && netErr.Timeout() || e == context.DeadlineExceeded {
+			return Timeout
+		}
+	}
+	for _, e := range errs {
+		if e == io.EOF || e == io.ErrUnexpectedEOF {
+			return ConnectionClosed
+		}
+	}
+	return NetworkError
+}
+
+// causes returns err followed by the errors it wraps. The wrappers of the standard library that probes get
+// are unwrapped by type, as Go 1.12 has no errors.Unwrap. Other errors are unwrapped with their Unwrap method.
+func causes(err error) []error {
+	var errs []error
+	for err != nil && len(errs) < maxCauses {
+		errs = append(errs, err)
+		switch e := err.(type) {
+		case *url.Error:
+			err = e.Err
+		case *net.OpError:
+			err = e.Err
+		case *os.SyscallError:
+			err = e.Err
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			err = nil
+		}
+	}
+	return errs
+}
+
+// maxCauses bounds the errors unwrapped by causes.
+const maxCauses = 16
+
+// isTLSError returns true if err is an error of crypto/x509 verifying a certificate, an invalid TLS record,
+// or an alert of the peer failing the TLS handshake.
+func isTLSError(err error) bool {
+	switch e := err.(type) {
+	case x509.UnknownAuthorityError, x509.HostnameError, x509.CertificateInvalidError, x509.SystemRootsError,
+		x509.ConstraintViolationError, x509.UnhandledCriticalExtension, x509.InsecureAlgorithmError, tls.RecordHeaderError:
+		return true
+	case *net.OpError:
+		// crypto/tls reports the alerts of the peer, e.g. "tls: bad certificate", with an untyped error.
+		return e.Op == "remote error"
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package failure
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// wrapped is an error of a probe that keeps the error it wraps.
+type wrapped struct {
+	err error
+}
+
+func (e wrapped) Error() string { return "probe: " + e.err.Error() }
+func (e wrapped) Unwrap() error { return e.err }
+
+func opError(op string, err error) error {
+	return &net.OpError{Op: op, Net: "tcp", Err: os.NewSyscallError(op, err)}
+}
+
+// urlError wraps err like http.Client does.
+func urlError(err error) error {
+	return &url.Error{Op: "Get", URL: "http://127.0.0.1/", Err: err}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{"refused", opError("connect", syscall.ECONNREFUSED), ConnectionRefused},
+		{"http refused", urlError(opError("dial", syscall.ECONNREFUSED)), ConnectionRefused},
+		{"reset", opError("read", syscall.ECONNRESET), ConnectionReset},
+		{"broken pipe", opError("write", syscall.EPIPE), ConnectionReset},
+		{"no route", opError("connect", syscall.EHOSTUNREACH), NoRouteToHost},
+		{"network unreachable", opError("connect", syscall.ENETUNREACH), NetworkUnreachable},
+		{"connect timed out", opError("connect", syscall.ETIMEDOUT), Timeout},
+		{"i/o timeout", &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, Timeout},
+		{"deadline", urlError(context.DeadlineExceeded), Timeout},
+		{"nxdomain", urlError(&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "x.invalid", IsNotFound: true}}), NXDomain},
+		{"dns timeout", &net.DNSError{Err: "timeout", Name: "x.test", IsTimeout: true}, Timeout},
+		{"servfail", &net.DNSError{Err: "server misbehaving", Name: "x.test"}, DNSError},
+		{"unknown authority", urlError(wrapped{x509.UnknownAuthorityError{}}), TLSError},
+		{"hostname", urlError(wrapped{x509.HostnameError{Host: "x.test", Certificate: &x509.Certificate{}}}), TLSError},
+		{"not tls", urlError(tls.RecordHeaderError{Msg: "tls: first record does not look like a TLS handshake"}), TLSError},
+		{"eof", io.EOF, ConnectionClosed},
+		{"unexpected eof", wrapped{io.ErrUnexpectedEOF}, ConnectionClosed},
+		// only typed errors are classified, not messages that look alike.
+		{"untyped x509 message", errors.New("certificate verification failed: x509: certificate signed by unknown authority"), NetworkError},
+		{"untyped tls message", urlError(errors.New("proxy said: tls: handshake failure")), NetworkError},
+		{"other", errors.New("something else"), NetworkError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Classify(test.err); got != test.want {
+				t.Errorf("Classify(%v) = %s, want %s", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+// TestClassifyRemoteAlert pins the error crypto/tls returns for an alert of the peer, which has no exported type:
+// a server that requires a client certificate rejects a client without one.
+func TestClassifyRemoteAlert(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	res, err := client.Get(srv.URL)
+	if err == nil {
+		res.Body.Close()
+		t.Fatal("request without client certificate succeeded")
+	}
+	remote := false
+	for _, e := range causes(err) {
+		if opErr, ok := e.(*net.OpError); ok && opErr.Op == "remote error" {
+			remote = true
+		}
+	}
+	if !remote {
+		t.Fatalf("crypto/tls no longer reports alerts as a remote error: %#v", causes(err))
+	}
+	if got := Classify(err); got != TLSError {
+		t.Errorf("Classify(%v) = %s, want %s", err, got, TLSError)
+	}
+}
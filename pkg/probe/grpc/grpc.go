@@ -0,0 +1,126 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"stash.appscode.dev/prober-demo/pkg/grpc/health"
+
+	"github.com/appscode/go/log"
+	"golang.org/x/net/http2"
+	api "kmodules.xyz/prober/api"
+)
+
+// New creates Prober.
+func New() Prober {
+	return grpcProber{}
+}
+
+// Prober is an interface that defines the Probe function for doing gRPC health checks.
+type Prober interface {
+	Probe(host string, port int, service string, config *tls.Config, timeout time.Duration) (api.Result, string, error)
+}
+
+type grpcProber struct{}
+
+// Probe returns a ProbeRunner capable of running a gRPC health check.
+func (pr grpcProber) Probe(host string, port int, service string, config *tls.Config, timeout time.Duration) (api.Result, string, error) {
+	return DoGRPCProbe(net.JoinHostPort(host, strconv.Itoa(port)), service, config, timeout)
+}
+
+// DoGRPCProbe calls grpc.health.v1.Health/Check for service on addr, over TLS if config is not nil
+// and over plaintext HTTP/2 otherwise.
+// If the service is SERVING, it returns Success.
+// If it is NOT_SERVING, or the call fails, it returns Failure.
+// If it is UNKNOWN, it returns Unknown.
+// This is exported because some other packages may want to do direct gRPC probes.
+func DoGRPCProbe(addr string, service string, config *tls.Config, timeout time.Duration) (api.Result, string, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	transport := &http2.Transport{
+		TLSClientConfig: config,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return tls.DialWithDialer(dialer, network, addr, cfg)
+		},
+	}
+	scheme := "https"
+	if config == nil {
+		scheme = "http"
+		transport.AllowHTTP = true
+		transport.DialTLS = func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dialer.Dial(network, addr)
+		}
+	}
+	defer transport.CloseIdleConnections()
+
+	var body bytes.Buffer
+	if err := health.WriteMessage(&body, &health.HealthCheckRequest{Service: service}); err != nil {
+		return api.Unknown, "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodPost, scheme+"://"+addr+health.CheckPath, &body)
+	if err != nil {
+		return api.Failure, err.Error(), nil
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", health.ContentType)
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("User-Agent", "stash.appscode.dev/prober-demo")
+	req.Header.Set(health.HeaderTimeout, strconv.FormatInt(int64(timeout/time.Millisecond), 10)+"m")
+
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		// Convert errors into failures to catch timeouts.
+		return api.Failure, err.Error(), nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return api.Failure, fmt.Sprintf("gRPC probe failed with HTTP statuscode: %d", res.StatusCode), nil
+	}
+
+	var resp health.HealthCheckResponse
+	readErr := health.ReadMessage(res.Body, &resp)
+	// the status is sent in the trailers, which are only available once the body is consumed.
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	code, message := grpcStatus(res)
+	if code != health.OK {
+		return api.Failure, fmt.Sprintf("rpc error: code = %v desc = %s", code, message), nil
+	}
+	if readErr != nil {
+		return api.Failure, readErr.Error(), nil
+	}
+
+	log.Debugf("gRPC probe for %s service %q returned %v", addr, service, resp.Status)
+	switch resp.Status {
+	case health.HealthCheckResponse_SERVING:
+		return api.Success, resp.Status.String(), nil
+	case health.HealthCheckResponse_NOT_SERVING:
+		return api.Failure, fmt.Sprintf("service %q is %v", service, resp.Status), nil
+	default:
+		return api.Unknown, fmt.Sprintf("service %q is %v", service, resp.Status), nil
+	}
+}
+
+// grpcStatus returns the gRPC status of a call, which is sent in the headers of trailers-only responses.
+func grpcStatus(res *http.Response) (health.Code, string) {
+	value, message := res.Trailer.Get(health.HeaderStatus), res.Trailer.Get(health.HeaderMessage)
+	if value == "" {
+		value, message = res.Header.Get(health.HeaderStatus), res.Header.Get(health.HeaderMessage)
+	}
+	if value == "" {
+		return health.Unknown, "missing grpc-status"
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return health.Unknown, fmt.Sprintf("invalid grpc-status %q", value)
+	}
+	return health.Code(code), message
+}
@@ -0,0 +1,111 @@
+package grpc
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"stash.appscode.dev/prober-demo/pkg/grpc/health"
+
+	"golang.org/x/net/http2"
+	api "kmodules.xyz/prober/api"
+)
+
+func newHealthServer() *health.Server {
+	srv := health.NewServer()
+	srv.SetServingStatus("down", health.HealthCheckResponse_NOT_SERVING)
+	srv.SetServingStatus("starting", health.HealthCheckResponse_UNKNOWN)
+	return srv
+}
+
+// listenH2C serves srv over plaintext HTTP/2 like the gRPC server of run-client, until the listener is closed.
+func listenH2C(t *testing.T, srv *health.Server) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		h2 := &http2.Server{}
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go h2.ServeConn(conn, &http2.ServeConnOpts{Handler: srv})
+		}
+	}()
+	return l
+}
+
+func TestDoGRPCProbe(t *testing.T) {
+	plain := listenH2C(t, newHealthServer())
+	defer plain.Close()
+
+	tests := []struct {
+		name    string
+		service string
+		want    api.Result
+	}{
+		{"overall health", "", api.Success},
+		{"not serving", "down", api.Failure},
+		{"unknown status", "starting", api.Unknown},
+		{"unknown service", "missing", api.Failure},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, reason, err := DoGRPCProbe(plain.Addr().String(), test.service, nil, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != test.want {
+				t.Errorf("result = %s, want %s: %s", result, test.want, reason)
+			}
+		})
+	}
+}
+
+func TestDoGRPCProbeTLS(t *testing.T) {
+	srv := httptest.NewUnstartedServer(newHealthServer())
+	srv.TLS = &tls.Config{NextProtos: []string{http2.NextProtoTLS}}
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	if err := http2.ConfigureServer(srv.Config, nil); err != nil {
+		t.Fatal(err)
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "https://")
+
+	result, reason, err := DoGRPCProbe(addr, "down", &tls.Config{InsecureSkipVerify: true}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != api.Failure || !strings.Contains(reason, "NOT_SERVING") {
+		t.Errorf("result = %s, want %s for NOT_SERVING: %s", result, api.Failure, reason)
+	}
+	// gRPC without TLS fails against a TLS port.
+	if result, reason, _ := DoGRPCProbe(addr, "", nil, time.Second); result != api.Failure {
+		t.Errorf("plaintext result = %s, want %s: %s", result, api.Failure, reason)
+	}
+}
+
+func TestDoGRPCProbeClosedPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	result, reason, err := DoGRPCProbe(addr, "", nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != api.Failure {
+		t.Errorf("result = %s, want %s: %s", result, api.Failure, reason)
+	}
+}
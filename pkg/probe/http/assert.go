@@ -0,0 +1,133 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+
+	api "kmodules.xyz/prober/api"
+)
+
+// Assertions are HTTPAssertions in a form that can be checked against responses.
+type Assertions struct {
+	spec      api_v1.HTTPAssertions
+	bodyRegex *regexp.Regexp
+}
+
+// ParseAssertions validates assertions and returns them in a form that can be checked against responses.
+// Nil assertions parse to nil, which checks nothing.
+func ParseAssertions(assertions *api_v1.HTTPAssertions) (*Assertions, error) {
+	if assertions == nil {
+		return nil, nil
+	}
+	a := &Assertions{spec: *assertions}
+	if assertions.BodyRegex != "" {
+		re, err := regexp.Compile(assertions.BodyRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body regex %q: %v", assertions.BodyRegex, err)
+		}
+		a.bodyRegex = re
+	}
+	return a, nil
+}
+
+// Check applies the assertions to res, the response behind the result and reason of an HTTP probe.
+// If an assertion fails, it returns Failure with a reason showing the expected and the actual values.
+// The status codes matched by rules keep their result, StatusCodes decides alone for the others.
+func (a *Assertions) Check(res *Response, rules StatusResults, result api.Result, reason string) (api.Result, string) {
+	if a == nil || res == nil {
+		// nothing to check, or no response was received at all.
+		return result, reason
+	}
+	assertions := &a.spec
+
+	if len(assertions.StatusCodes) > 0 && !rules.Matches(res.StatusCode) {
+		if !containsCode(assertions.StatusCodes, res.StatusCode) {
+			return api.Failure, fmt.Sprintf("expected status code in %v, got %d", assertions.StatusCodes, res.StatusCode)
+		}
+		// an accepted status code is a success, a redirect too.
+		result, reason = api.Success, res.BodyString()
+	}
+	if result == api.Failure {
+		return result, reason
+	}
+
+	body, shown := string(res.Body), res.BodyString()
+	if assertions.BodyEquals != nil && body != *assertions.BodyEquals {
+		return api.Failure, fmt.Sprintf("expected body %q, got %q", truncate(*assertions.BodyEquals), shown)
+	}
+	if assertions.BodyContains != "" && !strings.Contains(body, assertions.BodyContains) {
+		return api.Failure, fmt.Sprintf("expected body to contain %q, got %q", truncate(assertions.BodyContains), shown)
+	}
+	if a.bodyRegex != nil && !a.bodyRegex.MatchString(body) {
+		return api.Failure, fmt.Sprintf("expected body to match %q, got %q", truncate(assertions.BodyRegex), shown)
+	}
+	if len(assertions.JSONPath) > 0 {
+		var data interface{}
+		decoder := json.NewDecoder(bytes.NewReader(res.Body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&data); err != nil {
+			return api.Failure, fmt.Sprintf("expected a JSON body, got %q: %v", shown, err)
+		}
+		for _, a := range assertions.JSONPath {
+			v, err := evalJSONPath(data, a.Path)
+			if err != nil {
+				return api.Failure, fmt.Sprintf("expected %s to be %q, got error: %v", a.Path, truncate(a.Value), err)
+			}
+			if actual := jsonValueString(v); actual != a.Value {
+				return api.Failure, fmt.Sprintf("expected %s to be %q, got %q", a.Path, truncate(a.Value), truncate(actual))
+			}
+		}
+	}
+	for _, h := range assertions.Headers {
+		values, ok := res.Header[http.CanonicalHeaderKey(h.Name)]
+		if !ok {
+			return api.Failure, fmt.Sprintf("expected header %s, got headers %v", h.Name, headerNames(res.Header))
+		}
+		if h.Value != "" && !containsString(values, h.Value) {
+			return api.Failure, fmt.Sprintf("expected header %s to be %q, got %q", h.Name, truncate(h.Value), values)
+		}
+	}
+	return result, reason
+}
+
+// truncate shortens s to the length of the body read by a probe, so expected and actual values are shown alike.
+func truncate(s string) string {
+	if len(s) > maxRespBodyLength {
+		return s[:maxRespBodyLength] + TruncatedMark
+	}
+	return s
+}
+
+func containsCode(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func headerNames(header http.Header) []string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,190 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	api "kmodules.xyz/prober/api"
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+)
+
+func TestParseAssertionsInvalidRegex(t *testing.T) {
+	a, err := ParseAssertions(&api_v1.HTTPAssertions{BodyRegex: `"status":\s*(`})
+	if err == nil {
+		t.Fatalf("ParseAssertions() = %v, want an error", a)
+	}
+}
+
+// statusServer replies with the status code of the path, e.g. 503 to /503, and without Location to redirects.
+func statusServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		w.WriteHeader(code)
+		w.Write([]byte("ok"))
+	}))
+}
+
+// probeStatus probes the path of srv for code like runHTTP does, with rules and assertions applied.
+func probeStatus(t *testing.T, srv *httptest.Server, code int, rules StatusResults, assertions *Assertions) (api.Result, string) {
+	u, err := url.Parse(srv.URL + "/" + strconv.Itoa(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := NewRecorder(srv.Client())
+	result, reason, err := DoHTTPProbe(http.MethodGet, u, http.Header{}, rec, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, reason = rules.Apply(rec.Response(), result, reason)
+	return assertions.Check(rec.Response(), rules, result, reason)
+}
+
+// TestStatusCodesPrecedence checks that the codes matched by HTTPStatusResults keep their result,
+// and that StatusCodes decides alone for the others, whatever their default result.
+func TestStatusCodesPrecedence(t *testing.T) {
+	srv := statusServer()
+	defer srv.Close()
+	rules, err := ParseStatusResults([]api_v1.HTTPStatusResult{
+		{Codes: []string{"503"}, Result: api.Warning},
+		{Codes: []string{"429"}, Result: api.Failure},
+		{Codes: []string{"500-502"}, Result: api.Success},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertions, err := ParseAssertions(&api_v1.HTTPAssertions{StatusCodes: []int{200, 302, 404, 429, 503}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		code int
+		want api.Result
+	}{
+		{"listed success", 200, api.Success},
+		{"listed redirect", 302, api.Success},
+		{"listed failure", 404, api.Success},
+		{"not listed success", 204, api.Failure},
+		{"not listed redirect", 308, api.Failure},
+		{"not listed failure", 403, api.Failure},
+		{"status result warning", 503, api.Warning},
+		{"status result failure", 429, api.Failure},
+		{"status result success", 501, api.Success},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got, reason := probeStatus(t, srv, test.code, rules, assertions); got != test.want {
+				t.Errorf("result of %d = %s, want %s: %s", test.code, got, test.want, reason)
+			}
+		})
+	}
+}
+
+// TestStatusCodesDefault checks that the default results stand without StatusCodes.
+func TestStatusCodesDefault(t *testing.T) {
+	srv := statusServer()
+	defer srv.Close()
+	assertions, err := ParseAssertions(&api_v1.HTTPAssertions{BodyEquals: stringPtr("ok")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		code int
+		want api.Result
+	}{
+		{200, api.Success},
+		{302, api.Warning},
+		{404, api.Failure},
+	}
+	for _, test := range tests {
+		if got, reason := probeStatus(t, srv, test.code, nil, assertions); got != test.want {
+			t.Errorf("result of %d = %s, want %s: %s", test.code, got, test.want, reason)
+		}
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestCheckAssertions(t *testing.T) {
+	res := &Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}, "X-Version": {"1", "2"}},
+		Body:       []byte(`{"status":"ok","items":[{"name":"a","count":2}],"ready":true}`),
+	}
+	tests := []struct {
+		name       string
+		assertions api_v1.HTTPAssertions
+		want       api.Result
+	}{
+		{"none", api_v1.HTTPAssertions{}, api.Success},
+		{"body equals", api_v1.HTTPAssertions{BodyEquals: stringPtr(string(res.Body))}, api.Success},
+		{"body differs", api_v1.HTTPAssertions{BodyEquals: stringPtr("ok")}, api.Failure},
+		{"body contains", api_v1.HTTPAssertions{BodyContains: `"ready":true`}, api.Success},
+		{"body does not contain", api_v1.HTTPAssertions{BodyContains: "error"}, api.Failure},
+		{"body matches", api_v1.HTTPAssertions{BodyRegex: `"status":\s*"\w+"`}, api.Success},
+		{"body does not match", api_v1.HTTPAssertions{BodyRegex: `^ok$`}, api.Failure},
+		{"json string", api_v1.HTTPAssertions{JSONPath: []api_v1.JSONPathAssertion{{Path: "$.status", Value: "ok"}}}, api.Success},
+		{"json index", api_v1.HTTPAssertions{JSONPath: []api_v1.JSONPathAssertion{{Path: "$.items[0].name", Value: "a"}}}, api.Success},
+		{"json bracket", api_v1.HTTPAssertions{JSONPath: []api_v1.JSONPathAssertion{{Path: "$['items'][0]['count']", Value: "2"}}}, api.Success},
+		{"json kubectl", api_v1.HTTPAssertions{JSONPath: []api_v1.JSONPathAssertion{{Path: "{.ready}", Value: "true"}}}, api.Success},
+		{"json object", api_v1.HTTPAssertions{JSONPath: []api_v1.JSONPathAssertion{{Path: "$.items[0]", Value: `{"count":2,"name":"a"}`}}}, api.Success},
+		{"json differs", api_v1.HTTPAssertions{JSONPath: []api_v1.JSONPathAssertion{{Path: "$.status", Value: "down"}}}, api.Failure},
+		{"json missing key", api_v1.HTTPAssertions{JSONPath: []api_v1.JSONPathAssertion{{Path: "$.items[1].name", Value: "b"}}}, api.Failure},
+		{"json invalid path", api_v1.HTTPAssertions{JSONPath: []api_v1.JSONPathAssertion{{Path: "$.items[x]", Value: "a"}}}, api.Failure},
+		{"header present", api_v1.HTTPAssertions{Headers: []api_v1.HeaderAssertion{{Name: "x-version"}}}, api.Success},
+		{"header value", api_v1.HTTPAssertions{Headers: []api_v1.HeaderAssertion{{Name: "X-Version", Value: "2"}}}, api.Success},
+		{"header missing", api_v1.HTTPAssertions{Headers: []api_v1.HeaderAssertion{{Name: "Allow"}}}, api.Failure},
+		{"header differs", api_v1.HTTPAssertions{Headers: []api_v1.HeaderAssertion{{Name: "Content-Type", Value: "text/plain"}}}, api.Failure},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := ParseAssertions(&test.assertions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, reason := a.Check(res, nil, api.Success, "ok"); got != test.want {
+				t.Errorf("result = %s, want %s: %s", got, test.want, reason)
+			}
+		})
+	}
+}
+
+func TestCheckAssertionsKeepsFailure(t *testing.T) {
+	res := &Response{StatusCode: http.StatusServiceUnavailable, Body: []byte("ok")}
+	a, err := ParseAssertions(&api_v1.HTTPAssertions{BodyContains: "ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, reason := a.Check(res, nil, api.Failure, "HTTP probe failed with statuscode: 503"); got != api.Failure {
+		t.Errorf("result = %s, want %s: %s", got, api.Failure, reason)
+	}
+	// without a response, e.g. on a connection error, nothing is checked.
+	if got, reason := a.Check(nil, nil, api.Failure, "connection refused"); got != api.Failure || reason != "connection refused" {
+		t.Errorf("result = %s, %q, want the failure unchanged", got, reason)
+	}
+	var none *Assertions
+	if got, _ := none.Check(res, nil, api.Success, "ok"); got != api.Success {
+		t.Errorf("result of no assertions = %s, want %s", got, api.Success)
+	}
+}
+
+// TestCheckAssertionsTruncatedBody checks that a body cut off by the probe is shown with TruncatedMark.
+func TestCheckAssertionsTruncatedBody(t *testing.T) {
+	res := &Response{StatusCode: http.StatusOK, Body: []byte(strings.Repeat("x", maxRespBodyLength))}
+	a, err := ParseAssertions(&api_v1.HTTPAssertions{BodyContains: "never"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, reason := a.Check(res, nil, api.Success, "ok")
+	if !strings.HasSuffix(reason, "x"+TruncatedMark+`"`) {
+		t.Errorf("reason does not end with %s: %s", TruncatedMark, reason[len(reason)-20:])
+	}
+}
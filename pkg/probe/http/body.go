@@ -0,0 +1,84 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	prober_http "kmodules.xyz/prober/probe/http"
+)
+
+const (
+	ContentMultipartForm = "multipart/form-data"
+	ContentXML           = "application/xml"
+	ContentProtobuf      = "application/x-protobuf"
+)
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// MultipartBody encodes parts as a multipart body and returns it with its Content-Type.
+// mediaType defaults to multipart/form-data, the boundary of the body is added to it.
+func MultipartBody(parts []api_v1.MultipartPart, mediaType string) (string, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, part := range parts {
+		h := make(textproto.MIMEHeader)
+		value := []byte(part.Value)
+		contentType := part.ContentType
+		if part.File != "" {
+			data, err := ioutil.ReadFile(part.File)
+			if err != nil {
+				return "", "", fmt.Errorf("failed to read multipart file: %v", err)
+			}
+			value = data
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+				quoteEscaper.Replace(part.Name), quoteEscaper.Replace(filepath.Base(part.File))))
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+		} else {
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(part.Name)))
+		}
+		if contentType != "" {
+			h.Set(prober_http.ContentType, contentType)
+		}
+		pw, err := w.CreatePart(h)
+		if err != nil {
+			return "", "", err
+		}
+		if _, err = pw.Write(value); err != nil {
+			return "", "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", "", err
+	}
+
+	if mediaType == "" {
+		mediaType = ContentMultipartForm
+	}
+	return buf.String(), mime.FormatMediaType(mediaType, map[string]string{"boundary": w.Boundary()}), nil
+}
+
+// ProtobufBody encodes a JSON object as a binary google.protobuf.Struct message.
+func ProtobufBody(object string) (string, error) {
+	var s structpb.Struct
+	if err := jsonpb.UnmarshalString(object, &s); err != nil {
+		return "", fmt.Errorf("invalid protobuf body: %v", err)
+	}
+	data, err := proto.Marshal(&s)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
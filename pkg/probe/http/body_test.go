@@ -0,0 +1,124 @@
+package http
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+
+	"github.com/golang/protobuf/proto"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+func TestMultipartBody(t *testing.T) {
+	f, err := ioutil.TempFile("", "multipart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("file content")
+	f.Close()
+
+	body, contentType, err := MultipartBody([]api_v1.MultipartPart{
+		{Name: "expectedCode", Value: "201"},
+		{Name: `quoted "name"`, Value: "{}", ContentType: "application/json"},
+		{Name: "expectedResponse", File: f.Name()},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != ContentMultipartForm || params["boundary"] == "" {
+		t.Fatalf("Content-Type = %q, want %s with a boundary", contentType, ContentMultipartForm)
+	}
+
+	tests := []struct {
+		name        string
+		fileName    string
+		contentType string
+		value       string
+	}{
+		{"expectedCode", "", "", "201"},
+		{`quoted "name"`, "", "application/json", "{}"},
+		{"expectedResponse", filepath.Base(f.Name()), "application/octet-stream", "file content"},
+	}
+	r := multipart.NewReader(strings.NewReader(body), params["boundary"])
+	for _, test := range tests {
+		part, err := r.NextPart()
+		if err != nil {
+			t.Fatalf("part %s: %v", test.name, err)
+		}
+		value, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part.FormName() != test.name || part.FileName() != test.fileName ||
+			part.Header.Get("Content-Type") != test.contentType || string(value) != test.value {
+			t.Errorf("part = %q %q %q %q, want %q %q %q %q",
+				part.FormName(), part.FileName(), part.Header.Get("Content-Type"), value,
+				test.name, test.fileName, test.contentType, test.value)
+		}
+	}
+	if _, err := r.NextPart(); err == nil {
+		t.Error("body has more parts than sent")
+	}
+}
+
+func TestMultipartBodyMediaType(t *testing.T) {
+	_, contentType, err := MultipartBody([]api_v1.MultipartPart{{Name: "a", Value: "b"}}, "multipart/mixed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(contentType, "multipart/mixed; boundary=") {
+		t.Errorf("Content-Type = %q, want multipart/mixed with a boundary", contentType)
+	}
+}
+
+func TestMultipartBodyMissingFile(t *testing.T) {
+	_, _, err := MultipartBody([]api_v1.MultipartPart{{Name: "a", File: "/nonexistent/file"}}, "")
+	if err == nil {
+		t.Error("MultipartBody() of a missing file succeeded")
+	}
+}
+
+func TestProtobufBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		object  string
+		wantErr bool
+	}{
+		{"object", `{"expectedCode": "200", "nested": {"list": [1, true, null]}}`, false},
+		{"empty object", `{}`, false},
+		{"not an object", `["200"]`, true},
+		{"invalid json", `{"expectedCode":`, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := ProtobufBody(test.object)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("ProtobufBody() = %q, want an error", body)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var msg structpb.Struct
+			if err := proto.Unmarshal([]byte(body), &msg); err != nil {
+				t.Fatalf("body is not a Struct message: %v", err)
+			}
+			if code := msg.Fields["expectedCode"].GetStringValue(); strings.Contains(test.object, "expectedCode") && code != "200" {
+				t.Errorf("expectedCode = %q, want 200", code)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package http
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+const (
+	maxRespBodyLength = 10 * 1 << 10 // 10KB
+
+	// DefaultMaxRedirects is the number of redirects followed by the clients of kmodules.xyz/prober/probe/http.
+	DefaultMaxRedirects = 10
+)
+
+// NewClient returns an http.Client set up like the ones of kmodules.xyz/prober/probe/http,
+// so it can be passed to DoHTTPGetProbe and DoHTTPPostProbe.
+// followNonLocalRedirects configures whether the client should follow redirects to a different hostname.
+// If disabled, redirects to other hosts will trigger a warning result.
+// maxRedirects is the number of redirects followed before the request fails, zero follows none.
+func NewClient(config *tls.Config, followNonLocalRedirects bool, maxRedirects int, timeout time.Duration) *http.Client {
+	// We do not want the probe use node's local proxy set.
+	transport := utilnet.SetTransportDefaults(
+		&http.Transport{
+			TLSClientConfig:   config,
+			DisableKeepAlives: true,
+			Proxy:             http.ProxyURL(nil),
+		})
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     transport,
+		CheckRedirect: redirectChecker(followNonLocalRedirects, maxRedirects),
+	}
+}
+
+func redirectChecker(followNonLocalRedirects bool, maxRedirects int) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if !followNonLocalRedirects && req.URL.Hostname() != via[0].URL.Hostname() {
+			return http.ErrUseLastResponse
+		}
+		if maxRedirects == 0 {
+			// the redirect itself is the response.
+			return http.ErrUseLastResponse
+		}
+		if len(via) >= maxRedirects {
+			return &TooManyRedirectsError{MaxRedirects: maxRedirects}
+		}
+		return nil
+	}
+}
+
+// TooManyRedirectsError is the error of a request stopped after following MaxRedirects redirects.
+type TooManyRedirectsError struct {
+	MaxRedirects int
+}
+
+func (e *TooManyRedirectsError) Error() string {
+	return fmt.Sprintf("stopped after %d redirects", e.MaxRedirects)
+}
+
+// Response is the part of an HTTP response seen by a probe.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	// Body holds at most the first 10KB of the response body, like the probe reads.
+	Body []byte
+}
+
+// BodyString returns the body as shown in the reasons of probes, ending with TruncatedMark if it may have been cut off.
+func (r *Response) BodyString() string {
+	return shownBody(r.Body)
+}
+
+// TruncatedMark ends the values that are cut off in the reasons of probes.
+const TruncatedMark = "..."
+
+func shownBody(body []byte) string {
+	if len(body) >= maxRespBodyLength {
+		return string(body) + TruncatedMark
+	}
+	return string(body)
+}
+
+// Recorder is a prober_http.HTTPInterface that keeps the response of the request it performs,
+// or the error it failed with, the redirects it followed and the timing of its phases.
+type Recorder struct {
+	Client    *http.Client
+	response  *Response
+	err       error
+	redirects []string
+	tracer    *tracer
+}
+
+// NewRecorder returns a Recorder sending requests with a copy of client that records redirects.
+func NewRecorder(client *http.Client) *Recorder {
+	r := &Recorder{}
+	c := *client
+	check := client.CheckRedirect
+	if check == nil {
+		check = redirectChecker(true, DefaultMaxRedirects)
+	}
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		err := check(req, via)
+		if err == nil {
+			if len(r.redirects) == 0 {
+				r.redirects = append(r.redirects, via[0].URL.String())
+			}
+			r.redirects = append(r.redirects, req.URL.String())
+		}
+		return err
+	}
+	r.Client = &c
+	return r
+}
+
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	r.tracer = newTracer()
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), r.tracer.clientTrace()))
+	res, err := r.Client.Do(req)
+	if err != nil {
+		r.tracer.done()
+		r.err = err
+		return res, err
+	}
+	r.response = &Response{
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+	}
+	res.Body = &recordingBody{ReadCloser: res.Body, response: r.response, tracer: r.tracer}
+	return res, nil
+}
+
+// Response returns the recorded response, or nil if no response was received.
+func (r *Recorder) Response() *Response {
+	return r.response
+}
+
+// Err returns the error the request failed with, or nil if a response was received.
+func (r *Recorder) Err() error {
+	return r.err
+}
+
+// Timing returns the latency breakdown of the request, or nil if no request was sent.
+// Its total time ends when the response body is closed.
+func (r *Recorder) Timing() *Timing {
+	if r.tracer == nil {
+		return nil
+	}
+	return r.tracer.done()
+}
+
+// Redirects returns the URLs of the followed redirect chain, starting with the requested one.
+// It is empty if no redirect was followed.
+func (r *Recorder) Redirects() []string {
+	return r.redirects
+}
+
+// recordingBody copies up to maxRespBodyLength bytes read from the body into the response,
+// and ends the timing of the request when it is closed.
+type recordingBody struct {
+	io.ReadCloser
+	response *Response
+	tracer   *tracer
+	buf      bytes.Buffer
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if remaining := maxRespBodyLength - b.buf.Len(); remaining > 0 {
+		if n < remaining {
+			remaining = n
+		}
+		b.buf.Write(p[:remaining])
+		b.response.Body = b.buf.Bytes()
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.tracer.done()
+	return b.ReadCloser.Close()
+}
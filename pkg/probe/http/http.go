@@ -0,0 +1,102 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/appscode/go/log"
+	"github.com/gabriel-vasile/mimetype"
+	utilio "k8s.io/utils/io"
+	api "kmodules.xyz/prober/api"
+	prober_http "kmodules.xyz/prober/probe/http"
+)
+
+// DoHTTPProbe checks if a request with the given method to the url succeeds.
+// The request body is built from form or body like prober_http.DoHTTPPostProbe does,
+// except that a Content-Type set in headers is kept instead of being guessed.
+// If the HTTP response code is successful (i.e. 400 > code >= 200), it returns Success.
+// If the HTTP response code is unsuccessful or HTTP communication fails, it returns Failure.
+func DoHTTPProbe(method string, addr *url.URL, headers http.Header, client prober_http.HTTPInterface, form *url.Values, body string) (api.Result, string, error) {
+	var req *http.Request
+	var err error
+
+	if headers == nil {
+		headers = http.Header{}
+	}
+
+	if form != nil {
+		req, err = http.NewRequest(method, addr.String(), strings.NewReader(form.Encode()))
+		if err != nil {
+			// Convert errors into failures to catch timeouts.
+			return api.Failure, err.Error(), nil
+		}
+		setDefaultContentType(headers, prober_http.ContentUrlEncodedForm)
+	} else if len(body) > 0 {
+		req, err = http.NewRequest(method, addr.String(), strings.NewReader(body))
+		if err != nil {
+			// Convert errors into failures to catch timeouts.
+			return api.Failure, err.Error(), nil
+		}
+		if headers.Get(prober_http.ContentType) == "" {
+			mime, _ := mimetype.Detect([]byte(body))
+			headers.Set(prober_http.ContentType, mime)
+		}
+	} else {
+		req, err = http.NewRequest(method, addr.String(), nil)
+		if err != nil {
+			// Convert errors into failures to catch timeouts.
+			return api.Failure, err.Error(), nil
+		}
+	}
+
+	return doHTTPProbe(req, addr, headers, client)
+}
+
+func setDefaultContentType(headers http.Header, contentType string) {
+	if headers.Get(prober_http.ContentType) == "" {
+		headers.Set(prober_http.ContentType, contentType)
+	}
+}
+
+// doHTTPProbe is the response handling of kmodules.xyz/prober/probe/http, shared by every method.
+func doHTTPProbe(req *http.Request, url *url.URL, headers http.Header, client prober_http.HTTPInterface) (api.Result, string, error) {
+	if _, ok := headers["User-Agent"]; !ok {
+		if headers == nil {
+			headers = http.Header{}
+		}
+		// explicitly set User-Agent so it's not set to default Go value
+		headers.Set("User-Agent", "kmodules.xyz/client-go/release-11.0")
+	}
+	req.Header = headers
+	if headers.Get("Host") != "" {
+		req.Host = headers.Get("Host")
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		// Convert errors into failures to catch timeouts.
+		return api.Failure, err.Error(), nil
+	}
+	defer res.Body.Close()
+	b, err := utilio.ReadAtMost(res.Body, maxRespBodyLength)
+	if err != nil {
+		if err == utilio.ErrLimitReached {
+			log.Debugf("Non fatal body truncation for %s, Response: %v", url.String(), *res)
+		} else {
+			return api.Failure, "", err
+		}
+	}
+	respBody := shownBody(b)
+	if res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusBadRequest {
+		if res.StatusCode >= http.StatusMultipleChoices { // Redirect
+			log.Debugf("Probe terminated redirects for %s, Response: %v", url.String(), *res)
+			return api.Warning, respBody, nil
+		}
+		log.Debugf("Probe succeeded for %s, Response: %v", url.String(), *res)
+		return api.Success, respBody, nil
+	}
+	// request headers are not logged, they may hold credentials.
+	log.Debugf("Probe failed for %s, response body: %v", url.String(), respBody)
+	return api.Failure, fmt.Sprintf("HTTP probe failed with statuscode: %d", res.StatusCode), nil
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// evalJSONPath evaluates a simple JSONPath expression like "$.items[0].name", "{.items[0].name}"
+// or "$['items'][0]['name']" against a value decoded by encoding/json.
+// Only child and index selectors are supported.
+func evalJSONPath(data interface{}, path string) (interface{}, error) {
+	expr := strings.TrimSpace(path)
+	if strings.HasPrefix(expr, "{") && strings.HasSuffix(expr, "}") {
+		expr = expr[1 : len(expr)-1]
+	}
+	expr = strings.TrimPrefix(expr, "$")
+
+	cur := data
+	for len(expr) > 0 {
+		var key string
+		index := -1
+		switch {
+		case expr[0] == '.':
+			end := strings.IndexAny(expr[1:], ".[")
+			if end < 0 {
+				end = len(expr) - 1
+			}
+			key, expr = expr[1:end+1], expr[end+1:]
+		case strings.HasPrefix(expr, "['") || strings.HasPrefix(expr, `["`):
+			end := strings.Index(expr[2:], string(expr[1])+"]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unterminated name selector", path)
+			}
+			key, expr = expr[2:end+2], expr[end+4:]
+		case expr[0] == '[':
+			end := strings.IndexByte(expr, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unterminated index selector", path)
+			}
+			i, err := strconv.Atoi(expr[1:end])
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unsupported index %q", path, expr[1:end])
+			}
+			index, expr = i, expr[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", path, expr)
+		}
+
+		if index >= 0 {
+			list, ok := cur.([]interface{})
+			if !ok || index >= len(list) {
+				return nil, fmt.Errorf("index %d not found", index)
+			}
+			cur = list[index]
+		} else {
+			obj, ok := cur.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("key %q not found", key)
+			}
+			if cur, ok = obj[key]; !ok {
+				return nil, fmt.Errorf("key %q not found", key)
+			}
+		}
+	}
+	return cur, nil
+}
+
+// jsonValueString formats a JSONPath result for comparison: strings as is, anything else as compact JSON.
+func jsonValueString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// Capture returns the first value of the header of res if header is set,
+// otherwise the value of the JSONPath expression evaluated against its JSON body.
+func Capture(res *Response, header string, jsonPath string) (string, error) {
+	if header != "" {
+		values, ok := res.Header[http.CanonicalHeaderKey(header)]
+		if !ok || len(values) == 0 {
+			return "", fmt.Errorf("header %s not found", header)
+		}
+		return values[0], nil
+	}
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(res.Body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return "", fmt.Errorf("expected a JSON body: %v", err)
+	}
+	v, err := evalJSONPath(data, jsonPath)
+	if err != nil {
+		return "", err
+	}
+	return jsonValueString(v), nil
+}
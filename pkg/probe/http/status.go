@@ -0,0 +1,118 @@
+package http
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+
+	api "kmodules.xyz/prober/api"
+)
+
+// StatusResults maps status codes to probe results, see api_v1.Handler.HTTPStatusResults.
+type StatusResults []statusResult
+
+type statusResult struct {
+	ranges CodeRanges
+	result api.Result
+}
+
+// CodeRanges are status codes and inclusive ranges of them, e.g. "503" or "500-599".
+type CodeRanges []codeRange
+
+type codeRange struct {
+	from, to int
+}
+
+// ParseCodeRanges validates codes and returns them in a form that can be matched against status codes.
+func ParseCodeRanges(codes []string) (CodeRanges, error) {
+	out := make(CodeRanges, 0, len(codes))
+	for _, c := range codes {
+		r, err := parseCodeRange(c)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// Contains returns true if code is one of the codes or within one of the ranges.
+func (c CodeRanges) Contains(code int) bool {
+	for _, r := range c {
+		if code >= r.from && code <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseStatusResults validates rules and returns them in a form that can be applied to responses.
+func ParseStatusResults(rules []api_v1.HTTPStatusResult) (StatusResults, error) {
+	out := make(StatusResults, 0, len(rules))
+	for _, rule := range rules {
+		switch rule.Result {
+		case api.Success, api.Warning, api.Failure:
+		default:
+			return nil, fmt.Errorf("invalid status code result %q, must be one of %s, %s or %s", rule.Result, api.Success, api.Warning, api.Failure)
+		}
+		ranges, err := ParseCodeRanges(rule.Codes)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, statusResult{ranges: ranges, result: rule.Result})
+	}
+	return out, nil
+}
+
+func parseCodeRange(s string) (codeRange, error) {
+	from, to := s, s
+	if i := strings.Index(s, "-"); i >= 0 {
+		from, to = s[:i], s[i+1:]
+	}
+	var r codeRange
+	var err error
+	if r.from, err = strconv.Atoi(strings.TrimSpace(from)); err != nil {
+		return r, fmt.Errorf("invalid status codes %q", s)
+	}
+	if r.to, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+		return r, fmt.Errorf("invalid status codes %q", s)
+	}
+	if r.from < 100 || r.to > 599 || r.from > r.to {
+		return r, fmt.Errorf("invalid status codes %q, must be within 100-599", s)
+	}
+	return r, nil
+}
+
+// Matches returns true if a rule applies to code.
+func (rules StatusResults) Matches(code int) bool {
+	for _, rule := range rules {
+		if rule.ranges.Contains(code) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns the result mapped to the status code of res, with a reason like the one of the
+// default classification, or result and reason unchanged if no rule matches.
+func (rules StatusResults) Apply(res *Response, result api.Result, reason string) (api.Result, string) {
+	if res == nil {
+		return result, reason
+	}
+	for _, rule := range rules {
+		if !rule.ranges.Contains(res.StatusCode) {
+			continue
+		}
+		switch rule.result {
+		case api.Failure:
+			return api.Failure, fmt.Sprintf("HTTP probe failed with statuscode: %d", res.StatusCode)
+		case api.Warning:
+			return api.Warning, fmt.Sprintf("HTTP probe returned statuscode: %d", res.StatusCode)
+		default:
+			return rule.result, res.BodyString()
+		}
+	}
+	return result, reason
+}
@@ -0,0 +1,111 @@
+package http
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing is the latency breakdown of an HTTP probe, measured with net/http/httptrace.
+// The phases of the requests of a redirect chain add up.
+type Timing struct {
+	// DNSLookup is the time spent resolving the host. It is zero for IP addresses.
+	DNSLookup time.Duration
+	// Connect is the time spent establishing TCP connections, or failing to.
+	Connect time.Duration
+	// TLSHandshake is the time spent in TLS handshakes.
+	TLSHandshake time.Duration
+	// FirstByte is the time from the start of the probe to the first byte of the last response.
+	FirstByte time.Duration
+	// Total is the time from the start of the probe to the end of the response body, or to the error.
+	Total time.Duration
+	// RemoteAddr is the address the last request was sent to.
+	RemoteAddr string
+	// Reused tells that the connection of the last request was reused from an earlier request.
+	Reused bool
+}
+
+// tracer fills a Timing from the events of a httptrace.ClientTrace, which may come from several goroutines.
+type tracer struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	timing       Timing
+}
+
+func newTracer() *tracer {
+	return &tracer{start: time.Now()}
+}
+
+func (t *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if !t.dnsStart.IsZero() {
+				t.timing.DNSLookup += time.Since(t.dnsStart)
+				t.dnsStart = time.Time{}
+			}
+		},
+		ConnectStart: func(network, addr string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// dials to several addresses of a host may race, the first start counts.
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// failed dials count too, e.g. the time it took to be refused.
+			if !t.connectStart.IsZero() {
+				t.timing.Connect += time.Since(t.connectStart)
+				t.connectStart = time.Time{}
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if !t.tlsStart.IsZero() {
+				t.timing.TLSHandshake += time.Since(t.tlsStart)
+				t.tlsStart = time.Time{}
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.RemoteAddr = info.Conn.RemoteAddr().String()
+			t.timing.Reused = info.Reused
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.FirstByte = time.Since(t.start)
+		},
+	}
+}
+
+// done records the end of the probe and returns the timing.
+func (t *tracer) done() *Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timing.Total == 0 {
+		t.timing.Total = time.Since(t.start)
+	}
+	timing := t.timing
+	return &timing
+}
@@ -0,0 +1,107 @@
+package mongodb
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"stash.appscode.dev/prober-demo/pkg/mongo"
+
+	api "kmodules.xyz/prober/api"
+
+	"github.com/appscode/go/log"
+)
+
+// New creates Prober.
+func New() Prober {
+	return mongodbProber{}
+}
+
+// Prober is an interface that defines the Probe function for doing MongoDB checks.
+type Prober interface {
+	Probe(host string, port int, primaryOnly bool, timeout time.Duration) (api.Result, string, error)
+}
+
+type mongodbProber struct{}
+
+// Probe returns a ProbeRunner capable of running a MongoDB check.
+func (pr mongodbProber) Probe(host string, port int, primaryOnly bool, timeout time.Duration) (api.Result, string, error) {
+	return DoMongoDBProbe(net.JoinHostPort(host, strconv.Itoa(port)), primaryOnly, timeout)
+}
+
+// DoMongoDBProbe connects to addr and runs hello, or isMaster if the server is too old for it.
+// If the command fails before timeout, or primaryOnly is set and the server does not accept writes,
+// it returns Failure. Otherwise, it returns Success. The reason shows the replication state of the server.
+func DoMongoDBProbe(addr string, primaryOnly bool, timeout time.Duration) (api.Result, string, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		// Convert errors to failures to handle timeouts.
+		return api.Failure, describe("connect", err), nil
+	}
+	c := mongo.NewConn(conn)
+	defer func() {
+		if err := c.Close(); err != nil {
+			log.Errorf("Unexpected error closing MongoDB probe connection: %v (%#v)", err, err)
+		}
+	}()
+	if err = conn.SetDeadline(start.Add(timeout)); err != nil {
+		return api.Failure, err.Error(), nil
+	}
+
+	reply, command, err := c.Hello()
+	if err != nil {
+		return api.Failure, describe(command, err), nil
+	}
+	writable := reply.Bool("isWritablePrimary") || reply.Bool("ismaster")
+	state := replicationState(reply, writable)
+	if primaryOnly && !writable {
+		if primary := reply.String("primary"); primary != "" {
+			return api.Failure, fmt.Sprintf("not primary: %s, primary is %s", state, primary), nil
+		}
+		return api.Failure, "not primary: " + state, nil
+	}
+	return api.Success, fmt.Sprintf("%s (maxWireVersion %v, via %s, in %v)", state, reply.Number("maxWireVersion"), command, since(start)), nil
+}
+
+// replicationState describes the server from the reply of hello, e.g. "secondary of replica set rs0".
+func replicationState(reply mongo.Document, writable bool) string {
+	if reply.String("msg") == "isdbgrid" {
+		return "mongos router"
+	}
+	setName := reply.String("setName")
+	if setName == "" {
+		if writable {
+			return "standalone"
+		}
+		return "standalone, not writable"
+	}
+	var state string
+	switch {
+	case writable:
+		state = "primary"
+	case reply.Bool("secondary"):
+		state = "secondary"
+	case reply.Bool("arbiterOnly"):
+		state = "arbiter"
+	default:
+		state = "member in recovery"
+	}
+	return fmt.Sprintf("%s of replica set %s", state, setName)
+}
+
+// describe returns the reason of an error, prefixed with the phase it timed out in.
+func describe(phase string, err error) string {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return fmt.Sprintf("timeout during %s: %v", phase, err)
+	}
+	if _, ok := err.(*mongo.CommandError); ok {
+		return fmt.Sprintf("%s failed: %v", phase, err)
+	}
+	return err.Error()
+}
+
+func since(start time.Time) time.Duration {
+	return time.Since(start).Round(time.Microsecond)
+}
@@ -0,0 +1,108 @@
+package mysql
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"stash.appscode.dev/prober-demo/pkg/mysql"
+
+	api "kmodules.xyz/prober/api"
+
+	"github.com/appscode/go/log"
+)
+
+// Options are the credentials of a MySQL probe and the checks it runs once authenticated.
+type Options struct {
+	Database string
+	Username string
+	Password string
+	// Query is run once authenticated, if it is not empty.
+	Query string
+	// RequireWritable fails the probe if the server is read-only.
+	RequireWritable bool
+}
+
+// New creates Prober.
+func New() Prober {
+	return mysqlProber{}
+}
+
+// Prober is an interface that defines the Probe function for doing MySQL checks.
+type Prober interface {
+	Probe(host string, port int, opts Options, timeout time.Duration) (api.Result, string, error)
+}
+
+type mysqlProber struct{}
+
+// Probe returns a ProbeRunner capable of running a MySQL check.
+func (pr mysqlProber) Probe(host string, port int, opts Options, timeout time.Duration) (api.Result, string, error) {
+	return DoMySQLProbe(net.JoinHostPort(host, strconv.Itoa(port)), opts, timeout)
+}
+
+// DoMySQLProbe connects to addr and authenticates with the credentials of opts, then runs the checks of opts.
+// If the connection, the authentication or a check fails before timeout, it returns Failure with a reason
+// starting with "authentication failed", "read-only" or "timeout during <phase>" when it applies.
+// Otherwise, it returns Success.
+func DoMySQLProbe(addr string, opts Options, timeout time.Duration) (api.Result, string, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		// Convert errors to failures to handle timeouts.
+		return api.Failure, describe("connect", err), nil
+	}
+	if err = conn.SetDeadline(start.Add(timeout)); err != nil {
+		conn.Close()
+		return api.Failure, err.Error(), nil
+	}
+	c, err := mysql.Connect(conn, opts.Username, opts.Password, opts.Database)
+	if err != nil {
+		conn.Close()
+		return api.Failure, describe("authentication", err), nil
+	}
+	defer func() {
+		if err := c.Close(); err != nil {
+			log.Errorf("Unexpected error closing MySQL probe connection: %v (%#v)", err, err)
+		}
+	}()
+
+	if opts.RequireWritable {
+		rows, err := c.Query("SELECT @@global.read_only")
+		if err != nil {
+			return api.Failure, describe("query", err), nil
+		}
+		if len(rows) > 0 && len(rows[0]) > 0 && rows[0][0] != "0" {
+			return api.Failure, "read-only: read_only is ON", nil
+		}
+	}
+	server := fmt.Sprintf("MySQL %s", c.ServerVersion)
+	if opts.Query == "" {
+		return api.Success, fmt.Sprintf("authenticated as %q to %s (in %v)", opts.Username, server, since(start)), nil
+	}
+	rows, err := c.Query(opts.Query)
+	if err != nil {
+		return api.Failure, describe("query", err), nil
+	}
+	return api.Success, fmt.Sprintf("%s: query %q returned %v (in %v)", server, opts.Query, rows, since(start)), nil
+}
+
+// describe returns the reason of an error, prefixed with its kind when probes tell it apart.
+func describe(phase string, err error) string {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return fmt.Sprintf("timeout during %s: %v", phase, err)
+	}
+	if myErr, ok := err.(*mysql.Error); ok {
+		switch {
+		case myErr.IsAuthError():
+			return "authentication failed: " + err.Error()
+		case myErr.Code == mysql.ErrOptionPreventsStatement:
+			return "read-only: " + err.Error()
+		}
+	}
+	return err.Error()
+}
+
+func since(start time.Time) time.Duration {
+	return time.Since(start).Round(time.Microsecond)
+}
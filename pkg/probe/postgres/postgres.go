@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"stash.appscode.dev/prober-demo/pkg/postgres"
+
+	api "kmodules.xyz/prober/api"
+
+	"github.com/appscode/go/log"
+)
+
+// Options are the credentials of a PostgreSQL probe and the checks it runs once authenticated.
+type Options struct {
+	Database string
+	Username string
+	Password string
+	// Query is run once authenticated, if it is not empty.
+	Query string
+	// RequireWritable fails the probe if the server is in recovery or its transactions are read-only.
+	RequireWritable bool
+}
+
+// New creates Prober.
+func New() Prober {
+	return postgresProber{}
+}
+
+// Prober is an interface that defines the Probe function for doing PostgreSQL checks.
+type Prober interface {
+	Probe(host string, port int, opts Options, timeout time.Duration) (api.Result, string, error)
+}
+
+type postgresProber struct{}
+
+// Probe returns a ProbeRunner capable of running a PostgreSQL check.
+func (pr postgresProber) Probe(host string, port int, opts Options, timeout time.Duration) (api.Result, string, error) {
+	return DoPostgresProbe(net.JoinHostPort(host, strconv.Itoa(port)), opts, timeout)
+}
+
+// DoPostgresProbe connects to addr and authenticates with the credentials of opts, then runs the checks of opts.
+// If the connection, the authentication or a check fails before timeout, it returns Failure with a reason
+// starting with "authentication failed", "in recovery", "read-only" or "timeout during <phase>" when it applies.
+// Otherwise, it returns Success.
+func DoPostgresProbe(addr string, opts Options, timeout time.Duration) (api.Result, string, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		// Convert errors to failures to handle timeouts.
+		return api.Failure, describe("connect", err), nil
+	}
+	if err = conn.SetDeadline(start.Add(timeout)); err != nil {
+		conn.Close()
+		return api.Failure, err.Error(), nil
+	}
+	c, err := postgres.Connect(conn, opts.Username, opts.Password, opts.Database)
+	if err != nil {
+		conn.Close()
+		return api.Failure, describe("authentication", err), nil
+	}
+	defer func() {
+		if err := c.Close(); err != nil {
+			log.Errorf("Unexpected error closing PostgreSQL probe connection: %v (%#v)", err, err)
+		}
+	}()
+
+	if opts.RequireWritable {
+		if reason := checkWritable(c); reason != "" {
+			return api.Failure, reason, nil
+		}
+	}
+	server := fmt.Sprintf("PostgreSQL %s", c.Params["server_version"])
+	if opts.Query == "" {
+		return api.Success, fmt.Sprintf("authenticated as %q to %s (in %v)", opts.Username, server, since(start)), nil
+	}
+	rows, err := c.Query(opts.Query)
+	if err != nil {
+		return api.Failure, describe("query", err), nil
+	}
+	return api.Success, fmt.Sprintf("%s: query %q returned %v (in %v)", server, opts.Query, rows, since(start)), nil
+}
+
+// checkWritable returns why the server does not accept writes, or "" if it does.
+func checkWritable(c *postgres.Conn) string {
+	rows, err := c.Query("SELECT pg_is_in_recovery()")
+	if err != nil {
+		return describe("query", err)
+	}
+	if len(rows) > 0 && len(rows[0]) > 0 && rows[0][0] == "t" {
+		return "in recovery: server is a standby"
+	}
+	rows, err = c.Query("SHOW transaction_read_only")
+	if err != nil {
+		return describe("query", err)
+	}
+	if len(rows) > 0 && len(rows[0]) > 0 && rows[0][0] == "on" {
+		return "read-only: transaction_read_only is on"
+	}
+	return ""
+}
+
+// describe returns the reason of an error, prefixed with its kind when probes tell it apart.
+func describe(phase string, err error) string {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return fmt.Sprintf("timeout during %s: %v", phase, err)
+	}
+	if pgErr, ok := err.(*postgres.Error); ok {
+		switch {
+		case pgErr.IsAuthError():
+			return "authentication failed: " + err.Error()
+		case pgErr.Code == postgres.CodeCannotConnectNow:
+			return "in recovery: " + err.Error()
+		case pgErr.Code == postgres.CodeReadOnlyTransaction:
+			return "read-only: " + err.Error()
+		}
+	}
+	return err.Error()
+}
+
+func since(start time.Time) time.Duration {
+	return time.Since(start).Round(time.Microsecond)
+}
@@ -0,0 +1,635 @@
+package probe
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+	"stash.appscode.dev/prober-demo/pkg/dns"
+	dnsprobe "stash.appscode.dev/prober-demo/pkg/probe/dns"
+	"stash.appscode.dev/prober-demo/pkg/probe/failure"
+	grpcprobe "stash.appscode.dev/prober-demo/pkg/probe/grpc"
+	httpprobe "stash.appscode.dev/prober-demo/pkg/probe/http"
+	mongodbprobe "stash.appscode.dev/prober-demo/pkg/probe/mongodb"
+	mysqlprobe "stash.appscode.dev/prober-demo/pkg/probe/mysql"
+	postgresprobe "stash.appscode.dev/prober-demo/pkg/probe/postgres"
+	redisprobe "stash.appscode.dev/prober-demo/pkg/probe/redis"
+	tcpprobe "stash.appscode.dev/prober-demo/pkg/probe/tcp"
+	tlscertprobe "stash.appscode.dev/prober-demo/pkg/probe/tlscert"
+	udpprobe "stash.appscode.dev/prober-demo/pkg/probe/udp"
+
+	"github.com/appscode/go/log"
+	core "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	api "kmodules.xyz/prober/api"
+	prober_v1 "kmodules.xyz/prober/api/v1"
+	"kmodules.xyz/prober/probe"
+	prober_http "kmodules.xyz/prober/probe/http"
+)
+
+const (
+	// followNonLocalRedirects is used by HTTP probes that don't set HTTPRedirects.
+	followNonLocalRedirects = false
+	// defaultWarnBefore is used by TLSCert probes that don't set WarnBefore.
+	defaultWarnBefore = 30 * 24 * time.Hour
+)
+
+// Prober runs the probes of kmodules.xyz/prober along with the extensions defined in api_v1.Handler.
+type Prober struct {
+	*probe.Prober
+	TLSCert  tlscertprobe.Prober
+	GRPC     grpcprobe.Prober
+	TCP      tcpprobe.Prober
+	UDP      udpprobe.Prober
+	DNS      dnsprobe.Prober
+	Postgres postgresprobe.Prober
+	MySQL    mysqlprobe.Prober
+	Redis    redisprobe.Prober
+	MongoDB  mongodbprobe.Prober
+	// KubeClient reads the ConfigMaps and Secrets referred to by probes.
+	KubeClient kubernetes.Interface
+	// TLS holds the TLS options used by HTTPS, TLSCert and GRPC probes for every field they don't set themselves.
+	TLS *api_v1.TLSConfig
+}
+
+// NewProber creates a Prober instance that can be used to run the probes of an api_v1.Handler.
+func NewProber(config *rest.Config) *Prober {
+	pb := &Prober{
+		Prober:   probe.NewProber(config),
+		TLSCert:  tlscertprobe.New(),
+		GRPC:     grpcprobe.New(),
+		TCP:      tcpprobe.New(),
+		UDP:      udpprobe.New(),
+		DNS:      dnsprobe.New(),
+		Postgres: postgresprobe.New(),
+		MySQL:    mysqlprobe.New(),
+		Redis:    redisprobe.New(),
+		MongoDB:  mongodbprobe.New(),
+	}
+	if config != nil {
+		pb.KubeClient = kubernetes.NewForConfigOrDie(config)
+	}
+	return pb
+}
+
+// RunProbe runs the probe described by p against the given container.
+// Failed probes are run again as set by the retry options of p, within timeout.
+func (pb *Prober) RunProbe(p *api_v1.Handler, pod *core.Pod, status core.PodStatus, container core.Container, timeout time.Duration) (api.Result, string, error) {
+	report, err := pb.Run(p, pod, status, container, timeout)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	return report.Result, report.Reason, nil
+}
+
+// Report describes a run of a probe, with the details gathered along its result.
+type Report struct {
+	Result api.Result
+	Reason string
+	// Duration is the time spent running the probe, retries and delays between them included.
+	Duration time.Duration
+	// Attempts is the number of times the probe ran, more than one if it was retried.
+	Attempts int
+	// StatusCode is the status of the last response of HTTP probes, zero for other probes or if none was received.
+	StatusCode int
+	// HTTPTiming is the latency breakdown of the last attempt of HTTP probes, nil for other probes.
+	HTTPTiming *httpprobe.Timing
+	// Code is the class of the failure of TCP and HTTP probes. It is empty for other probes, and if they did not fail.
+	Code failure.Code
+}
+
+// attempt is the outcome of a single run of a probe.
+type attempt struct {
+	result  api.Result
+	reason  string
+	latency time.Duration
+	// statusCode is the status of the response of HTTP probes, zero if none was received.
+	statusCode int
+	// timing is the latency breakdown of HTTP probes.
+	timing *httpprobe.Timing
+	// code is the class of the failure of TCP and HTTP probes, empty otherwise.
+	code failure.Code
+}
+
+// Run runs the probe like RunProbe, and reports the details of the run.
+func (pb *Prober) Run(p *api_v1.Handler, pod *core.Pod, status core.PodStatus, container core.Container, timeout time.Duration) (*Report, error) {
+	start := time.Now()
+	var a attempt
+	attempts := 1
+	var err error
+	if p.Retries != 0 {
+		a, attempts, err = pb.runWithRetries(p, pod, status, container, timeout)
+	} else {
+		a.result, a.reason, err = pb.runOnce(p, pod, status, container, timeout, &a)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Report{
+		Result:     a.result,
+		Reason:     a.reason,
+		Duration:   since(start),
+		Attempts:   attempts,
+		StatusCode: a.statusCode,
+		HTTPTiming: a.timing,
+		Code:       a.code,
+	}, nil
+}
+
+// runOnce runs the probe described by p a single time. If a is not nil, it is filled with the details of the run.
+func (pb *Prober) runOnce(p *api_v1.Handler, pod *core.Pod, status core.PodStatus, container core.Container, timeout time.Duration, a *attempt) (api.Result, string, error) {
+	if p.Composite != nil {
+		return pb.runComposite(p, pod, status, container, timeout)
+	}
+	if p.TLSCert != nil {
+		return pb.runTLSCert(p, status, container, timeout)
+	}
+	if p.GRPC != nil {
+		return pb.runGRPC(p, status, container, timeout)
+	}
+	if p.UDP != nil {
+		return pb.runUDP(p, status, container, timeout)
+	}
+	if p.DNS != nil {
+		return pb.runDNS(p, timeout)
+	}
+	if p.Postgres != nil {
+		return pb.runPostgres(p, pod, status, container, timeout)
+	}
+	if p.MySQL != nil {
+		return pb.runMySQL(p, pod, status, container, timeout)
+	}
+	if p.Redis != nil {
+		return pb.runRedis(p, pod, status, container, timeout)
+	}
+	if p.MongoDB != nil {
+		return pb.runMongoDB(p, status, container, timeout)
+	}
+	if p.HTTPScenario != nil {
+		return pb.runHTTPScenario(p, pod, status, container, timeout)
+	}
+	if p.TCPSocket != nil {
+		return pb.runTCP(p, status, container, timeout, a)
+	}
+	if action := httpAction(p); action != nil {
+		return pb.runHTTP(p, action, pod, status, container, timeout, a)
+	}
+	return pb.Prober.RunProbe(&p.Handler, pod, status, container, timeout)
+}
+
+func (pb *Prober) runHTTP(p *api_v1.Handler, action *api_v1.HTTPAction, pod *core.Pod, status core.PodStatus, container core.Container, timeout time.Duration, a *attempt) (api.Result, string, error) {
+	scheme := httpScheme(action.Scheme)
+	host := probeHost(action.Host, status)
+	port, err := extractPort(action.Port, container)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	client, err := pb.httpClient(p, scheme, host, timeout)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	statusResults, err := httpprobe.ParseStatusResults(p.HTTPStatusResults)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	assertions, err := httpprobe.ParseAssertions(p.HTTPAssertions)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	method := strings.ToUpper(action.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	targetURL := formatURL(scheme, host, port, action.Path)
+	headers := buildHeader(action.HTTPHeaders)
+	form, body := action.Form, action.Body
+	// values read from Secrets, to be kept out of the output.
+	var secrets []string
+	if src := p.HTTPBody; src != nil {
+		rb, err := pb.httpBody(src, pod.Namespace)
+		if err != nil {
+			return api.Unknown, "", err
+		}
+		contentType := src.ContentType
+		if rb != nil {
+			form, body = nil, rb.value
+			if rb.secret {
+				secrets = append(secrets, rb.value)
+			}
+			if rb.contentType != "" {
+				contentType = rb.contentType
+			}
+		}
+		if contentType != "" {
+			headers.Set(prober_http.ContentType, contentType)
+		}
+	}
+	headerSecrets, err := pb.resolveHeaders(p, headers, pod.Namespace)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	secrets = append(secrets, headerSecrets...)
+	log.Debugf("HTTP-Probe Method: %v, URL: %v, Headers: %v", method, targetURL, redactHeader(headers, secrets))
+	rec := httpprobe.NewRecorder(client)
+	result, reason, err := httpprobe.DoHTTPProbe(method, targetURL, headers, rec, form, body)
+	if err != nil {
+		return result, reason, err
+	}
+	result, reason = statusResults.Apply(rec.Response(), result, reason)
+	result, reason = assertions.Check(rec.Response(), statusResults, result, reason)
+	if a != nil {
+		a.timing = rec.Timing()
+		if res := rec.Response(); res != nil {
+			a.statusCode = res.StatusCode
+		}
+		if result == api.Failure {
+			a.code = httpFailureCode(rec)
+		}
+	}
+	if redirects := rec.Redirects(); len(redirects) > 0 {
+		reason = strings.TrimSpace(fmt.Sprintf("%s (redirects: %s)", reason, strings.Join(redirects, " -> ")))
+	}
+	return result, redact(reason, secrets), nil
+}
+
+// httpFailureCode returns the code of the failure of the request of rec.
+func httpFailureCode(rec *httpprobe.Recorder) failure.Code {
+	res := rec.Response()
+	switch {
+	case isTooManyRedirects(rec.Err()):
+		return failure.TooManyRedirects
+	case res == nil && rec.Err() != nil:
+		return failure.Classify(rec.Err())
+	case res == nil:
+		return failure.NetworkError
+	case res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusMultipleChoices:
+		return failure.AssertionFailed
+	}
+	return failure.HTTPStatus
+}
+
+// isTooManyRedirects returns true if err is the error of a client that stopped following redirects at its limit.
+func isTooManyRedirects(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		_, ok = urlErr.Err.(*httpprobe.TooManyRedirectsError)
+		return ok
+	}
+	return false
+}
+
+// resolveHeaders adds the headers of HTTPHeadersFrom and HTTPAuth to headers, and returns the values it resolved.
+func (pb *Prober) resolveHeaders(p *api_v1.Handler, headers http.Header, namespace string) ([]string, error) {
+	var secrets []string
+	for i := range p.HTTPHeadersFrom {
+		h := &p.HTTPHeadersFrom[i]
+		value, err := pb.resolveValue(&h.ValueFrom, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve header %s: %v", h.Name, err)
+		}
+		headers.Add(h.Name, value)
+		secrets = append(secrets, value)
+	}
+
+	auth := p.HTTPAuth
+	switch {
+	case auth == nil:
+	case auth.Basic != nil:
+		password, err := pb.resolveValue(&auth.Basic.Password, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve basic auth password: %v", err)
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte(auth.Basic.Username + ":" + password))
+		headers.Set("Authorization", "Basic "+credentials)
+		secrets = append(secrets, password, credentials)
+	case auth.Bearer != nil:
+		token, err := pb.resolveValue(auth.Bearer, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve bearer token: %v", err)
+		}
+		headers.Set("Authorization", "Bearer "+token)
+		secrets = append(secrets, token)
+	}
+	return secrets, nil
+}
+
+// requestBody is the body of an HTTP probe read from an api_v1.HTTPBodySource.
+type requestBody struct {
+	value string
+	// contentType is the Content-Type of value, if its encoding decides it.
+	contentType string
+	// secret is true if value was read from a Secret.
+	secret bool
+}
+
+// httpBody reads the body described by src, or returns nil if src sets no body.
+func (pb *Prober) httpBody(src *api_v1.HTTPBodySource, namespace string) (*requestBody, error) {
+	switch {
+	case src.File != "":
+		data, err := ioutil.ReadFile(src.File)
+		return &requestBody{value: string(data)}, err
+	case src.ConfigMapKeyRef != nil:
+		value, err := pb.configMapValue(namespace, src.ConfigMapKeyRef)
+		return &requestBody{value: value}, err
+	case src.SecretKeyRef != nil:
+		value, err := pb.secretValue(namespace, src.SecretKeyRef)
+		return &requestBody{value: value, secret: true}, err
+	case len(src.Multipart) > 0:
+		value, contentType, err := httpprobe.MultipartBody(src.Multipart, src.ContentType)
+		return &requestBody{value: value, contentType: contentType}, err
+	case src.Protobuf != "":
+		value, err := httpprobe.ProtobufBody(src.Protobuf)
+		contentType := src.ContentType
+		if contentType == "" {
+			contentType = httpprobe.ContentProtobuf
+		}
+		return &requestBody{value: value, contentType: contentType}, err
+	}
+	return nil, nil
+}
+
+// httpAction returns the HTTP request of a probe, turning the HTTPGet and HTTPPost shortcuts into an HTTPAction.
+func httpAction(p *api_v1.Handler) *api_v1.HTTPAction {
+	switch {
+	case p.HTTP != nil:
+		return p.HTTP
+	case p.HTTPGet != nil:
+		return &api_v1.HTTPAction{
+			Method: http.MethodGet,
+			HTTPPostAction: prober_v1.HTTPPostAction{
+				Path:        p.HTTPGet.Path,
+				Port:        p.HTTPGet.Port,
+				Host:        p.HTTPGet.Host,
+				Scheme:      p.HTTPGet.Scheme,
+				HTTPHeaders: p.HTTPGet.HTTPHeaders,
+			},
+		}
+	case p.HTTPPost != nil:
+		return &api_v1.HTTPAction{
+			Method:         http.MethodPost,
+			HTTPPostAction: *p.HTTPPost,
+		}
+	}
+	return nil
+}
+
+// httpClient returns the client used by an HTTP probe of host.
+func (pb *Prober) httpClient(p *api_v1.Handler, scheme string, host string, timeout time.Duration) (*http.Client, error) {
+	var tlsConfig *tls.Config
+	if scheme == "https" {
+		var err error
+		if tlsConfig, err = pb.tlsConfig(p, host); err != nil {
+			return nil, err
+		}
+	}
+	followNonLocal, maxRedirects := followNonLocalRedirects, httpprobe.DefaultMaxRedirects
+	if policy := p.HTTPRedirects; policy != nil {
+		followNonLocal = policy.FollowNonLocal
+		if policy.MaxRedirects != nil {
+			if *policy.MaxRedirects < 0 {
+				return nil, fmt.Errorf("invalid maximum number of redirects: %d", *policy.MaxRedirects)
+			}
+			maxRedirects = int(*policy.MaxRedirects)
+		}
+	}
+	return httpprobe.NewClient(tlsConfig, followNonLocal, maxRedirects, timeout), nil
+}
+
+// tlsConfig returns the TLS configuration used to probe host.
+// Without TLS options, certificate verification is skipped like kmodules.xyz/prober does.
+func (pb *Prober) tlsConfig(p *api_v1.Handler, host string) (*tls.Config, error) {
+	if tlsOpts := mergeTLSConfig(p.TLS, pb.TLS); tlsOpts != nil {
+		return newTLSConfig(tlsOpts, host)
+	}
+	return &tls.Config{InsecureSkipVerify: true}, nil
+}
+
+// httpScheme returns the lower case scheme of an HTTP probe, defaulting to http.
+func httpScheme(scheme core.URIScheme) string {
+	if scheme == "" {
+		return "http"
+	}
+	return strings.ToLower(string(scheme))
+}
+
+func (pb *Prober) runTLSCert(p *api_v1.Handler, status core.PodStatus, container core.Container, timeout time.Duration) (api.Result, string, error) {
+	host := probeHost(p.TLSCert.Host, status)
+	port, err := extractPort(p.TLSCert.Port, container)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	tlsOpts := mergeTLSConfig(p.TLS, pb.TLS)
+	if tlsOpts == nil {
+		tlsOpts = &api_v1.TLSConfig{}
+	}
+	tlsConfig, err := newTLSConfig(tlsOpts, host)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	roots, err := rootCAs(tlsOpts)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	warnBefore := defaultWarnBefore
+	if p.TLSCert.WarnBefore != nil {
+		warnBefore = p.TLSCert.WarnBefore.Duration
+	}
+	log.Debugf("TLSCert-Probe Host: %v, Port: %v, ServerName: %v, WarnBefore: %v", host, port, tlsConfig.ServerName, warnBefore)
+	return pb.TLSCert.Probe(host, port, tlsConfig, roots, warnBefore, timeout)
+}
+
+func (pb *Prober) runGRPC(p *api_v1.Handler, status core.PodStatus, container core.Container, timeout time.Duration) (api.Result, string, error) {
+	host := probeHost(p.GRPC.Host, status)
+	port, err := extractPort(p.GRPC.Port, container)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	var tlsConfig *tls.Config
+	if httpScheme(p.GRPC.Scheme) == "https" {
+		if tlsConfig, err = pb.tlsConfig(p, host); err != nil {
+			return api.Unknown, "", err
+		}
+	}
+	log.Debugf("gRPC-Probe Host: %v, Port: %v, Service: %q, TLS: %v", host, port, p.GRPC.Service, tlsConfig != nil)
+	return pb.GRPC.Probe(host, port, p.GRPC.Service, tlsConfig, timeout)
+}
+
+// runTCP runs TCPSocket probes, sending and expecting the bytes of TCPExchange if it is set.
+func (pb *Prober) runTCP(p *api_v1.Handler, status core.PodStatus, container core.Container, timeout time.Duration, a *attempt) (api.Result, string, error) {
+	host := probeHost(p.TCPSocket.Host, status)
+	port, err := extractPort(p.TCPSocket.Port, container)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	var send []byte
+	var expect *tcpprobe.Expectation
+	readTimeout := timeout
+	if ex := p.TCPExchange; ex != nil {
+		if send, expect, err = exchange(ex.Send, ex.ExpectRegex, ex.ExpectBytes); err != nil {
+			return api.Unknown, "", err
+		}
+		if ex.ReadTimeout != nil {
+			readTimeout = ex.ReadTimeout.Duration
+		}
+	}
+	log.Debugf("TCP-Probe Host: %v, Port: %v, Send: %q, Expect: %v", host, port, send, expect)
+	result, reason, code, err := pb.TCP.Probe(host, port, send, expect, readTimeout, timeout)
+	if a != nil {
+		a.code = code
+	}
+	return result, reason, err
+}
+
+func (pb *Prober) runUDP(p *api_v1.Handler, status core.PodStatus, container core.Container, timeout time.Duration) (api.Result, string, error) {
+	host := probeHost(p.UDP.Host, status)
+	port, err := extractPort(p.UDP.Port, container)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	send, expect, err := exchange(p.UDP.Send, p.UDP.ExpectRegex, p.UDP.ExpectBytes)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	log.Debugf("UDP-Probe Host: %v, Port: %v, Send: %q, Expect: %v", host, port, send, expect)
+	return pb.UDP.Probe(host, port, send, expect, timeout)
+}
+
+// exchange decodes the payload and the expectation of TCP exchange and UDP probes.
+// The expectation is nil if neither expectRegex nor expectBytes is set.
+func exchange(send, expectRegex, expectBytes string) ([]byte, *tcpprobe.Expectation, error) {
+	payload, err := tcpprobe.Unescape(send)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid send payload: %v", err)
+	}
+	switch {
+	case expectRegex != "":
+		re, err := regexp.Compile(expectRegex)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid expected regex %q: %v", expectRegex, err)
+		}
+		return payload, &tcpprobe.Expectation{Regex: re}, nil
+	case expectBytes != "":
+		b, err := tcpprobe.Unescape(expectBytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid expected bytes: %v", err)
+		}
+		return payload, &tcpprobe.Expectation{Bytes: b}, nil
+	}
+	return payload, nil, nil
+}
+
+func (pb *Prober) runDNS(p *api_v1.Handler, timeout time.Duration) (api.Result, string, error) {
+	qtype := dns.TypeA
+	if p.DNS.Type != "" {
+		var err error
+		if qtype, err = dns.ParseType(p.DNS.Type); err != nil {
+			return api.Unknown, "", err
+		}
+	}
+	if p.DNS.Name == "" {
+		return api.Unknown, "", fmt.Errorf("DNS probe has no name to resolve")
+	}
+	var warnLatency, maxLatency time.Duration
+	if p.DNS.WarnLatency != nil {
+		warnLatency = p.DNS.WarnLatency.Duration
+	}
+	if p.DNS.MaxLatency != nil {
+		maxLatency = p.DNS.MaxLatency.Duration
+	}
+	if warnLatency < 0 || maxLatency < 0 {
+		return api.Unknown, "", fmt.Errorf("invalid DNS probe latency, warnLatency and maxLatency must not be negative")
+	}
+	log.Debugf("DNS-Probe Name: %v, Type: %v, Server: %q, Expect: %v, WarnLatency: %v, MaxLatency: %v", p.DNS.Name, qtype, p.DNS.Server, p.DNS.Expect, warnLatency, maxLatency)
+	return pb.DNS.Probe(p.DNS.Name, qtype, p.DNS.Server, p.DNS.Expect, warnLatency, maxLatency, timeout)
+}
+
+func (pb *Prober) runPostgres(p *api_v1.Handler, pod *core.Pod, status core.PodStatus, container core.Container, timeout time.Duration) (api.Result, string, error) {
+	action := p.Postgres
+	host, port, password, err := pb.databaseTarget(action, pod, status, container)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	opts := postgresprobe.Options{
+		Database:        action.Database,
+		Username:        action.Username,
+		Password:        password,
+		Query:           action.Query,
+		RequireWritable: action.RequireWritable,
+	}
+	log.Debugf("Postgres-Probe Host: %v, Port: %v, Database: %q, Username: %q, Query: %q", host, port, opts.Database, opts.Username, opts.Query)
+	result, reason, err := pb.Postgres.Probe(host, port, opts, timeout)
+	return result, redact(reason, []string{password}), err
+}
+
+func (pb *Prober) runMySQL(p *api_v1.Handler, pod *core.Pod, status core.PodStatus, container core.Container, timeout time.Duration) (api.Result, string, error) {
+	action := p.MySQL
+	host, port, password, err := pb.databaseTarget(action, pod, status, container)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	opts := mysqlprobe.Options{
+		Database:        action.Database,
+		Username:        action.Username,
+		Password:        password,
+		Query:           action.Query,
+		RequireWritable: action.RequireWritable,
+	}
+	log.Debugf("MySQL-Probe Host: %v, Port: %v, Database: %q, Username: %q, Query: %q", host, port, opts.Database, opts.Username, opts.Query)
+	result, reason, err := pb.MySQL.Probe(host, port, opts, timeout)
+	return result, redact(reason, []string{password}), err
+}
+
+// databaseTarget returns the host and port of a database probe, and the password it resolves.
+func (pb *Prober) databaseTarget(action *api_v1.DatabaseAction, pod *core.Pod, status core.PodStatus, container core.Container) (string, int, string, error) {
+	host := probeHost(action.Host, status)
+	port, err := extractPort(action.Port, container)
+	if err != nil {
+		return "", 0, "", err
+	}
+	var password string
+	if action.Password != nil {
+		if password, err = pb.resolveValue(action.Password, pod.Namespace); err != nil {
+			return "", 0, "", err
+		}
+	}
+	return host, port, password, nil
+}
+
+func (pb *Prober) runRedis(p *api_v1.Handler, pod *core.Pod, status core.PodStatus, container core.Container, timeout time.Duration) (api.Result, string, error) {
+	host := probeHost(p.Redis.Host, status)
+	port, err := extractPort(p.Redis.Port, container)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	switch p.Redis.Role {
+	case "", redisprobe.RoleMaster, redisprobe.RoleReplica:
+	default:
+		return api.Unknown, "", fmt.Errorf("invalid Redis role %q, expected %s or %s", p.Redis.Role, redisprobe.RoleMaster, redisprobe.RoleReplica)
+	}
+	opts := redisprobe.Options{Username: p.Redis.Username, Role: p.Redis.Role}
+	if p.Redis.Password != nil {
+		if opts.Password, err = pb.resolveValue(p.Redis.Password, pod.Namespace); err != nil {
+			return api.Unknown, "", err
+		}
+	}
+	log.Debugf("Redis-Probe Host: %v, Port: %v, Username: %q, Role: %q", host, port, opts.Username, opts.Role)
+	result, reason, err := pb.Redis.Probe(host, port, opts, timeout)
+	return result, redact(reason, []string{opts.Password}), err
+}
+
+func (pb *Prober) runMongoDB(p *api_v1.Handler, status core.PodStatus, container core.Container, timeout time.Duration) (api.Result, string, error) {
+	host := probeHost(p.MongoDB.Host, status)
+	port, err := extractPort(p.MongoDB.Port, container)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	log.Debugf("MongoDB-Probe Host: %v, Port: %v, PrimaryOnly: %v", host, port, p.MongoDB.PrimaryOnly)
+	return pb.MongoDB.Probe(host, port, p.MongoDB.PrimaryOnly, timeout)
+}
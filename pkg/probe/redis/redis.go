@@ -0,0 +1,116 @@
+package redis
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"stash.appscode.dev/prober-demo/pkg/redis"
+
+	api "kmodules.xyz/prober/api"
+
+	"github.com/appscode/go/log"
+)
+
+// Roles a probe can require. Replica also matches the slave role of older servers.
+const (
+	RoleMaster  = "master"
+	RoleReplica = "replica"
+)
+
+// Options are the credentials of a Redis probe and the role it requires.
+type Options struct {
+	// Username is sent along with Password, for servers with ACL users.
+	Username string
+	// Password is sent with AUTH before PING, if it is not empty.
+	Password string
+	// Role is checked with ROLE, if it is not empty. One of RoleMaster or RoleReplica.
+	Role string
+}
+
+// New creates Prober.
+func New() Prober {
+	return redisProber{}
+}
+
+// Prober is an interface that defines the Probe function for doing Redis checks.
+type Prober interface {
+	Probe(host string, port int, opts Options, timeout time.Duration) (api.Result, string, error)
+}
+
+type redisProber struct{}
+
+// Probe returns a ProbeRunner capable of running a Redis check.
+func (pr redisProber) Probe(host string, port int, opts Options, timeout time.Duration) (api.Result, string, error) {
+	return DoRedisProbe(net.JoinHostPort(host, strconv.Itoa(port)), opts, timeout)
+}
+
+// DoRedisProbe connects to addr, authenticates if opts has a password, and sends PING, then ROLE if opts requires one.
+// If any of them fails before timeout, or the server has another role, it returns Failure with a reason
+// starting with "authentication failed", "loading" or "timeout during <phase>" when it applies.
+// Otherwise, it returns Success.
+func DoRedisProbe(addr string, opts Options, timeout time.Duration) (api.Result, string, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		// Convert errors to failures to handle timeouts.
+		return api.Failure, describe("connect", err), nil
+	}
+	c := redis.NewConn(conn)
+	defer func() {
+		if err := c.Close(); err != nil {
+			log.Errorf("Unexpected error closing Redis probe connection: %v (%#v)", err, err)
+		}
+	}()
+	if err = conn.SetDeadline(start.Add(timeout)); err != nil {
+		return api.Failure, err.Error(), nil
+	}
+
+	if opts.Password != "" {
+		if err = c.Auth(opts.Username, opts.Password); err != nil {
+			return api.Failure, describe("AUTH", err), nil
+		}
+	}
+	pong, err := c.Ping()
+	if err != nil {
+		return api.Failure, describe("PING", err), nil
+	}
+	if opts.Role == "" {
+		return api.Success, fmt.Sprintf("%s (in %v)", pong, since(start)), nil
+	}
+	role, err := c.Role()
+	if err != nil {
+		return api.Failure, describe("ROLE", err), nil
+	}
+	if role == redis.RoleReplica {
+		role = RoleReplica
+	}
+	if role != opts.Role {
+		return api.Failure, fmt.Sprintf("expected role %s, got %s", opts.Role, role), nil
+	}
+	return api.Success, fmt.Sprintf("%s from %s (in %v)", pong, role, since(start)), nil
+}
+
+// describe returns the reason of an error, prefixed with its kind when probes tell it apart.
+func describe(phase string, err error) string {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return fmt.Sprintf("timeout during %s: %v", phase, err)
+	}
+	if redisErr, ok := err.(redis.Error); ok {
+		switch redisErr.Prefix() {
+		case "NOAUTH", "WRONGPASS":
+			return "authentication failed: " + err.Error()
+		case "LOADING":
+			return "loading: " + err.Error()
+		}
+		if phase == "AUTH" {
+			return "authentication failed: " + err.Error()
+		}
+	}
+	return err.Error()
+}
+
+func since(start time.Time) time.Duration {
+	return time.Since(start).Round(time.Microsecond)
+}
@@ -0,0 +1,242 @@
+package probe
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+	"stash.appscode.dev/prober-demo/pkg/probe/failure"
+	httpprobe "stash.appscode.dev/prober-demo/pkg/probe/http"
+
+	"github.com/appscode/go/log"
+	core "k8s.io/api/core/v1"
+	api "kmodules.xyz/prober/api"
+)
+
+// Types of retry backoff.
+const (
+	backoffConstant    = "constant"
+	backoffExponential = "exponential"
+)
+
+// Failures RetryOn selects besides status codes.
+const (
+	retryOnConnectionError = "connectionError"
+	retryOnTimeout         = "timeout"
+)
+
+const (
+	defaultRetryDelay    = time.Second
+	defaultMaxRetryDelay = 30 * time.Second
+)
+
+// retryPolicy is the parsed form of the retry options of a probe.
+type retryPolicy struct {
+	retries     int
+	exponential bool
+	delay       time.Duration
+	maxDelay    time.Duration
+	// attemptTimeout is the timeout of each attempt, 0 to share the probe timeout equally.
+	attemptTimeout time.Duration
+	// all is true if RetryOn is empty, every failure is retried then.
+	all              bool
+	connectionErrors bool
+	timeouts         bool
+	codes            httpprobe.CodeRanges
+}
+
+func newRetryPolicy(p *api_v1.Handler) (*retryPolicy, error) {
+	if p.Retries < 0 {
+		return nil, fmt.Errorf("invalid number of retries: %d", p.Retries)
+	}
+	rp := &retryPolicy{
+		retries:  int(p.Retries),
+		delay:    defaultRetryDelay,
+		maxDelay: defaultMaxRetryDelay,
+		all:      len(p.RetryOn) == 0,
+	}
+	if b := p.RetryBackoff; b != nil {
+		switch b.Type {
+		case "", backoffConstant:
+		case backoffExponential:
+			rp.exponential = true
+		default:
+			return nil, fmt.Errorf("invalid retry backoff type %q, must be one of %s or %s", b.Type, backoffConstant, backoffExponential)
+		}
+		if b.Delay != nil {
+			rp.delay = b.Delay.Duration
+		}
+		if b.MaxDelay != nil {
+			rp.maxDelay = b.MaxDelay.Duration
+		}
+		if rp.delay < 0 || rp.maxDelay < 0 {
+			return nil, fmt.Errorf("invalid retry backoff, delays must not be negative")
+		}
+	}
+	if p.PerAttemptTimeout != nil {
+		if p.PerAttemptTimeout.Duration <= 0 {
+			return nil, fmt.Errorf("invalid perAttemptTimeout %v, must be positive", p.PerAttemptTimeout.Duration)
+		}
+		rp.attemptTimeout = p.PerAttemptTimeout.Duration
+	}
+	var codes []string
+	for _, on := range p.RetryOn {
+		switch on {
+		case retryOnConnectionError:
+			rp.connectionErrors = true
+		case retryOnTimeout:
+			rp.timeouts = true
+		default:
+			codes = append(codes, on)
+		}
+	}
+	var err error
+	if rp.codes, err = httpprobe.ParseCodeRanges(codes); err != nil {
+		return nil, fmt.Errorf("invalid retryOn: %v, must be %s, %s or status codes", err, retryOnConnectionError, retryOnTimeout)
+	}
+	return rp, nil
+}
+
+// retryable returns true if the policy retries the failure of a.
+func (rp *retryPolicy) retryable(a *attempt) bool {
+	if a.result != api.Failure {
+		return false
+	}
+	switch {
+	case rp.all:
+		return true
+	case a.statusCode != 0:
+		return rp.codes.Contains(a.statusCode)
+	case a.code == failure.Timeout:
+		return rp.timeouts
+	case a.code != "":
+		return rp.connectionErrors && isConnectionError(a.code)
+	// the other probes do not classify their failures, their reasons tell.
+	case isTimeoutReason(a.reason):
+		return rp.timeouts
+	case isConnectionErrorReason(a.reason):
+		return rp.connectionErrors
+	}
+	return false
+}
+
+// isConnectionError returns true if code means that the probe could not connect, or lost its connection.
+func isConnectionError(code failure.Code) bool {
+	switch code {
+	case failure.ConnectionRefused, failure.ConnectionReset, failure.ConnectionClosed,
+		failure.NoRouteToHost, failure.NetworkUnreachable, failure.NXDomain, failure.DNSError, failure.NetworkError:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before the given retry, starting at 1.
+func (rp *retryPolicy) backoff(retry int) time.Duration {
+	if !rp.exponential {
+		return rp.delay
+	}
+	delay := rp.delay
+	for i := 1; i < retry && delay < rp.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > rp.maxDelay {
+		delay = rp.maxDelay
+	}
+	if half := delay / 2; half > 0 {
+		delay = half + time.Duration(rand.Int63n(int64(half)+1))
+	}
+	return delay
+}
+
+// timeout returns the timeout of the next attempt of a probe with the given timeout, which ends by deadline.
+func (rp *retryPolicy) timeout(timeout time.Duration, deadline time.Time) time.Duration {
+	left := time.Until(deadline)
+	if rp.retries == 0 {
+		return left
+	}
+	attemptTimeout := rp.attemptTimeout
+	if attemptTimeout == 0 {
+		attemptTimeout = timeout / time.Duration(rp.retries+1)
+	}
+	if attemptTimeout > left {
+		return left
+	}
+	return attemptTimeout
+}
+
+// runWithRetries runs the probe described by p until it succeeds, the policy stops retrying or timeout is reached.
+// Each attempt has its own timeout, so an attempt that times out can be retried.
+// It returns the last attempt, with a reason showing every attempt if there was more than one, and the number of attempts.
+func (pb *Prober) runWithRetries(p *api_v1.Handler, pod *core.Pod, status core.PodStatus, container core.Container, timeout time.Duration) (attempt, int, error) {
+	rp, err := newRetryPolicy(p)
+	if err != nil {
+		return attempt{}, 0, err
+	}
+	start := time.Now()
+	deadline := start.Add(timeout)
+	var attempts []attempt
+	// stopped tells why retries stopped before the policy allowed, if they did.
+	var stopped string
+	for {
+		a := attempt{}
+		attemptStart := time.Now()
+		a.result, a.reason, err = pb.runOnce(p, pod, status, container, rp.timeout(timeout, deadline), &a)
+		if err != nil {
+			return attempt{}, 0, err
+		}
+		a.latency = since(attemptStart)
+		attempts = append(attempts, a)
+		if len(attempts) > rp.retries || !rp.retryable(&a) {
+			break
+		}
+		delay := rp.backoff(len(attempts))
+		if delay >= time.Until(deadline) {
+			stopped = fmt.Sprintf("no time left of %v for another attempt", timeout)
+			break
+		}
+		log.Debugf("Retrying probe in %v after attempt %d: %v", delay, len(attempts), a.result)
+		time.Sleep(delay)
+	}
+
+	last := attempts[len(attempts)-1]
+	if len(attempts) == 1 && stopped == "" {
+		return last, 1, nil
+	}
+	nodes := make([]string, len(attempts))
+	for i, a := range attempts {
+		nodes[i] = treeNode(a.result, a.latency, fmt.Sprintf("attempt %d", i+1), a.reason)
+	}
+	header := fmt.Sprintf("%s after %d of %d attempts (in %v)", last.result, len(attempts), rp.retries+1, since(start))
+	if stopped != "" {
+		header += ", " + stopped
+	}
+	last.reason = formatTree(header, nodes)
+	return last, len(attempts), nil
+}
+
+// isTimeoutReason returns true if the reason of a failure tells that the probe timed out.
+func isTimeoutReason(reason string) bool {
+	reason = strings.ToLower(reason)
+	return strings.Contains(reason, "timeout") || strings.Contains(reason, "deadline exceeded")
+}
+
+// isConnectionErrorReason returns true if the reason of a failure tells that the probe could not connect,
+// or that the connection was lost.
+func isConnectionErrorReason(reason string) bool {
+	for _, s := range []string{
+		"connection refused",
+		"connection reset",
+		"broken pipe",
+		"no route to host",
+		"network is unreachable",
+		"no such host",
+		"EOF",
+	} {
+		if strings.Contains(reason, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package probe
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "kmodules.xyz/prober/api"
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+	"stash.appscode.dev/prober-demo/pkg/probe/failure"
+)
+
+func TestRetryOnTimeout(t *testing.T) {
+	silent := listen(t, func(conn net.Conn) {
+		time.Sleep(5 * time.Second)
+		conn.Close()
+	})
+	defer silent.Close()
+	tests := []struct {
+		name              string
+		perAttemptTimeout *metav1.Duration
+		attempts          int
+	}{
+		{"shared timeout", nil, 3},
+		{"per attempt timeout", &metav1.Duration{Duration: 500 * time.Millisecond}, 2},
+	}
+	pb := NewProber(nil)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := tcpHandler(listenerPort(silent), &api_v1.TCPExchange{Send: `PING\r\n`, ExpectBytes: "PONG"})
+			p.Retries = 2
+			p.RetryOn = []string{retryOnTimeout}
+			p.RetryBackoff = &api_v1.RetryBackoff{Delay: &metav1.Duration{Duration: 10 * time.Millisecond}}
+			p.PerAttemptTimeout = test.perAttemptTimeout
+
+			start := time.Now()
+			report, err := pb.Run(&p, &core.Pod{}, core.PodStatus{}, core.Container{}, 900*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Result != api.Failure || report.Code != failure.Timeout {
+				t.Errorf("result = %s, code = %s, want failure, Timeout: %s", report.Result, report.Code, report.Reason)
+			}
+			if report.Attempts != test.attempts {
+				t.Errorf("attempts = %d, want %d: %s", report.Attempts, test.attempts, report.Reason)
+			}
+			if elapsed := time.Since(start); elapsed > 1200*time.Millisecond {
+				t.Errorf("probe took %v, past its timeout of 900ms", elapsed)
+			}
+		})
+	}
+}
+
+func TestRetryOnConnectionError(t *testing.T) {
+	loop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/", http.StatusFound)
+	}))
+	defer loop.Close()
+	tests := []struct {
+		name     string
+		handler  api_v1.Handler
+		attempts int
+	}{
+		{"refused", tcpHandler(closedPort(t), nil), 3},
+		{"redirect loop", httpHandler(t, loop.URL+"/"), 1},
+	}
+	pb := NewProber(nil)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := test.handler
+			p.Retries = 2
+			p.RetryOn = []string{retryOnConnectionError}
+			p.RetryBackoff = &api_v1.RetryBackoff{Delay: &metav1.Duration{Duration: 10 * time.Millisecond}}
+
+			report, err := pb.Run(&p, &core.Pod{}, core.PodStatus{}, core.Container{}, 2*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Attempts != test.attempts {
+				t.Errorf("attempts = %d, want %d: %s", report.Attempts, test.attempts, report.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package probe
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+	httpprobe "stash.appscode.dev/prober-demo/pkg/probe/http"
+
+	"github.com/appscode/go/log"
+	core "k8s.io/api/core/v1"
+	api "kmodules.xyz/prober/api"
+)
+
+// variableRef matches the references to variables in the steps of an HTTP scenario, e.g. ${token}.
+var variableRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+func (pb *Prober) runHTTPScenario(p *api_v1.Handler, pod *core.Pod, status core.PodStatus, container core.Container, timeout time.Duration) (api.Result, string, error) {
+	sc := p.HTTPScenario
+	assertions, err := validateScenario(sc)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	scheme := httpScheme(sc.Scheme)
+	host := probeHost(sc.Host, status)
+	port, err := extractPort(sc.Port, container)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	client, err := pb.httpClient(p, scheme, host, timeout)
+	if err != nil {
+		return api.Unknown, "", err
+	}
+	if client.Jar, err = cookiejar.New(nil); err != nil {
+		return api.Unknown, "", err
+	}
+	vars := map[string]string{}
+	// values of Variables and secret captures, to be kept out of the output.
+	var secrets []string
+	for i := range sc.Variables {
+		v := &sc.Variables[i]
+		value, err := pb.resolveValue(&v.ValueFrom, pod.Namespace)
+		if err != nil {
+			return api.Unknown, "", fmt.Errorf("failed to resolve variable %s: %v", v.Name, err)
+		}
+		vars[v.Name] = value
+		secrets = append(secrets, value)
+	}
+
+	start := time.Now()
+	deadline := start.Add(timeout)
+	result := api.Success
+	nodes := make([]string, len(sc.Steps))
+	failed := false
+	for i := range sc.Steps {
+		step := &sc.Steps[i]
+		name := stepName(step)
+		if failed {
+			nodes[i] = "skipped " + name
+			continue
+		}
+		stepStart := time.Now()
+		var stepResult api.Result
+		var reason string
+		if remaining := time.Until(deadline); remaining > 0 {
+			var captured []string
+			stepResult, reason, captured = runScenarioStep(client, remaining, scheme, host, port, step, assertions[i], vars)
+			secrets = append(secrets, captured...)
+		} else {
+			stepResult, reason = api.Failure, fmt.Sprintf("timeout: no time left of %v", timeout)
+		}
+		nodes[i] = treeNode(stepResult, since(stepStart), name, reason)
+		if severity[stepResult] > severity[result] {
+			result = stepResult
+		}
+		failed = severity[stepResult] >= severity[api.Unknown]
+	}
+	reason := formatTree(fmt.Sprintf("httpScenario: %s (in %v)", result, since(start)), nodes)
+	return result, redact(reason, secrets), nil
+}
+
+// runScenarioStep sends the request of step, checks the response with assertions, parsed from those of step, and captures its variables into vars.
+// It returns the values of the secret captures along with the result of the step.
+func runScenarioStep(client *http.Client, timeout time.Duration, scheme string, host string, port int, step *api_v1.HTTPScenarioStep, assertions *httpprobe.Assertions, vars map[string]string) (api.Result, string, []string) {
+	path, err := substitutePath(step.Path, vars)
+	if err != nil {
+		return api.Unknown, "path: " + err.Error(), nil
+	}
+	headers := make(http.Header)
+	for _, h := range step.HTTPHeaders {
+		value, err := substitute(h.Value, vars, nil)
+		if err != nil {
+			return api.Unknown, fmt.Sprintf("header %s: %v", h.Name, err), nil
+		}
+		headers.Add(h.Name, value)
+	}
+	body, err := substitute(step.Body, vars, nil)
+	if err != nil {
+		return api.Unknown, "body: " + err.Error(), nil
+	}
+	method := strings.ToUpper(step.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	targetURL := formatURL(scheme, host, port, path)
+	log.Debugf("HTTP-Scenario-Probe Step: %v, Method: %v, URL: %v", stepName(step), method, targetURL)
+	rec := httpprobe.NewRecorder(client)
+	rec.Client.Timeout = timeout
+	result, reason, err := httpprobe.DoHTTPProbe(method, targetURL, headers, rec, nil, body)
+	if err != nil {
+		return api.Failure, err.Error(), nil
+	}
+	res := rec.Response()
+	result, reason = assertions.Check(res, nil, result, reason)
+	if result == api.Failure || res == nil {
+		return result, reason, nil
+	}
+
+	reason = fmt.Sprintf("status %d", res.StatusCode)
+	var names, secrets []string
+	for _, c := range step.Capture {
+		value, err := httpprobe.Capture(res, c.Header, c.JSONPath)
+		if err != nil {
+			return api.Failure, fmt.Sprintf("failed to capture %s: %v", c.Name, err), nil
+		}
+		vars[c.Name] = value
+		names = append(names, c.Name)
+		if c.Secret {
+			secrets = append(secrets, value)
+		}
+	}
+	if len(names) > 0 {
+		reason += ", captured " + strings.Join(names, ", ")
+	}
+	if redirects := rec.Redirects(); len(redirects) > 0 {
+		reason += fmt.Sprintf(" (redirects: %s)", strings.Join(redirects, " -> "))
+	}
+	return result, reason, secrets
+}
+
+// validateScenario checks the parts of an HTTP scenario that do not depend on the responses,
+// and returns the parsed assertions of every step.
+func validateScenario(sc *api_v1.HTTPScenarioAction) ([]*httpprobe.Assertions, error) {
+	if len(sc.Steps) == 0 {
+		return nil, fmt.Errorf("http scenario has no steps")
+	}
+	assertions := make([]*httpprobe.Assertions, len(sc.Steps))
+	for i := range sc.Steps {
+		for _, c := range sc.Steps[i].Capture {
+			if c.Name == "" {
+				return nil, fmt.Errorf("step %s captures a variable without name", stepName(&sc.Steps[i]))
+			}
+			if (c.Header == "") == (c.JSONPath == "") {
+				return nil, fmt.Errorf("step %s captures %s from none or both of header and jsonPath, one is required", stepName(&sc.Steps[i]), c.Name)
+			}
+		}
+		a, err := httpprobe.ParseAssertions(sc.Steps[i].Assertions)
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %v", stepName(&sc.Steps[i]), err)
+		}
+		assertions[i] = a
+	}
+	return assertions, nil
+}
+
+// stepName returns the name of a step, or its method and path if it has none.
+func stepName(step *api_v1.HTTPScenarioStep) string {
+	if step.Name != "" {
+		return step.Name
+	}
+	method := strings.ToUpper(step.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	if step.Path == "" {
+		return method + " /"
+	}
+	return method + " " + step.Path
+}
+
+// substitute replaces the references to variables in s with their values, escaped by escape if it is set.
+func substitute(s string, vars map[string]string, escape func(string) string) (string, error) {
+	var err error
+	out := variableRef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		value, ok := vars[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("undefined variable %s", name)
+		}
+		if escape != nil {
+			value = escape(value)
+		}
+		return value
+	})
+	return out, err
+}
+
+// substitutePath replaces the references to variables in path with their values,
+// escaped as a path segment before the query and as a query value in it.
+func substitutePath(path string, vars map[string]string) (string, error) {
+	query := ""
+	if i := strings.Index(path, "?"); i >= 0 {
+		path, query = path[:i], path[i:]
+	}
+	path, err := substitute(path, vars, url.PathEscape)
+	if err != nil {
+		return "", err
+	}
+	query, err = substitute(query, vars, url.QueryEscape)
+	if err != nil {
+		return "", err
+	}
+	return path + query, nil
+}
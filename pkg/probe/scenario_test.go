@@ -0,0 +1,218 @@
+package probe
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	api "kmodules.xyz/prober/api"
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+)
+
+func TestSubstitutePath(t *testing.T) {
+	vars := map[string]string{"id": "a/b?c&d#e", "name": "x y"}
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"path segment", "/items/${id}", "/items/a%2Fb%3Fc&d%23e"},
+		{"query value", "/search?q=${id}&n=${name}", "/search?q=a%2Fb%3Fc%26d%23e&n=x+y"},
+		{"path and query", "/items/${name}?id=${id}", "/items/x%20y?id=a%2Fb%3Fc%26d%23e"},
+		{"no variables", "/items?q=1", "/items?q=1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := substitutePath(test.path, vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("substitutePath(%q) = %q, want %q", test.path, got, test.want)
+			}
+		})
+	}
+
+	if _, err := substitutePath("/items/${missing}", vars); err == nil {
+		t.Error("substitutePath() of an undefined variable succeeded")
+	}
+}
+
+// TestScenarioEscapesCapturedValues checks that a captured value with reserved characters
+// reaches the server as one path segment and one query value.
+func TestScenarioEscapesCapturedValues(t *testing.T) {
+	const id = "a/b?c&d#e"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/new":
+			json.NewEncoder(w).Encode(map[string]string{"id": id})
+		case r.URL.EscapedPath() == "/items/a%2Fb%3Fc&d%23e" && r.URL.Query().Get("id") == id:
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.Error(w, r.URL.String(), http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	handler := api_v1.Handler{HTTPScenario: &api_v1.HTTPScenarioAction{
+		Port: httpHandler(t, srv.URL+"/").HTTPGet.Port,
+		Host: "127.0.0.1",
+		Steps: []api_v1.HTTPScenarioStep{
+			{Path: "/new", Capture: []api_v1.HTTPCapture{{Name: "id", JSONPath: "$.id"}}},
+			{Path: "/items/${id}?id=${id}"},
+		},
+	}}
+	report, err := NewProber(nil).Run(&handler, &core.Pod{}, core.PodStatus{}, core.Container{}, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Result != api.Success {
+		t.Errorf("result = %s, want success: %s", report.Result, report.Reason)
+	}
+}
+
+func TestValidateScenario(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []api_v1.HTTPScenarioStep
+		err   string
+	}{
+		{"valid", []api_v1.HTTPScenarioStep{
+			{Path: "/login", Capture: []api_v1.HTTPCapture{{Name: "token", Header: "X-Token"}, {Name: "id", JSONPath: "$.id"}}},
+			{Path: "/items/${id}", Assertions: &api_v1.HTTPAssertions{BodyRegex: `^\{`}},
+		}, ""},
+		{"no steps", nil, "http scenario has no steps"},
+		{"capture without name", []api_v1.HTTPScenarioStep{
+			{Name: "login", Capture: []api_v1.HTTPCapture{{Header: "X-Token"}}},
+		}, "step login captures a variable without name"},
+		{"capture from both", []api_v1.HTTPScenarioStep{
+			{Path: "/login", Capture: []api_v1.HTTPCapture{{Name: "token", Header: "X-Token", JSONPath: "$.token"}}},
+		}, "step GET /login captures token from none or both"},
+		{"capture from none", []api_v1.HTTPScenarioStep{
+			{Method: "post", Capture: []api_v1.HTTPCapture{{Name: "token"}}},
+		}, "step POST / captures token from none or both"},
+		{"invalid body regex", []api_v1.HTTPScenarioStep{
+			{Path: "/"}, {Name: "check", Assertions: &api_v1.HTTPAssertions{BodyRegex: "("}},
+		}, "step check: invalid body regex"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assertions, err := validateScenario(&api_v1.HTTPScenarioAction{Steps: test.steps})
+			if test.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(assertions) != len(test.steps) || assertions[0] != nil || assertions[1] == nil {
+					t.Errorf("assertions = %v, want one for the second step only", assertions)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+				t.Errorf("validateScenario() = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	vars := map[string]string{"token": "a b", "id": "42"}
+	got, err := substitute("Bearer ${token} ${id}${id} $id ${ id}", vars, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Bearer a b 4242 $id ${ id}"; got != want {
+		t.Errorf("substitute() = %q, want %q", got, want)
+	}
+	if _, err := substitute("${token} ${missing}", vars, nil); err == nil || err.Error() != "undefined variable missing" {
+		t.Errorf("substitute() of an undefined variable = %v, want an error naming it", err)
+	}
+}
+
+// TestRunHTTPScenario runs a login, then a request that needs its cookie and its captured values.
+func TestRunHTTPScenario(t *testing.T) {
+	const token = "s3cr3t-demo-token-0123"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/login" && r.Method == http.MethodPost:
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+			w.Header().Set("X-Token", token)
+			json.NewEncoder(w).Encode(map[string]interface{}{"user": map[string]string{"id": "42"}})
+		case r.URL.Path == "/users/42":
+			if c, err := r.Cookie("session"); err != nil || c.Value != "1" || r.Header.Get("Authorization") != "Bearer "+token {
+				http.Error(w, "unauthorized "+r.Header.Get("Authorization"), http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"name":"demo"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	port := httpHandler(t, srv.URL+"/").HTTPGet.Port
+
+	login := api_v1.HTTPScenarioStep{
+		Name:   "login",
+		Method: "POST",
+		Path:   "/login",
+		Capture: []api_v1.HTTPCapture{
+			{Name: "token", Header: "X-Token", Secret: true},
+			{Name: "id", JSONPath: "$.user.id"},
+		},
+	}
+	profile := api_v1.HTTPScenarioStep{
+		Name:        "profile",
+		Path:        "/users/${id}",
+		HTTPHeaders: []core.HTTPHeader{{Name: "Authorization", Value: "Bearer ${token}"}},
+		Assertions:  &api_v1.HTTPAssertions{JSONPath: []api_v1.JSONPathAssertion{{Path: "$.name", Value: "demo"}}},
+	}
+	wrongName := profile
+	wrongName.Assertions = &api_v1.HTTPAssertions{BodyContains: "admin"}
+	undefined := profile
+	undefined.Path = "/users/${user}"
+	anonymous := api_v1.HTTPScenarioStep{Name: "anonymous", Path: "/users/42"}
+
+	tests := []struct {
+		name  string
+		steps []api_v1.HTTPScenarioStep
+		want  api.Result
+		lines []string
+	}{
+		{"logged in", []api_v1.HTTPScenarioStep{login, profile}, api.Success, []string{
+			"httpScenario: success", "|-- success", "login: status 200, captured token, id", "`-- success", "profile: status 200",
+		}},
+		{"not logged in", []api_v1.HTTPScenarioStep{anonymous, login}, api.Failure, []string{
+			"httpScenario: failure", "|-- failure", "anonymous: HTTP probe failed with statuscode: 401", "`-- skipped login",
+		}},
+		{"assertion failed", []api_v1.HTTPScenarioStep{login, wrongName, login}, api.Failure, []string{
+			"|-- success", "|-- failure", `profile: expected body to contain "admin"`, "`-- skipped login",
+		}},
+		{"undefined variable", []api_v1.HTTPScenarioStep{login, undefined, login}, api.Unknown, []string{
+			"httpScenario: unknown", "profile: path: undefined variable user", "`-- skipped login",
+		}},
+	}
+	pb := NewProber(nil)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := api_v1.Handler{HTTPScenario: &api_v1.HTTPScenarioAction{Port: port, Host: "127.0.0.1", Steps: test.steps}}
+			report, err := pb.Run(&handler, &core.Pod{}, core.PodStatus{}, core.Container{}, 2*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Result != test.want {
+				t.Errorf("result = %s, want %s:\n%s", report.Result, test.want, report.Reason)
+			}
+			for _, line := range test.lines {
+				if !strings.Contains(report.Reason, line) {
+					t.Errorf("reason does not contain %q:\n%s", line, report.Reason)
+				}
+			}
+			if strings.Contains(report.Reason, token) {
+				t.Errorf("reason shows the captured secret:\n%s", report.Reason)
+			}
+		})
+	}
+}
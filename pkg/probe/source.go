@@ -0,0 +1,208 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+	httpprobe "stash.appscode.dev/prober-demo/pkg/probe/http"
+
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// redacted replaces the values read from Secrets in the output of a probe.
+	redacted = "<redacted>"
+
+	defaultServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+var errNoKubeClient = errors.New("no Kubernetes client configured to read ConfigMaps and Secrets")
+
+// resolveValue returns the value selected by src, reading Secrets from the given namespace.
+func (pb *Prober) resolveValue(src *api_v1.ValueSource, namespace string) (string, error) {
+	switch {
+	case src.SecretKeyRef != nil:
+		return pb.secretValue(namespace, src.SecretKeyRef)
+	case src.ServiceAccountToken != nil:
+		path := src.ServiceAccountToken.Path
+		if path == "" {
+			path = defaultServiceAccountTokenPath
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read ServiceAccount token: %v", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case src.Env != "":
+		value, ok := os.LookupEnv(src.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", src.Env)
+		}
+		return value, nil
+	}
+	return "", errors.New("value source has no secretKeyRef, serviceAccountToken or env")
+}
+
+// secretValue returns the value of the key selected by ref in the given namespace.
+// A missing optional Secret or key results in an empty value.
+func (pb *Prober) secretValue(namespace string, ref *core.SecretKeySelector) (string, error) {
+	if pb.KubeClient == nil {
+		return "", errNoKubeClient
+	}
+	optional := ref.Optional != nil && *ref.Optional
+	secret, err := pb.KubeClient.CoreV1().Secrets(namespace).Get(ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if optional && kerr.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	data, ok := secret.Data[ref.Key]
+	if !ok && !optional {
+		return "", fmt.Errorf("key %s not found in secret %s/%s", ref.Key, namespace, ref.Name)
+	}
+	return string(data), nil
+}
+
+// configMapValue returns the value of the key selected by ref in the given namespace.
+// A missing optional ConfigMap or key results in an empty value.
+func (pb *Prober) configMapValue(namespace string, ref *core.ConfigMapKeySelector) (string, error) {
+	if pb.KubeClient == nil {
+		return "", errNoKubeClient
+	}
+	optional := ref.Optional != nil && *ref.Optional
+	cm, err := pb.KubeClient.CoreV1().ConfigMaps(namespace).Get(ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if optional && kerr.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if data, ok := cm.Data[ref.Key]; ok {
+		return data, nil
+	}
+	if data, ok := cm.BinaryData[ref.Key]; ok {
+		return string(data), nil
+	}
+	if !optional {
+		return "", fmt.Errorf("key %s not found in configmap %s/%s", ref.Key, namespace, ref.Name)
+	}
+	return "", nil
+}
+
+// redactHeader returns a copy of header with the secret values redacted, for logging.
+func redactHeader(header http.Header, secrets []string) http.Header {
+	out := make(http.Header, len(header))
+	for name, values := range header {
+		for _, v := range values {
+			out[name] = append(out[name], redact(v, secrets))
+		}
+	}
+	return out
+}
+
+// redact replaces every occurrence of the secret values in s, in any of the forms of secretForms.
+// It also replaces the start of a secret cut off where a probe truncated a value, marked by httpprobe.TruncatedMark,
+// as the reasons of probes show at most the first bytes of a body.
+func redact(s string, secrets []string) string {
+	var forms []string
+	for _, secret := range secrets {
+		if secret != "" {
+			forms = append(forms, secretForms(secret)...)
+		}
+	}
+	// the longest first, so that no part of a longer form is left over.
+	sort.Slice(forms, func(i, j int) bool { return len(forms[i]) > len(forms[j]) })
+	for _, form := range forms {
+		s = strings.Replace(s, form, redacted, -1)
+	}
+	return redactCutPrefixes(s, forms)
+}
+
+// secretForms returns secret as is, and as it shows once escaped in a JSON string, e.g. a response body,
+// in a URL query or form, and by %q or %+q of those.
+func secretForms(secret string) []string {
+	forms := []string{secret, url.QueryEscape(secret)}
+	if data, err := json.Marshal(secret); err == nil {
+		forms = append(forms, unquote(string(data)))
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(secret); err == nil {
+		forms = append(forms, unquote(strings.TrimSuffix(buf.String(), "\n")))
+	}
+	unquoted := forms
+	for _, form := range unquoted {
+		forms = append(forms, unquote(strconv.Quote(form)), unquote(strconv.QuoteToASCII(form)))
+	}
+
+	unique := forms[:0]
+	seen := map[string]bool{}
+	for _, form := range forms {
+		if !seen[form] {
+			seen[form] = true
+			unique = append(unique, form)
+		}
+	}
+	return unique
+}
+
+func unquote(s string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(s, `"`), `"`)
+}
+
+// minRedactedPrefix is the length of the shortest start of a cut off secret that is redacted.
+// Shorter ones are too likely to be something else, e.g. "http" or "Bearer".
+const minRedactedPrefix = 8
+
+// redactCutPrefixes replaces the longest start of any of forms that is followed by httpprobe.TruncatedMark.
+func redactCutPrefixes(s string, forms []string) string {
+	var out strings.Builder
+	for i := 0; i < len(s); {
+		n := 0
+		for _, form := range forms {
+			if m := cutPrefixLength(s[i:], form); m > n {
+				n = m
+			}
+		}
+		if n > 0 {
+			out.WriteString(redacted)
+			i += n
+			continue
+		}
+		out.WriteByte(s[i])
+		i++
+	}
+	return out.String()
+}
+
+// cutPrefixLength returns the length of the longest start of form, shorter than form, that s starts with
+// and that is followed by httpprobe.TruncatedMark. It returns 0 if there is none.
+func cutPrefixLength(s, form string) int {
+	if len(form) <= minRedactedPrefix || !strings.HasPrefix(s, form[:minRedactedPrefix]) {
+		return 0
+	}
+	n := minRedactedPrefix
+	for n < len(s) && n < len(form)-1 && s[n] == form[n] {
+		n++
+	}
+	for ; n >= minRedactedPrefix; n-- {
+		if strings.HasPrefix(s[n:], httpprobe.TruncatedMark) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package tcp
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Expectation is what a probe waits for in the bytes it receives.
+// Regex is checked if it is set, Bytes otherwise. An empty Expectation matches anything.
+type Expectation struct {
+	Regex *regexp.Regexp
+	Bytes []byte
+}
+
+// Matches returns whether received meets the expectation.
+func (e *Expectation) Matches(received []byte) bool {
+	switch {
+	case e == nil:
+		return true
+	case e.Regex != nil:
+		return e.Regex.Match(received)
+	default:
+		return bytes.Contains(received, e.Bytes)
+	}
+}
+
+func (e *Expectation) String() string {
+	switch {
+	case e == nil:
+		return "anything"
+	case e.Regex != nil:
+		return fmt.Sprintf("a match of %q", e.Regex.String())
+	default:
+		return fmt.Sprintf("%q", e.Bytes)
+	}
+}
+
+// Unescape decodes the escape sequences \r, \n, \t, \0, \\ and \xHH of s.
+// Other backslashes are kept as they are.
+func Unescape(s string) ([]byte, error) {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i == len(s)-1 {
+			out = append(out, c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'r':
+			out = append(out, '\r')
+		case 'n':
+			out = append(out, '\n')
+		case 't':
+			out = append(out, '\t')
+		case '0':
+			out = append(out, 0)
+		case '\\':
+			out = append(out, '\\')
+		case 'x':
+			if i+2 >= len(s) {
+				return nil, fmt.Errorf("invalid escape sequence %q at offset %d", s[i-1:], i-1)
+			}
+			b, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid escape sequence %q at offset %d", s[i-1:i+3], i-1)
+			}
+			out = append(out, byte(b))
+			i += 2
+		default:
+			out = append(out, '\\', s[i])
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,105 @@
+package tcp
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"stash.appscode.dev/prober-demo/pkg/probe/failure"
+
+	api "kmodules.xyz/prober/api"
+
+	"github.com/appscode/go/log"
+)
+
+const (
+	// maxReceived is the number of bytes a probe reads before giving up on its expectation.
+	maxReceived = 10 * 1 << 10 // 10KB
+)
+
+// New creates Prober.
+func New() Prober {
+	return tcpProber{}
+}
+
+// Prober is an interface that defines the Probe function for doing TCP send/expect checks.
+// Besides the result of the check, Probe returns the class of its failure, if it failed.
+type Prober interface {
+	Probe(host string, port int, send []byte, expect *Expectation, readTimeout time.Duration, timeout time.Duration) (api.Result, string, failure.Code, error)
+}
+
+type tcpProber struct{}
+
+// Probe returns a ProbeRunner capable of running a TCP send/expect check.
+func (pr tcpProber) Probe(host string, port int, send []byte, expect *Expectation, readTimeout time.Duration, timeout time.Duration) (api.Result, string, failure.Code, error) {
+	return exchange(net.JoinHostPort(host, strconv.Itoa(port)), send, expect, readTimeout, timeout)
+}
+
+// DoTCPExchangeProbe opens a TCP socket to addr, writes send to it and reads until expect matches the received bytes.
+// If expect is nil, nothing is read. Reading stops after readTimeout or timeout, or once 10KB are received.
+// If the socket can't be opened, or the expectation is not met, it returns Failure with the received bytes.
+// Otherwise, it returns Success.
+func DoTCPExchangeProbe(addr string, send []byte, expect *Expectation, readTimeout time.Duration, timeout time.Duration) (api.Result, string, error) {
+	result, reason, _, err := exchange(addr, send, expect, readTimeout, timeout)
+	return result, reason, err
+}
+
+func exchange(addr string, send []byte, expect *Expectation, readTimeout time.Duration, timeout time.Duration) (api.Result, string, failure.Code, error) {
+	deadline := time.Now().Add(timeout)
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		// Convert errors to failures to handle timeouts.
+		return api.Failure, err.Error(), failure.Classify(err), nil
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Errorf("Unexpected error closing TCP probe socket: %v (%#v)", err, err)
+		}
+	}()
+
+	if err = conn.SetDeadline(deadline); err != nil {
+		return api.Failure, err.Error(), failure.Classify(err), nil
+	}
+	if len(send) > 0 {
+		if _, err = conn.Write(send); err != nil {
+			return api.Failure, fmt.Sprintf("failed to send %q: %v", send, err), failure.Classify(err), nil
+		}
+	}
+	if expect == nil {
+		return api.Success, "", "", nil
+	}
+
+	// the read timeout can't extend the probe timeout.
+	if readDeadline := time.Now().Add(readTimeout); readDeadline.Before(deadline) {
+		if err = conn.SetReadDeadline(readDeadline); err != nil {
+			return api.Failure, err.Error(), failure.Classify(err), nil
+		}
+	}
+	received := make([]byte, 0, 512)
+	buf := make([]byte, 512)
+	for len(received) < maxReceived {
+		var n int
+		n, err = conn.Read(buf)
+		received = append(received, buf[:n]...)
+		if expect.Matches(received) {
+			return api.Success, fmt.Sprintf("received %q", received), "", nil
+		}
+		if err != nil {
+			break
+		}
+	}
+	// the peer sent enough bytes, none matching.
+	code := failure.AssertionFailed
+	switch {
+	case err == nil:
+		err = fmt.Errorf("read %d bytes", len(received))
+	case err == io.EOF:
+		code = failure.ConnectionClosed
+		err = fmt.Errorf("connection closed by peer")
+	default:
+		code = failure.Classify(err)
+	}
+	return api.Failure, fmt.Sprintf("expected %v, received %q: %v", expect, received, err), code, nil
+}
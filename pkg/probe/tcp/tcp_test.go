@@ -0,0 +1,146 @@
+package tcp
+
+import (
+	"bytes"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"stash.appscode.dev/prober-demo/pkg/probe/failure"
+
+	api "kmodules.xyz/prober/api"
+)
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []byte
+		wantErr bool
+	}{
+		{`PING\r\n`, []byte("PING\r\n"), false},
+		{`a\tb\0c`, []byte("a\tb\x00c"), false},
+		{`\x00\xffz`, []byte{0, 0xff, 'z'}, false},
+		{`back\\slash`, []byte(`back\slash`), false},
+		{`kept \d and trailing \`, []byte(`kept \d and trailing \`), false},
+		{`\xz1`, nil, true},
+		{`short \x1`, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			got, err := Unescape(test.in)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("Unescape(%q) = %q, want an error", test.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, test.want) {
+				t.Errorf("Unescape(%q) = %q, want %q", test.in, got, test.want)
+			}
+		})
+	}
+}
+
+func TestExpectationMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		expect   *Expectation
+		received string
+		want     bool
+	}{
+		{"nil", nil, "", true},
+		{"bytes", &Expectation{Bytes: []byte("+PONG")}, "+PONG\r\n", true},
+		{"bytes missing", &Expectation{Bytes: []byte("+PONG")}, "-ERR\r\n", false},
+		{"regex", &Expectation{Regex: regexp.MustCompile(`^\+PO\w+`)}, "+PONG\r\n", true},
+		{"regex over bytes", &Expectation{Regex: regexp.MustCompile(`^-ERR`), Bytes: []byte("+PONG")}, "+PONG\r\n", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.expect.Matches([]byte(test.received)); got != test.want {
+				t.Errorf("%v matches %q = %v, want %v", test.expect, test.received, got, test.want)
+			}
+		})
+	}
+}
+
+// listen replies to the first read of each connection with reply, and closes it if hangUp is set.
+func listen(t *testing.T, reply string, hangUp bool) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 512)
+				if _, err := conn.Read(buf); err != nil {
+					return
+				}
+				conn.Write([]byte(reply))
+				if !hangUp {
+					// keep the connection open until the probe closes it.
+					conn.Read(buf)
+				}
+			}()
+		}
+	}()
+	return l
+}
+
+func TestExchange(t *testing.T) {
+	pong := listen(t, "+PONG\r\n", false)
+	defer pong.Close()
+	closing := listen(t, "-ERR\r\n", true)
+	defer closing.Close()
+
+	tests := []struct {
+		name   string
+		addr   string
+		expect *Expectation
+		want   api.Result
+		code   failure.Code
+	}{
+		{"no expectation", pong.Addr().String(), nil, api.Success, ""},
+		{"bytes", pong.Addr().String(), &Expectation{Bytes: []byte("PONG\r\n")}, api.Success, ""},
+		{"regex", pong.Addr().String(), &Expectation{Regex: regexp.MustCompile(`^\+\w+`)}, api.Success, ""},
+		{"read timeout", pong.Addr().String(), &Expectation{Bytes: []byte("OK")}, api.Failure, failure.Timeout},
+		{"closed by peer", closing.Addr().String(), &Expectation{Bytes: []byte("OK")}, api.Failure, failure.ConnectionClosed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, reason, code, err := exchange(test.addr, []byte("PING\r\n"), test.expect, 100*time.Millisecond, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != test.want || code != test.code {
+				t.Errorf("result = %s (%q), want %s (%q): %s", result, code, test.want, test.code, reason)
+			}
+		})
+	}
+}
+
+func TestExchangeClosedPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	result, reason, code, err := exchange(addr, nil, nil, time.Second, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != api.Failure || code != failure.ConnectionRefused {
+		t.Errorf("result = %s (%q), want %s (%q): %s", result, code, api.Failure, failure.ConnectionRefused, reason)
+	}
+}
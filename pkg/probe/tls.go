@@ -0,0 +1,133 @@
+package probe
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	api_v1 "stash.appscode.dev/prober-demo/pkg/api/v1"
+
+	"k8s.io/client-go/util/cert"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// mergeTLSConfig returns the TLS options of a probe, with the unset fields taken from def.
+func mergeTLSConfig(opts, def *api_v1.TLSConfig) *api_v1.TLSConfig {
+	if opts == nil {
+		return def
+	}
+	if def == nil {
+		return opts
+	}
+	out := *opts
+	if out.CAFile == "" {
+		out.CAFile = def.CAFile
+	}
+	if out.CertFile == "" && out.KeyFile == "" {
+		out.CertFile, out.KeyFile = def.CertFile, def.KeyFile
+	}
+	if out.ServerName == "" {
+		out.ServerName = def.ServerName
+	}
+	if out.MinVersion == "" {
+		out.MinVersion = def.MinVersion
+	}
+	return &out
+}
+
+// newTLSConfig builds the tls.Config used to probe host with the given options.
+func newTLSConfig(opts *api_v1.TLSConfig, host string) (*tls.Config, error) {
+	serverName := opts.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+
+	roots, err := rootCAs(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		ServerName: serverName,
+		// The chain is verified by verifyPeerCertificate instead, so that
+		// a failure names the certificate that could not be verified.
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: verifyPeerCertificate(roots, serverName),
+	}
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		pair, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	if opts.MinVersion != "" {
+		version, ok := tlsVersions[opts.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported minimum TLS version %q", opts.MinVersion)
+		}
+		config.MinVersion = version
+	}
+	return config, nil
+}
+
+// rootCAs returns the CA bundle of opts, or nil for the system roots if it has none.
+func rootCAs(opts *api_v1.TLSConfig) (*x509.CertPool, error) {
+	if opts.CAFile == "" {
+		return nil, nil
+	}
+	return cert.NewPool(opts.CAFile)
+}
+
+func verifyPeerCertificate(roots *x509.CertPool, serverName string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			c, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("failed to parse server certificate: %v", err)
+			}
+			certs = append(certs, c)
+		}
+		if len(certs) == 0 {
+			return errors.New("server presented no certificate")
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, c := range certs[1:] {
+			intermediates.AddCert(c)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			DNSName:       serverName,
+		})
+		if err != nil {
+			return &certificateError{subject: certs[0].Subject.String(), issuer: certs[0].Issuer.String(), err: err}
+		}
+		return nil
+	}
+}
+
+// certificateError is the error of a server certificate that failed verification.
+// It keeps the error of crypto/x509, so that the failure can be classified.
+type certificateError struct {
+	subject string
+	issuer  string
+	err     error
+}
+
+func (e *certificateError) Error() string {
+	return fmt.Sprintf("certificate verification failed for subject %q issued by %q: %v", e.subject, e.issuer, e.err)
+}
+
+func (e *certificateError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,95 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	api "kmodules.xyz/prober/api"
+
+	"github.com/appscode/go/log"
+)
+
+// New creates Prober.
+func New() Prober {
+	return tlsCertProber{}
+}
+
+// Prober is an interface that defines the Probe function for checking the certificates served on a TLS port.
+type Prober interface {
+	Probe(host string, port int, config *tls.Config, roots *x509.CertPool, warnBefore time.Duration, timeout time.Duration) (api.Result, string, error)
+}
+
+type tlsCertProber struct{}
+
+// Probe returns a ProbeRunner capable of running a TLS certificate check.
+func (pr tlsCertProber) Probe(host string, port int, config *tls.Config, roots *x509.CertPool, warnBefore time.Duration, timeout time.Duration) (api.Result, string, error) {
+	return DoTLSCertProbe(net.JoinHostPort(host, strconv.Itoa(port)), config, roots, warnBefore, timeout)
+}
+
+// DoTLSCertProbe performs a TLS handshake with addr and checks the certificates it serves.
+// If a certificate of the chain is expired, or the leaf is not valid for config.ServerName, it returns Failure.
+// If roots is set and does not verify the chain, it returns Failure.
+// If a certificate of the chain expires within warnBefore, it returns Warning.
+// Otherwise it returns Success. The reason always describes the offending or the leaf certificate.
+func DoTLSCertProbe(addr string, config *tls.Config, roots *x509.CertPool, warnBefore time.Duration, timeout time.Duration) (api.Result, string, error) {
+	// The checks are done below, so that an invalid certificate
+	// is reported with its details instead of a handshake error.
+	config = config.Clone()
+	config.InsecureSkipVerify = true
+	config.VerifyPeerCertificate = nil
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, config)
+	if err != nil {
+		// Convert errors to failures to handle timeouts.
+		return api.Failure, err.Error(), nil
+	}
+	certs := conn.ConnectionState().PeerCertificates
+	if err = conn.Close(); err != nil {
+		log.Errorf("Unexpected error closing TLS probe socket: %v (%#v)", err, err)
+	}
+	if len(certs) == 0 {
+		return api.Failure, "server presented no certificate", nil
+	}
+
+	now := time.Now()
+	for _, c := range certs {
+		if now.After(c.NotAfter) {
+			return api.Failure, "certificate has expired: " + describe(c), nil
+		}
+		if now.Before(c.NotBefore) {
+			return api.Failure, "certificate is not yet valid: " + describe(c), nil
+		}
+	}
+	leaf := certs[0]
+	if err = leaf.VerifyHostname(config.ServerName); err != nil {
+		return api.Failure, fmt.Sprintf("%v: %s", err, describe(leaf)), nil
+	}
+	if roots != nil {
+		intermediates := x509.NewCertPool()
+		for _, c := range certs[1:] {
+			intermediates.AddCert(c)
+		}
+		_, err = leaf.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			DNSName:       config.ServerName,
+		})
+		if err != nil {
+			return api.Failure, fmt.Sprintf("%v: %s", err, describe(leaf)), nil
+		}
+	}
+	for _, c := range certs {
+		if c.NotAfter.Sub(now) < warnBefore {
+			return api.Warning, fmt.Sprintf("certificate expires in %v: %s", c.NotAfter.Sub(now).Round(time.Minute), describe(c)), nil
+		}
+	}
+	return api.Success, describe(leaf), nil
+}
+
+func describe(c *x509.Certificate) string {
+	return fmt.Sprintf("subject=%q issuer=%q notAfter=%s", c.Subject, c.Issuer, c.NotAfter.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,53 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	api "kmodules.xyz/prober/api"
+)
+
+func TestDoTLSCertProbe(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// the probe closes the connection once the handshake is done.
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "https://")
+	trusted := x509.NewCertPool()
+	trusted.AddCert(srv.Certificate())
+
+	tests := []struct {
+		name       string
+		serverName string
+		roots      *x509.CertPool
+		warnBefore time.Duration
+		want       api.Result
+	}{
+		{"no roots", "127.0.0.1", nil, 0, api.Success},
+		{"trusted", "example.com", trusted, 0, api.Success},
+		{"untrusted", "127.0.0.1", x509.NewCertPool(), 0, api.Failure},
+		{"wrong name", "prober-demo.test", nil, 0, api.Failure},
+		// the certificate of httptest expires in 2084.
+		{"expires soon", "127.0.0.1", trusted, 100 * 365 * 24 * time.Hour, api.Warning},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &tls.Config{ServerName: test.serverName}
+			result, reason, err := DoTLSCertProbe(addr, config, test.roots, test.warnBefore, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != test.want {
+				t.Errorf("result = %s, want %s: %s", result, test.want, reason)
+			}
+		})
+	}
+}
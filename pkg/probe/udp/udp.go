@@ -0,0 +1,109 @@
+package udp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	tcpprobe "stash.appscode.dev/prober-demo/pkg/probe/tcp"
+
+	api "kmodules.xyz/prober/api"
+
+	"github.com/appscode/go/log"
+)
+
+const (
+	// maxDatagram is the largest reply a probe reads.
+	maxDatagram = 64 * 1 << 10 // 64KB
+	// unreachableWait is how long a probe that expects no reply waits for an ICMP error.
+	unreachableWait = 500 * time.Millisecond
+)
+
+// New creates Prober.
+func New() Prober {
+	return udpProber{}
+}
+
+// Prober is an interface that defines the Probe function for doing UDP checks.
+type Prober interface {
+	Probe(host string, port int, send []byte, expect *tcpprobe.Expectation, timeout time.Duration) (api.Result, string, error)
+}
+
+type udpProber struct{}
+
+// Probe returns a ProbeRunner capable of running a UDP check.
+func (pr udpProber) Probe(host string, port int, send []byte, expect *tcpprobe.Expectation, timeout time.Duration) (api.Result, string, error) {
+	return DoUDPProbe(net.JoinHostPort(host, strconv.Itoa(port)), send, expect, timeout)
+}
+
+// DoUDPProbe sends the datagram send to addr. If expect is set, it waits for a reply that matches it until timeout.
+// Otherwise it only waits a moment for the ICMP port unreachable error that a closed port answers with.
+// If the port is unreachable or no matching reply is received, it returns Failure. Otherwise, it returns Success.
+func DoUDPProbe(addr string, send []byte, expect *tcpprobe.Expectation, timeout time.Duration) (api.Result, string, error) {
+	deadline := time.Now().Add(timeout)
+	// a connected socket is told about the ICMP errors of its peer.
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		// Convert errors to failures to handle timeouts.
+		return api.Failure, err.Error(), nil
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Errorf("Unexpected error closing UDP probe socket: %v (%#v)", err, err)
+		}
+	}()
+
+	if _, err = conn.Write(send); err != nil {
+		return api.Failure, describe(err), nil
+	}
+	if expect == nil {
+		if wait := time.Now().Add(unreachableWait); wait.Before(deadline) {
+			deadline = wait
+		}
+	}
+	if err = conn.SetReadDeadline(deadline); err != nil {
+		return api.Failure, err.Error(), nil
+	}
+
+	buf := make([]byte, maxDatagram)
+	var replies []string
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if expect == nil {
+				if isTimeout(err) {
+					// no error came back, the datagram is assumed to be delivered.
+					return api.Success, "", nil
+				}
+				return api.Failure, describe(err), nil
+			}
+			if len(replies) > 0 {
+				return api.Failure, fmt.Sprintf("expected %v, received %s: %v", expect, strings.Join(replies, ", "), describe(err)), nil
+			}
+			return api.Failure, fmt.Sprintf("expected %v, received no reply: %v", expect, describe(err)), nil
+		}
+		if expect.Matches(buf[:n]) {
+			return api.Success, fmt.Sprintf("received %q", buf[:n]), nil
+		}
+		replies = append(replies, strconv.Quote(string(buf[:n])))
+	}
+}
+
+// describe tells ICMP port unreachable errors apart, they are reported as refused connections.
+func describe(err error) string {
+	if opErr, ok := err.(*net.OpError); ok {
+		if sysErr, ok := opErr.Err.(*os.SyscallError); ok && sysErr.Err == syscall.ECONNREFUSED {
+			return fmt.Sprintf("port unreachable: %v", err)
+		}
+	}
+	return err.Error()
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
@@ -0,0 +1,80 @@
+package udp
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	tcpprobe "stash.appscode.dev/prober-demo/pkg/probe/tcp"
+
+	api "kmodules.xyz/prober/api"
+)
+
+// listen replies to every datagram with the replies, one datagram each.
+func listen(t *testing.T, replies ...string) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			for _, reply := range replies {
+				conn.WriteTo([]byte(reply), addr)
+			}
+		}
+	}()
+	return conn
+}
+
+// closedPort returns a local UDP address nothing listens on.
+func closedPort(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := conn.LocalAddr().String()
+	conn.Close()
+	return addr
+}
+
+func TestDoUDPProbe(t *testing.T) {
+	pong := listen(t, "ignored", "PONG")
+	defer pong.Close()
+	silent := listen(t)
+	defer silent.Close()
+	closed := closedPort(t)
+
+	tests := []struct {
+		name   string
+		addr   string
+		expect *tcpprobe.Expectation
+		want   api.Result
+		reason string
+	}{
+		{"bytes", pong.LocalAddr().String(), &tcpprobe.Expectation{Bytes: []byte("PONG")}, api.Success, `received "PONG"`},
+		{"regex", pong.LocalAddr().String(), &tcpprobe.Expectation{Regex: regexp.MustCompile(`^P\w+`)}, api.Success, `received "PONG"`},
+		{"no matching reply", pong.LocalAddr().String(), &tcpprobe.Expectation{Bytes: []byte("OK")}, api.Failure, `received "ignored", "PONG"`},
+		{"no reply", silent.LocalAddr().String(), &tcpprobe.Expectation{Bytes: []byte("OK")}, api.Failure, "received no reply"},
+		{"no expectation", silent.LocalAddr().String(), nil, api.Success, ""},
+		{"unreachable with expectation", closed, &tcpprobe.Expectation{Bytes: []byte("OK")}, api.Failure, "port unreachable"},
+		{"unreachable", closed, nil, api.Failure, "port unreachable"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, reason, err := DoUDPProbe(test.addr, []byte("PING"), test.expect, 200*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != test.want || !strings.Contains(reason, test.reason) {
+				t.Errorf("result = %s: %s, want %s: %s", result, reason, test.want, test.reason)
+			}
+		})
+	}
+}
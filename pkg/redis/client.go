@@ -0,0 +1,86 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+)
+
+// Roles reported by ROLE. Redis calls replicas slaves.
+const (
+	RoleMaster   = "master"
+	RoleReplica  = "slave"
+	RoleSentinel = "sentinel"
+)
+
+// Conn is a client connection.
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewConn returns a client for conn.
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// Do sends a command and returns its reply. An error reply is returned as an Error.
+func (c *Conn) Do(args ...string) (interface{}, error) {
+	cmd := make([]interface{}, len(args))
+	for i, arg := range args {
+		cmd[i] = arg
+	}
+	if _, err := c.conn.Write(appendValue(nil, cmd)); err != nil {
+		return nil, err
+	}
+	reply, err := readValue(c.r)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := reply.(Error); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// Auth authenticates with password, as username if it is not empty.
+func (c *Conn) Auth(username, password string) error {
+	args := []string{"AUTH", password}
+	if username != "" {
+		args = []string{"AUTH", username, password}
+	}
+	_, err := c.Do(args...)
+	return err
+}
+
+// Ping sends PING and returns the reply, PONG.
+func (c *Conn) Ping() (string, error) {
+	reply, err := c.Do("PING")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(reply), nil
+}
+
+// Role returns the replication role of the server, one of RoleMaster, RoleReplica or RoleSentinel.
+func (c *Conn) Role() (string, error) {
+	reply, err := c.Do("ROLE")
+	if err != nil {
+		return "", err
+	}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) == 0 {
+		return "", fmt.Errorf("unexpected ROLE reply %v", reply)
+	}
+	role, ok := values[0].(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected ROLE reply %v", reply)
+	}
+	return role, nil
+}
+
+// Close sends QUIT and closes the connection.
+func (c *Conn) Close() error {
+	c.conn.Write(appendValue(nil, []interface{}{"QUIT"}))
+	return c.conn.Close()
+}
@@ -0,0 +1,174 @@
+package redis
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValueRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		wire  string
+	}{
+		{"simple string", SimpleString("PONG"), "+PONG\r\n"},
+		{"error", Error("NOAUTH Authentication required."), "-NOAUTH Authentication required.\r\n"},
+		{"integer", int64(-42), ":-42\r\n"},
+		{"bulk string", "a\r\nb", "$4\r\na\r\nb\r\n"},
+		{"empty bulk string", "", "$0\r\n\r\n"},
+		{"nil", nil, "$-1\r\n"},
+		{"array", []interface{}{"slave", "10.0.0.1", int64(6379), []interface{}{}}, "*4\r\n$5\r\nslave\r\n$8\r\n10.0.0.1\r\n:6379\r\n*0\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if wire := string(appendValue(nil, test.value)); wire != test.wire {
+				t.Errorf("appendValue() = %q, want %q", wire, test.wire)
+			}
+			value, err := readValue(bufio.NewReader(strings.NewReader(test.wire)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(value, test.value) {
+				t.Errorf("readValue() = %#v, want %#v", value, test.value)
+			}
+		})
+	}
+}
+
+func TestReadValueInvalid(t *testing.T) {
+	tests := []string{
+		"\r\n",
+		"!oops\r\n",
+		":nan\r\n",
+		"$x\r\n",
+		"$2000000\r\n",
+		"$5\r\nab",
+		"*2000\r\n",
+		"*2\r\n:1\r\n",
+		"+no end of line",
+	}
+	for _, wire := range tests {
+		if v, err := readValue(bufio.NewReader(strings.NewReader(wire))); err == nil {
+			t.Errorf("readValue(%q) = %#v, want an error", wire, v)
+		}
+	}
+}
+
+func TestErrorPrefix(t *testing.T) {
+	if prefix := Error("WRONGPASS invalid username-password pair").Prefix(); prefix != "WRONGPASS" {
+		t.Errorf("Prefix() = %q, want WRONGPASS", prefix)
+	}
+	if prefix := Error("ERR").Prefix(); prefix != "ERR" {
+		t.Errorf("Prefix() = %q, want ERR", prefix)
+	}
+}
+
+// dial serves a connection of s and returns the client end of it.
+func dial(t *testing.T, s *Server) *Conn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	conn, err := net.Dial("tcp", l.Addr().String())
+	// the accepted connection outlives the listener.
+	l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewConn(conn)
+}
+
+func TestServer(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   Server
+		username string
+		password string
+		authErr  string
+		pingErr  string
+		role     string
+	}{
+		{"no password", Server{Role: RoleMaster}, "", "", "", "", RoleMaster},
+		{"password", Server{Password: "s3cr3t"}, "", "s3cr3t", "", "", RoleMaster},
+		{"username", Server{Password: "s3cr3t"}, "demo", "s3cr3t", "", "", RoleMaster},
+		{"replica", Server{Role: RoleReplica, MasterAddr: "10.0.0.1:6379"}, "", "", "", "", RoleReplica},
+		{"wrong password", Server{Password: "s3cr3t"}, "", "wrong", "WRONGPASS", "NOAUTH", ""},
+		{"password not configured", Server{}, "", "s3cr3t", "ERR", "", RoleMaster},
+		{"loading", Server{Loading: true}, "", "", "", "LOADING", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := dial(t, &test.server)
+			defer c.Close()
+			if test.password != "" {
+				if err := c.Auth(test.username, test.password); errorPrefix(err) != test.authErr {
+					t.Errorf("Auth() = %v, want %q", err, test.authErr)
+				}
+			}
+			pong, err := c.Ping()
+			if errorPrefix(err) != test.pingErr {
+				t.Errorf("Ping() = %v, want %q", err, test.pingErr)
+			}
+			if err != nil {
+				return
+			}
+			if pong != "PONG" {
+				t.Errorf("Ping() = %q, want PONG", pong)
+			}
+			if role, err := c.Role(); err != nil || role != test.role {
+				t.Errorf("Role() = %q, %v, want %q", role, err, test.role)
+			}
+		})
+	}
+}
+
+func errorPrefix(err error) string {
+	if e, ok := err.(Error); ok {
+		return e.Prefix()
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func TestServerCommands(t *testing.T) {
+	c := dial(t, &Server{Role: RoleReplica, MasterAddr: "10.0.0.1:6379"})
+	defer c.Close()
+	tests := []struct {
+		args  []string
+		reply interface{}
+	}{
+		{[]string{"ping", "hello"}, "hello"},
+		{[]string{"ECHO", "a b"}, "a b"},
+		{[]string{"ECHO"}, Error("ERR wrong number of arguments for 'echo' command")},
+		{[]string{"GET", "key"}, Error("ERR unknown command 'GET'")},
+		{[]string{"ROLE"}, []interface{}{RoleReplica, "10.0.0.1", int64(6379), "connected", int64(0)}},
+	}
+	for _, test := range tests {
+		reply, err := c.Do(test.args...)
+		if err != nil {
+			reply = err
+		}
+		if !reflect.DeepEqual(reply, test.reply) {
+			t.Errorf("%v = %#v, want %#v", test.args, reply, test.reply)
+		}
+	}
+}
+
+// TestServerInline checks that commands typed by hand, e.g. with telnet, are answered.
+func TestServerInline(t *testing.T) {
+	c := dial(t, &Server{})
+	defer c.Close()
+	if _, err := c.conn.Write([]byte("PING\n")); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := readValue(c.r)
+	if err != nil || reply != SimpleString("PONG") {
+		t.Errorf("inline PING = %#v, %v, want PONG", reply, err)
+	}
+}
@@ -0,0 +1,122 @@
+// Package redis implements the part of the Redis serialization protocol (RESP2) needed to
+// authenticate, ping and ask for the replication role, on the client and on the server side.
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxBulkLength bounds the bulk strings read, the protocol allows much more than a probe needs.
+	maxBulkLength = 1 << 20
+	// maxArrayLength bounds the arrays read.
+	maxArrayLength = 1024
+)
+
+// Error is an error reply of the server, e.g. "NOAUTH Authentication required.".
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Prefix returns the first word of the error, which tells its kind, e.g. "NOAUTH".
+func (e Error) Prefix() string {
+	if i := strings.IndexByte(string(e), ' '); i >= 0 {
+		return string(e[:i])
+	}
+	return string(e)
+}
+
+// SimpleString is a status reply, e.g. "PONG". Bulk strings are read as plain strings.
+type SimpleString string
+
+var errInvalidReply = errors.New("invalid RESP reply")
+
+// readValue reads a value: a SimpleString, an Error, an int64, a string, nil or an []interface{} of them.
+func readValue(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errInvalidReply
+	}
+	switch line[0] {
+	case '+':
+		return SimpleString(line[1:]), nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n > maxBulkLength {
+			return nil, errInvalidReply
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err = io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n > maxArrayLength {
+			return nil, errInvalidReply
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = readValue(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("invalid RESP type %q", line[0])
+}
+
+// readLine reads a line terminated by CRLF, or by LF like inline commands typed by hand.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// appendValue appends v in the wire format. It supports the types returned by readValue.
+func appendValue(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(b, "$-1\r\n"...)
+	case SimpleString:
+		return append(append(append(b, '+'), v...), "\r\n"...)
+	case Error:
+		return append(append(append(b, '-'), v...), "\r\n"...)
+	case int64:
+		return append(strconv.AppendInt(append(b, ':'), v, 10), "\r\n"...)
+	case int:
+		return appendValue(b, int64(v))
+	case string:
+		b = append(strconv.AppendInt(append(b, '$'), int64(len(v)), 10), "\r\n"...)
+		return append(append(b, v...), "\r\n"...)
+	case []interface{}:
+		b = append(strconv.AppendInt(append(b, '*'), int64(len(v)), 10), "\r\n"...)
+		for _, e := range v {
+			b = appendValue(b, e)
+		}
+		return b
+	}
+	panic(fmt.Sprintf("unsupported RESP value %T", v))
+}
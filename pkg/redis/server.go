@@ -0,0 +1,139 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/appscode/go/log"
+)
+
+// Server is a fake Redis server that answers AUTH, PING, ECHO, ROLE and QUIT.
+type Server struct {
+	// Password is required by AUTH before any other command, if it is not empty.
+	// Any user name is accepted along with it.
+	Password string
+	// Role is the replication role reported by ROLE, RoleMaster or RoleReplica.
+	Role string
+	// MasterAddr is the host:port of the master reported by ROLE when Role is RoleReplica.
+	MasterAddr string
+	// Loading makes every command but AUTH and QUIT fail, like a server loading its dataset.
+	Loading bool
+}
+
+// Serve serves the connections accepted by l until it is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := s.serveConn(conn); err != nil && err != io.EOF {
+				log.Debugf("redis connection from %v: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	authenticated := s.Password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			continue
+		}
+		name := strings.ToUpper(args[0])
+		var reply interface{}
+		switch {
+		case name == "QUIT":
+			_, err = conn.Write(appendValue(nil, SimpleString("OK")))
+			return err
+		case name == "AUTH":
+			reply = s.auth(args[1:], &authenticated)
+		case !authenticated:
+			reply = Error("NOAUTH Authentication required.")
+		case s.Loading:
+			reply = Error("LOADING Redis is loading the dataset in memory")
+		default:
+			reply = s.answer(name, args[1:])
+		}
+		if _, err = conn.Write(appendValue(nil, reply)); err != nil {
+			return err
+		}
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings, or inline like "PING\r\n".
+func readCommand(r *bufio.Reader) ([]string, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if b[0] != '*' {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		return strings.Fields(line), nil
+	}
+	v, err := readValue(r)
+	if err != nil {
+		return nil, err
+	}
+	values, _ := v.([]interface{})
+	args := make([]string, 0, len(values))
+	for _, value := range values {
+		arg, ok := value.(string)
+		if !ok {
+			return nil, errInvalidReply
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+func (s *Server) auth(args []string, authenticated *bool) interface{} {
+	if len(args) == 0 || len(args) > 2 {
+		return Error("ERR wrong number of arguments for 'auth' command")
+	}
+	if s.Password == "" {
+		return Error("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+	}
+	if args[len(args)-1] != s.Password {
+		return Error("WRONGPASS invalid username-password pair or user is disabled.")
+	}
+	*authenticated = true
+	return SimpleString("OK")
+}
+
+func (s *Server) answer(name string, args []string) interface{} {
+	switch name {
+	case "PING":
+		if len(args) > 0 {
+			return args[0]
+		}
+		return SimpleString("PONG")
+	case "ECHO":
+		if len(args) != 1 {
+			return Error("ERR wrong number of arguments for 'echo' command")
+		}
+		return args[0]
+	case "ROLE":
+		if s.Role == RoleReplica {
+			host, port, _ := net.SplitHostPort(s.MasterAddr)
+			portNumber, _ := strconv.Atoi(port)
+			return []interface{}{RoleReplica, host, int64(portNumber), "connected", int64(0)}
+		}
+		return []interface{}{RoleMaster, int64(0), []interface{}{}}
+	}
+	return Error(fmt.Sprintf("ERR unknown command '%s'", name))
+}